@@ -3,50 +3,187 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/hcuri/skool-mvp-app/docs"
+	"github.com/hcuri/skool-mvp-app/internal/billing"
 	"github.com/hcuri/skool-mvp-app/internal/config"
 	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/features"
+	"github.com/hcuri/skool-mvp-app/internal/fixtures"
 	apihttp "github.com/hcuri/skool-mvp-app/internal/http"
+	"github.com/hcuri/skool-mvp-app/internal/metrics"
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// statsInterval controls how often business gauges are refreshed from the store.
+const statsInterval = time.Minute
+
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	logger, err := initLogger(cfg.LogLevel)
+	level := zap.NewAtomicLevel()
+	logger, err := initLogger(cfg.LogLevel, level)
 	if err != nil {
 		panic(err)
 	}
 	defer logger.Sync()
 	zap.ReplaceGlobals(logger)
+	logger.Info("effective configuration", zap.Any("config", cfg.Redacted()))
 
 	var store db.Store
 	if cfg.DatabaseURL != "" {
-		store, err = db.NewPostgresStore(context.Background(), cfg.DatabaseURL, logger)
+		pool := db.PoolConfig{
+			MaxConns:        cfg.DBMaxOpenConns,
+			MinConns:        cfg.DBMinConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+			ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		}
+		var pgOpts []db.PostgresOption
+		if replicas := splitList(cfg.DatabaseReadURL); len(replicas) > 0 {
+			pgOpts = append(pgOpts, db.WithReadReplicas(replicas, db.ReplicaOptions{
+				PinWindow:     cfg.DBReadYourWritesWindow,
+				CheckInterval: cfg.DBReplicaCheckInterval,
+			}))
+			logger.Info("routing list reads to read replicas", zap.Int("replicas", len(replicas)))
+		}
+		store, err = db.Open(context.Background(), cfg.DatabaseURL, pool, logger, pgOpts...)
 		if err != nil {
-			logger.Fatal("failed to initialize postgres store", zap.Error(err))
+			logger.Fatal("failed to initialize database store", zap.Error(err))
 		}
-		logger.Info("using postgres store")
+		if closer, ok := store.(io.Closer); ok {
+			defer func() {
+				if err := closer.Close(); err != nil {
+					logger.Error("failed to close database store", zap.Error(err))
+				}
+			}()
+		}
+		if strings.HasPrefix(cfg.DatabaseURL, db.SQLiteScheme) {
+			logger.Info("using sqlite store")
+		} else {
+			logger.Info("using postgres store")
+		}
+	} else if cfg.DataDir != "" {
+		fileStore, err := db.NewFileStore(cfg.DataDir, db.FileStoreOptions{
+			Sync:          db.SyncPolicy(cfg.DataFsync),
+			SyncInterval:  cfg.DataFsyncInterval,
+			SnapshotEvery: cfg.DataSnapshotEvery,
+		}, logger)
+		if err != nil {
+			logger.Fatal("failed to open file store", zap.Error(err))
+		}
+		defer func() {
+			if err := fileStore.Close(); err != nil {
+				logger.Error("failed to close file store", zap.Error(err))
+			}
+		}()
+		store = fileStore
+		logger.Info("using file-backed store", zap.String("dir", cfg.DataDir), zap.String("fsync", cfg.DataFsync))
 	} else {
 		store = db.NewInMemoryStore()
 		logger.Info("using in-memory store")
 	}
+	store = db.NewInstrumentedStore(store)
+	// The file and in-memory stores already serve reads from memory.
+	if cfg.DatabaseURL != "" && cfg.CacheTTL > 0 {
+		store, err = db.NewCachingStore(store, db.CacheOptions{TTL: cfg.CacheTTL, Size: cfg.CacheSize})
+		if err != nil {
+			logger.Fatal("failed to initialize store cache", zap.Error(err))
+		}
+		logger.Info("caching list reads", zap.Duration("ttl", cfg.CacheTTL), zap.Int("size", cfg.CacheSize))
+	}
+
+	if cfg.SeedFile != "" {
+		if err := seedStore(context.Background(), store, cfg.SeedFile, logger); err != nil {
+			logger.Fatal("failed to seed store", zap.String("file", cfg.SeedFile), zap.Error(err))
+		}
+	}
 
-	router := apihttp.NewRouter(store, logger)
+	readiness := apihttp.NewReadiness()
+	limiter := apihttp.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	flags := features.NewService(store, cfg.Features)
+	opts := []apihttp.Option{
+		apihttp.WithReadiness(readiness),
+		apihttp.WithRateLimiter(limiter),
+		apihttp.WithFeatures(flags),
+		apihttp.WithCompression(cfg.CompressMinSize),
+	}
+	if cfg.AdminToken != "" {
+		opts = append(opts, apihttp.WithAdmin(cfg.AdminToken, level))
+	}
+	if cfg.DocsReDoc {
+		opts = append(opts, apihttp.WithReDoc())
+	}
+	if cfg.BillingProvider == "fake" {
+		opts = append(opts, apihttp.WithBilling(billing.NewFake(cfg.BillingWebhookSecret)))
+		logger.Warn("using the fake billing provider; subscriptions are not charged")
+	}
+	if cfg.ValidateRequests || cfg.ValidateResponses {
+		spec, err := openapi.Load(docs.OpenAPISpec)
+		if err != nil {
+			logger.Fatal("failed to parse embedded openapi spec", zap.Error(err))
+		}
+		opts = append(opts, apihttp.WithValidation(spec, apihttp.ValidationOptions{
+			Requests:  cfg.ValidateRequests,
+			Responses: cfg.ValidateResponses,
+		}))
+	}
+	router := apihttp.NewRouter(store, logger, opts...)
 	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: router,
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	reloader := config.NewReloader(cfg, os.Args[1:])
+	reloader.Subscribe(func(old, updated config.Config) {
+		if updated.LogLevel != old.LogLevel {
+			if l, err := zapcore.ParseLevel(updated.LogLevel); err == nil {
+				level.SetLevel(l)
+			}
+		}
+		limiter.SetLimit(updated.RateLimitRPS, updated.RateLimitBurst)
+		flags.SetOverrides(updated.Features)
+	})
+	go watchReload(ctx, reloader, logger)
+
+	go metrics.RunTotalsCollector(ctx, statsInterval, func(ctx context.Context) (metrics.Totals, error) {
+		stats, err := store.Stats(ctx)
+		if err != nil {
+			return metrics.Totals{}, err
+		}
+		return metrics.Totals{
+			Communities:   stats.Communities,
+			Posts:         stats.Posts,
+			ActiveMembers: stats.ActiveMembers,
+		}, nil
+	}, func(err error) {
+		logger.Warn("collect business metrics failed", zap.Error(err))
+	})
+
 	go func() {
 		logger.Info("starting server", zap.String("addr", server.Addr))
 		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -57,7 +194,11 @@ func main() {
 	<-ctx.Done()
 	logger.Info("shutdown signal received")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	readiness.SetDraining()
+	logger.Info("readiness set to draining", zap.Duration("drain_delay", cfg.DrainDelay))
+	time.Sleep(cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error("graceful shutdown failed", zap.Error(err))
@@ -65,15 +206,83 @@ func main() {
 	logger.Info("server stopped")
 }
 
-func initLogger(level string) (*zap.Logger, error) {
+// seedStore loads the fixture at path unless the store already has data, so a
+// persistent database is not seeded twice across restarts.
+func seedStore(ctx context.Context, store db.Store, path string, logger *zap.Logger) error {
+	fx, err := fixtures.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	if stats.Communities > 0 {
+		logger.Info("store already has data; skipping seed", zap.String("file", path))
+		return nil
+	}
+	res, err := fixtures.Load(ctx, store, fx)
+	if err != nil {
+		return err
+	}
+	logger.Info("seeded store",
+		zap.String("file", path),
+		zap.Int("users", len(res.UserIDs)),
+		zap.Int("communities", len(res.CommunityIDs)),
+		zap.Int("memberships", res.Memberships),
+		zap.Int("posts", res.Posts),
+	)
+	return nil
+}
+
+// watchReload reloads hot-reloadable settings on SIGHUP until ctx is done.
+func watchReload(ctx context.Context, reloader *config.Reloader, logger *zap.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			ignored, err := reloader.Reload()
+			if err != nil {
+				logger.Error("config reload failed; keeping current settings", zap.Error(err))
+				continue
+			}
+			if len(ignored) > 0 {
+				logger.Warn("config reload ignored settings that require a restart", zap.Strings("keys", ignored))
+			}
+			logger.Info("config reloaded", zap.Any("config", reloader.Current().Redacted()))
+		}
+	}
+}
+
+// initLogger builds the production logger around level so verbosity can be
+// changed at runtime.
+func initLogger(levelName string, level zap.AtomicLevel) (*zap.Logger, error) {
 	cfg := zap.NewProductionConfig()
 
-	if level != "" {
-		var l zapcore.Level
-		if err := l.Set(level); err == nil {
-			cfg.Level = zap.NewAtomicLevelAt(l)
+	if levelName != "" {
+		l, err := zapcore.ParseLevel(levelName)
+		if err != nil {
+			return nil, err
 		}
+		level.SetLevel(l)
 	}
+	cfg.Level = level
 
 	return cfg.Build()
 }
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/fixtures"
+)
+
+// subcommand parses flags for "skoolctl <group> <action>" and returns the
+// remaining positional arguments.
+func subcommand(name string, args []string, wantArgs int, define func(fs *flag.FlagSet)) ([]string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if define != nil {
+		define(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != wantArgs {
+		return nil, fmt.Errorf("%s: expected %d argument(s), got %d", name, wantArgs, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+func communitiesCmd(ctx context.Context, be backend, args []string, out printer) error {
+	if len(args) == 0 {
+		return errors.New("communities: expected list, create or delete")
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "list":
+		if _, err := subcommand("communities list", args, 0, nil); err != nil {
+			return err
+		}
+		communities, err := be.ListCommunities(ctx)
+		if err != nil {
+			return err
+		}
+		return out.communities(communities)
+	case "create":
+		var input db.CommunityInput
+		if _, err := subcommand("communities create", args, 0, func(fs *flag.FlagSet) {
+			fs.StringVar(&input.Name, "name", "", "community name (required)")
+			fs.StringVar(&input.Description, "description", "", "community description")
+			fs.StringVar((*string)(&input.Visibility), "visibility", "", "public (default), private or hidden; the API requires -user-id for the latter two")
+		}); err != nil {
+			return err
+		}
+		community, err := be.CreateCommunity(ctx, input)
+		if err != nil {
+			return err
+		}
+		return out.communities([]db.Community{community})
+	case "delete":
+		pos, err := subcommand("communities delete", args, 1, nil)
+		if err != nil {
+			return err
+		}
+		if err := be.DeleteCommunity(ctx, pos[0]); err != nil {
+			return err
+		}
+		return out.message("deleted community " + pos[0])
+	default:
+		return fmt.Errorf("communities: unknown action %q", action)
+	}
+}
+
+func postsCmd(ctx context.Context, be backend, args []string, out printer) error {
+	if len(args) == 0 {
+		return errors.New("posts: expected list, create or delete")
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "list":
+		pos, err := subcommand("posts list", args, 1, nil)
+		if err != nil {
+			return err
+		}
+		posts, err := be.ListPostsByCommunity(ctx, pos[0])
+		if err != nil {
+			return err
+		}
+		return out.posts(posts)
+	case "create":
+		var input db.PostInput
+		pos, err := subcommand("posts create", args, 1, func(fs *flag.FlagSet) {
+			fs.StringVar(&input.AuthorID, "author", "", "author user ID (required)")
+			fs.StringVar(&input.Title, "title", "", "post title (required)")
+			fs.StringVar(&input.Content, "content", "", "post body (required)")
+		})
+		if err != nil {
+			return err
+		}
+		post, err := be.CreatePost(ctx, pos[0], input)
+		if err != nil {
+			return err
+		}
+		return out.posts([]db.Post{post})
+	case "delete":
+		pos, err := subcommand("posts delete", args, 2, nil)
+		if err != nil {
+			return err
+		}
+		if err := be.DeletePost(ctx, pos[0], pos[1]); err != nil {
+			return err
+		}
+		return out.message("deleted post " + pos[1])
+	default:
+		return fmt.Errorf("posts: unknown action %q", action)
+	}
+}
+
+// dump is the export/seed file format.
+type dump struct {
+	ExportedAt  time.Time       `json:"exportedAt"`
+	Communities []communityDump `json:"communities"`
+}
+
+type communityDump struct {
+	db.Community
+	Posts []db.Post `json:"posts"`
+}
+
+func exportCmd(ctx context.Context, be backend, args []string, stdout io.Writer) error {
+	var path string
+	if _, err := subcommand("export", args, 0, func(fs *flag.FlagSet) {
+		fs.StringVar(&path, "file", "", "write to PATH instead of stdout")
+	}); err != nil {
+		return err
+	}
+
+	communities, err := be.ListCommunities(ctx)
+	if err != nil {
+		return err
+	}
+	out := dump{ExportedAt: time.Now().UTC(), Communities: make([]communityDump, 0, len(communities))}
+	for _, c := range communities {
+		posts, err := be.ListPostsByCommunity(ctx, c.ID)
+		if err != nil {
+			return fmt.Errorf("export posts of %s: %w", c.ID, err)
+		}
+		if posts == nil {
+			posts = []db.Post{}
+		}
+		out.Communities = append(out.Communities, communityDump{Community: c, Posts: posts})
+	}
+
+	w := stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// seedCmd loads an export file or a fixture file into the backend, or the
+// demo fixture without -file. IDs and post timestamps are assigned by the
+// target, so seeding the same file twice creates duplicates.
+func seedCmd(ctx context.Context, be backend, args []string, out printer) error {
+	var path string
+	if _, err := subcommand("seed", args, 0, func(fs *flag.FlagSet) {
+		fs.StringVar(&path, "file", "", "export or fixture file to load; demo data when empty")
+	}); err != nil {
+		return err
+	}
+
+	raw, name := fixtures.Demo, "demo data"
+	if path != "" {
+		var err error
+		if raw, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		name = path
+	}
+	if data, ok := parseDump(raw); ok {
+		return seedDump(ctx, be, data, out)
+	}
+	fx, err := fixtures.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%s is neither an export nor a fixture: %w", name, err)
+	}
+	return seedFixture(ctx, be, fx, out)
+}
+
+// parseDump decodes an export file. Unknown fields are rejected, so fixtures,
+// which are JSON too when not YAML, are not mistaken for one.
+func parseDump(raw []byte) (dump, bool) {
+	var data dump
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&data); err != nil {
+		return dump{}, false
+	}
+	return data, true
+}
+
+func seedDump(ctx context.Context, be backend, data dump, out printer) error {
+	var communities, posts int
+	for _, c := range data.Communities {
+		created, err := be.CreateCommunity(ctx, db.CommunityInput{Name: c.Name, Description: c.Description, Visibility: c.Visibility})
+		if err != nil {
+			return fmt.Errorf("seed community %q: %w", c.Name, err)
+		}
+		communities++
+		// Posts are listed newest first, as export writes them; create the
+		// oldest first so the order survives a round trip. The API authors
+		// posts as the caller, so only a database keeps the original authors.
+		for _, p := range slices.Backward(c.Posts) {
+			input := db.PostInput{Title: p.Title, Content: p.Content}
+			if _, ok := be.(db.Store); ok {
+				input.AuthorID = p.AuthorID
+			}
+			if _, err := be.CreatePost(ctx, created.ID, input); err != nil {
+				return fmt.Errorf("seed post %q: %w", p.Title, err)
+			}
+			posts++
+		}
+	}
+	return out.message(fmt.Sprintf("seeded %d communities and %d posts", communities, posts))
+}
+
+// seedFixture loads fx in full into a database. The API cannot create users
+// or add others as members, so over the API only communities and posts are
+// created, without authors.
+func seedFixture(ctx context.Context, be backend, fx *fixtures.Fixture, out printer) error {
+	if store, ok := be.(db.Store); ok {
+		res, err := fixtures.Load(ctx, store, fx)
+		if err != nil {
+			return err
+		}
+		return out.message(fmt.Sprintf("seeded %d users, %d communities, %d memberships and %d posts",
+			len(res.UserIDs), len(res.CommunityIDs), res.Memberships, res.Posts))
+	}
+
+	ids := make(map[string]string, len(fx.Communities))
+	for _, c := range fx.Communities {
+		created, err := be.CreateCommunity(ctx, db.CommunityInput{Name: c.Name, Description: c.Description})
+		if err != nil {
+			return fmt.Errorf("seed community %q: %w", c.Key, err)
+		}
+		ids[c.Key] = created.ID
+	}
+	for i, p := range fx.Posts {
+		if _, err := be.CreatePost(ctx, ids[p.Community], db.PostInput{Title: p.Title, Content: p.Content}); err != nil {
+			return fmt.Errorf("seed posts[%d]: %w", i, err)
+		}
+	}
+	msg := fmt.Sprintf("seeded %d communities and %d posts", len(fx.Communities), len(fx.Posts))
+	if len(fx.Users) > 0 || len(fx.Memberships) > 0 {
+		msg += fmt.Sprintf(" (skipped %d users and %d memberships, which need --database-url)", len(fx.Users), len(fx.Memberships))
+	}
+	return out.message(msg)
+}
+
+// generateCmd writes a synthetic fixture for load testing.
+func generateCmd(args []string, stdout io.Writer) error {
+	opts := fixtures.DefaultGenerateOptions()
+	var format, path string
+	if _, err := subcommand("generate", args, 0, func(fs *flag.FlagSet) {
+		fs.IntVar(&opts.Users, "users", opts.Users, "number of users")
+		fs.IntVar(&opts.Communities, "communities", opts.Communities, "number of communities")
+		fs.IntVar(&opts.Posts, "posts", opts.Posts, "number of posts")
+		fs.Uint64Var(&opts.Seed, "seed", opts.Seed, "random seed; the same seed produces the same data")
+		fs.StringVar(&format, "format", "yaml", "yaml or json")
+		fs.StringVar(&path, "file", "", "write to PATH instead of stdout")
+	}); err != nil {
+		return err
+	}
+	if opts.Users < 0 || opts.Communities < 0 || opts.Posts < 0 {
+		return errors.New("generate: counts must not be negative")
+	}
+
+	fx := fixtures.Generate(opts)
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case "yaml":
+		data, err = yaml.Marshal(fx)
+	case "json":
+		data, err = json.MarshalIndent(fx, "", "  ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("generate: unknown format %q (want yaml or json)", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,189 @@
+// Command skoolctl is an operator tool for the Skool MVP API. It manages
+// communities and posts, exports and seeds data, and runs migrations, either
+// through the HTTP API (--api-url) or directly against the database
+// (DATABASE_URL).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/pkg/client"
+)
+
+const usage = `Usage: skoolctl [flags] <command> [args]
+
+Commands:
+  communities list
+  communities create --name NAME [--description TEXT]
+  communities delete ID
+  posts list COMMUNITY_ID
+  posts create --author ID --title TITLE --content TEXT COMMUNITY_ID
+  posts delete COMMUNITY_ID POST_ID
+  export [--file PATH]         write all communities and posts as JSON
+  seed [--file PATH]           load an export file, or demo data without --file
+  migrate                      create or update the database schema (DATABASE_URL only)
+  generate [--users N] [--communities N] [--posts N] [--seed N] [--format yaml|json] [--file PATH]
+                               write a synthetic fixture for cmd/api --seed
+
+Flags:
+`
+
+// backend is the subset of db.Store that skoolctl needs. Both db.Store and
+// *client.Client implement it.
+type backend interface {
+	ListCommunities(ctx context.Context) ([]db.Community, error)
+	CreateCommunity(ctx context.Context, input db.CommunityInput) (db.Community, error)
+	DeleteCommunity(ctx context.Context, communityID string) error
+	ListPostsByCommunity(ctx context.Context, communityID string) ([]db.Post, error)
+	CreatePost(ctx context.Context, communityID string, input db.PostInput) (db.Post, error)
+	DeletePost(ctx context.Context, communityID, postID string) error
+}
+
+var (
+	_ backend = db.Store(nil)
+	_ backend = (*client.Client)(nil)
+)
+
+// env looks up environment variables; tests pass a fake.
+type env func(key string) string
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "skoolctl:", err)
+		os.Exit(1)
+	}
+}
+
+// options are the global flags shared by every command.
+type options struct {
+	apiURL      string
+	databaseURL string
+	userID      string
+	output      string
+	timeout     time.Duration
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv env) (err error) {
+	var opts options
+	fs := flag.NewFlagSet("skoolctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.apiURL, "api-url", getenv("SKOOL_API_URL"), "base URL of the API, e.g. http://localhost:8080 (env SKOOL_API_URL)")
+	fs.StringVar(&opts.databaseURL, "database-url", getenv("DATABASE_URL"), "Postgres DSN used when --api-url is not set (env DATABASE_URL)")
+	fs.StringVar(&opts.userID, "user-id", getenv("SKOOL_USER_ID"), "X-User-ID sent to the API (env SKOOL_USER_ID)")
+	fs.StringVar(&opts.output, "o", "table", "output format: table or json")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "overall timeout for the command")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("unknown output format %q (want table or json)", opts.output)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	out := printer{w: stdout, format: opts.output}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "migrate":
+		return migrate(ctx, opts, rest, out)
+	case "generate":
+		return generateCmd(rest, stdout)
+	}
+
+	be, err := openBackend(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore(be, &err)
+	switch cmd {
+	case "communities":
+		return communitiesCmd(ctx, be, rest, out)
+	case "posts":
+		return postsCmd(ctx, be, rest, out)
+	case "export":
+		return exportCmd(ctx, be, rest, stdout)
+	case "seed":
+		return seedCmd(ctx, be, rest, out)
+	default:
+		return fmt.Errorf("unknown command %q; run skoolctl -h for usage", cmd)
+	}
+}
+
+// openBackend prefers the HTTP API and falls back to the database.
+func openBackend(ctx context.Context, opts options) (backend, error) {
+	if opts.apiURL != "" {
+		var clientOpts []client.Option
+		if opts.userID != "" {
+			clientOpts = append(clientOpts, client.WithUserID(opts.userID))
+		}
+		clientOpts = append(clientOpts, client.WithUserAgent("skoolctl"))
+		return client.New(opts.apiURL, clientOpts...)
+	}
+	if opts.databaseURL != "" {
+		return openDatabase(ctx, opts.databaseURL)
+	}
+	return nil, errors.New("set --api-url (SKOOL_API_URL) or --database-url (DATABASE_URL)")
+}
+
+// openDatabase connects to Postgres with a small pool, or to an SQLite file
+// for sqlite:// URLs; the store creates any missing tables on connect.
+func openDatabase(ctx context.Context, dsn string) (db.Store, error) {
+	pool := db.DefaultPoolConfig()
+	pool.MaxConns = 2
+	store, err := db.Open(ctx, dsn, pool, zap.NewNop())
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return store, nil
+}
+
+func migrate(ctx context.Context, opts options, args []string, out printer) (err error) {
+	if len(args) > 0 {
+		return fmt.Errorf("migrate takes no arguments")
+	}
+	if opts.databaseURL == "" {
+		return errors.New("migrate needs --database-url (DATABASE_URL); the API applies its own schema on startup")
+	}
+	store, err := openDatabase(ctx, opts.databaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeStore(store, &err)
+	return out.message("schema is up to date")
+}
+
+// closeStore releases the pool, replica health checks or SQLite file behind a
+// database backend, folding a close error into *errp. API clients hold
+// nothing to close.
+func closeStore(be any, errp *error) {
+	if closer, ok := be.(io.Closer); ok {
+		*errp = errors.Join(*errp, closer.Close())
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/fixtures"
+	apihttp "github.com/hcuri/skool-mvp-app/internal/http"
+)
+
+// runCtl runs skoolctl against the API at apiURL and returns stdout.
+func runCtl(t *testing.T, apiURL string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	getenv := func(key string) string {
+		if key == "SKOOL_API_URL" {
+			return apiURL
+		}
+		return ""
+	}
+	err := run(context.Background(), args, &stdout, &stderr, getenv)
+	return stdout.String(), err
+}
+
+func newAPI(t *testing.T) (string, db.Store) {
+	t.Helper()
+	store := db.NewInMemoryStore()
+	srv := httptest.NewServer(apihttp.NewRouter(store, zaptest.NewLogger(t)))
+	t.Cleanup(srv.Close)
+	return srv.URL, store
+}
+
+func TestCommunitiesAndPostsOverAPI(t *testing.T) {
+	apiURL, _ := newAPI(t)
+
+	out, err := runCtl(t, apiURL, "-o", "json", "communities", "create", "--name", "Go Fans")
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	var created []db.Community
+	if err := json.Unmarshal([]byte(out), &created); err != nil || len(created) != 1 {
+		t.Fatalf("decode created community %q: %v", out, err)
+	}
+	id := created[0].ID
+
+	if _, err := runCtl(t, apiURL, "--user-id", "u1", "posts", "create", "--author", "u1", "--title", "Hello", "--content", "Hi all", id); err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	out, err = runCtl(t, apiURL, "posts", "list", id)
+	if err != nil {
+		t.Fatalf("list posts: %v", err)
+	}
+	if !strings.HasPrefix(out, "ID") || !strings.Contains(out, "Hello") {
+		t.Fatalf("unexpected table output:\n%s", out)
+	}
+
+	if _, err := runCtl(t, apiURL, "communities", "delete", id); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if _, err := runCtl(t, apiURL, "communities", "delete", id); err == nil || !strings.Contains(err.Error(), "community not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestExportSeedRoundTrip(t *testing.T) {
+	sourceURL, _ := newAPI(t)
+	if _, err := runCtl(t, sourceURL, "seed"); err != nil {
+		t.Fatalf("seed demo data: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.json")
+	if _, err := runCtl(t, sourceURL, "export", "--file", path); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	targetURL, target := newAPI(t)
+	out, err := runCtl(t, targetURL, "seed", "--file", path)
+	if err != nil {
+		t.Fatalf("seed from export: %v", err)
+	}
+	if strings.TrimSpace(out) != "seeded 2 communities and 3 posts" {
+		t.Fatalf("unexpected seed output %q", out)
+	}
+	stats, err := target.Stats(context.Background())
+	if err != nil || stats.Communities != 2 || stats.Posts != 3 {
+		t.Fatalf("target stats = %+v, %v", stats, err)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	if _, err := runCtl(t, "", "communities", "list"); err == nil || !strings.Contains(err.Error(), "--api-url") {
+		t.Fatalf("expected missing backend error, got %v", err)
+	}
+	if _, err := runCtl(t, "http://localhost:1", "migrate"); err == nil || !strings.Contains(err.Error(), "DATABASE_URL") {
+		t.Fatalf("expected migrate to require a database, got %v", err)
+	}
+	if _, err := runCtl(t, "http://localhost:1", "-o", "yaml", "communities", "list"); err == nil {
+		t.Fatal("expected unknown output format to fail")
+	}
+}
+
+func TestGenerateWritesLoadableFixture(t *testing.T) {
+	for _, format := range []string{"yaml", "json"} {
+		path := filepath.Join(t.TempDir(), "fixture."+format)
+		if _, err := runCtl(t, "", "generate", "--users", "10", "--communities", "3", "--posts", "40", "--format", format, "--file", path); err != nil {
+			t.Fatalf("%s: generate: %v", format, err)
+		}
+		fx, err := fixtures.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: read generated fixture: %v", format, err)
+		}
+		if len(fx.Users) != 10 || len(fx.Communities) != 3 || len(fx.Posts) != 40 {
+			t.Fatalf("%s: unexpected fixture sizes", format)
+		}
+	}
+}
+
+func TestSeedLoadsFixtures(t *testing.T) {
+	for _, format := range []string{"yaml", "json"} {
+		path := filepath.Join(t.TempDir(), "fixture."+format)
+		if _, err := runCtl(t, "", "generate", "--users", "4", "--communities", "2", "--posts", "6", "--format", format, "--file", path); err != nil {
+			t.Fatalf("%s: generate: %v", format, err)
+		}
+
+		// The API cannot create users, so only communities and posts arrive.
+		apiURL, store := newAPI(t)
+		out, err := runCtl(t, apiURL, "seed", "--file", path)
+		if err != nil {
+			t.Fatalf("%s: seed over API: %v", format, err)
+		}
+		if !strings.HasPrefix(out, "seeded 2 communities and 6 posts (skipped 4 users") {
+			t.Fatalf("%s: unexpected seed output %q", format, out)
+		}
+		if stats, err := store.Stats(context.Background()); err != nil || stats.Communities != 2 || stats.Posts != 6 {
+			t.Fatalf("%s: stats = %+v, %v", format, stats, err)
+		}
+
+		// A database gets everything.
+		dsn := "sqlite://" + filepath.Join(t.TempDir(), "skool.db")
+		var stdout bytes.Buffer
+		getenv := func(key string) string {
+			if key == "DATABASE_URL" {
+				return dsn
+			}
+			return ""
+		}
+		if err := run(context.Background(), []string{"seed", "--file", path}, &stdout, io.Discard, getenv); err != nil {
+			t.Fatalf("%s: seed database: %v", format, err)
+		}
+		if !strings.HasPrefix(stdout.String(), "seeded 4 users, 2 communities, ") || !strings.Contains(stdout.String(), "and 6 posts") {
+			t.Fatalf("%s: unexpected seed output %q", format, stdout.String())
+		}
+	}
+}
+
+func TestDatabaseCommandsCloseTheStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "skool.db")
+	getenv := func(key string) string {
+		if key == "DATABASE_URL" {
+			return "sqlite://" + path
+		}
+		return ""
+	}
+	for _, args := range [][]string{{"migrate"}, {"seed"}, {"communities", "list"}} {
+		if err := run(context.Background(), args, io.Discard, io.Discard, getenv); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		// Closing the last connection checkpoints and removes the WAL.
+		if _, err := os.Stat(path + "-wal"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%v left the database open: stat WAL = %v", args, err)
+		}
+	}
+}
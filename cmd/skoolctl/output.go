@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// printer renders command results as an aligned table or as JSON.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func (p printer) communities(communities []db.Community) error {
+	if p.format == "json" {
+		return p.json(nonNil(communities))
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSLUG\tNAME\tVISIBILITY\tDESCRIPTION")
+	for _, c := range communities {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.ID, c.Slug, c.Name, c.Visibility, c.Description)
+	}
+	return tw.Flush()
+}
+
+func (p printer) posts(posts []db.Post) error {
+	if p.format == "json" {
+		return p.json(nonNil(posts))
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tAUTHOR\tTITLE\tCREATED")
+	for _, post := range posts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", post.ID, post.AuthorID, post.Title, post.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func (p printer) message(msg string) error {
+	if p.format == "json" {
+		return p.json(map[string]string{"message": msg})
+	}
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
+
+func (p printer) json(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// nonNil makes empty results print as [] rather than null.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
@@ -1,8 +1,19 @@
 package docs
 
-import _ "embed"
+import "embed"
 
 // OpenAPISpec contains the embedded OpenAPI document.
 //
 //go:embed openapi.yaml
 var OpenAPISpec []byte
+
+// SwaggerUI holds the self-hosted Swagger UI page and assets under swagger-ui/.
+//
+//go:embed swagger-ui/*.html swagger-ui/*.js swagger-ui/*.css swagger-ui/*.png
+var SwaggerUI embed.FS
+
+// ReDoc holds the ReDoc page under redoc/ and the vendored
+// redoc.standalone.js it loads (see redoc/README.md).
+//
+//go:embed redoc
+var ReDoc embed.FS
@@ -0,0 +1,7 @@
+// Kept out of index.html so the page works under a script-src 'self' CSP.
+window.onload = () => {
+  window.ui = SwaggerUIBundle({
+    url: '/swagger/openapi.yaml',
+    dom_id: '#swagger-ui',
+  });
+};
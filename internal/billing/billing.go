@@ -0,0 +1,101 @@
+// Package billing connects paid communities to a payment provider.
+package billing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// SignatureHeader carries the webhook signature, formatted by Sign as
+// t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">.
+const SignatureHeader = "Billing-Signature"
+
+// SignatureTolerance is how far a webhook's timestamp may be from now before
+// it is rejected as a replay.
+const SignatureTolerance = 5 * time.Minute
+
+// ErrInvalidSignature indicates a webhook that is unsigned, signed with
+// another secret, or too old.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Provider is the pluggable billing provider behind paid communities. It
+// starts and cancels subscriptions, and turns the webhooks it sends about
+// payments into Events.
+type Provider interface {
+	// Subscribe starts a subscription to plan for userID, trialing when the
+	// plan has a trial.
+	Subscribe(ctx context.Context, plan db.Plan, userID string) (db.Subscription, error)
+	// Cancel ends a subscription immediately.
+	Cancel(ctx context.Context, subscriptionID string) error
+	// ParseWebhook verifies a webhook delivery and decodes its event. It
+	// returns ErrInvalidSignature when the delivery cannot be trusted.
+	ParseWebhook(payload []byte, header http.Header) (Event, error)
+}
+
+// Event reports a change to a subscription, such as a renewal or a failed
+// payment. It is also the webhook payload of the Fake provider. IDs are
+// unique, so redelivered events can be recognized.
+type Event struct {
+	ID               string                `json:"id"`
+	Type             string                `json:"type"`
+	SubscriptionID   string                `json:"subscriptionId"`
+	Status           db.SubscriptionStatus `json:"status"`
+	CurrentPeriodEnd *time.Time            `json:"currentPeriodEnd,omitempty"`
+}
+
+// Sign returns the SignatureHeader value for payload sent at t.
+func Sign(secret, payload []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, payload))
+}
+
+// Verify checks a SignatureHeader value against payload, accepting
+// timestamps within SignatureTolerance of now.
+func Verify(secret, payload []byte, header string, now time.Time) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = val
+		case "v1":
+			// Several signatures are sent while the secret is rotated.
+			if sig, err := hex.DecodeString(val); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	want := mac(secret, ts, payload)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret []byte, ts string, payload []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,89 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestVerify(t *testing.T) {
+	secret, payload := []byte("whsec"), []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1_700_000_000, 0)
+	header := Sign(secret, payload, now)
+
+	if err := Verify(secret, payload, header, now.Add(time.Minute)); err != nil {
+		t.Fatalf("expected a fresh signature to verify, got %v", err)
+	}
+	// A rotated secret is sent alongside the old one.
+	rotated := Sign([]byte("old"), payload, now) + "," + strings.Split(header, ",")[1]
+	if err := Verify(secret, payload, rotated, now); err != nil {
+		t.Fatalf("expected any matching signature to verify, got %v", err)
+	}
+	for name, tc := range map[string]struct {
+		secret, payload []byte
+		header          string
+		now             time.Time
+	}{
+		"wrong secret":     {[]byte("other"), payload, header, now},
+		"tampered payload": {secret, []byte(`{"id":"evt_2"}`), header, now},
+		"missing":          {secret, payload, "", now},
+		"garbled":          {secret, payload, "t=x,v1=zz", now},
+		"replayed":         {secret, payload, header, now.Add(SignatureTolerance + time.Second)},
+		"from the future":  {secret, payload, header, now.Add(-SignatureTolerance - time.Second)},
+	} {
+		if err := Verify(tc.secret, tc.payload, tc.header, tc.now); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("whsec")
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	fake.now = func() time.Time { return now }
+
+	trial, err := fake.Subscribe(ctx, db.Plan{ID: "p1", Interval: db.PlanMonthly, TrialDays: 7}, "u1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if trial.Status != db.SubscriptionTrialing || trial.PlanID != "p1" || !trial.CurrentPeriodEnd.Equal(now.AddDate(0, 0, 7)) {
+		t.Fatalf("unexpected trial subscription %+v", trial)
+	}
+	yearly, err := fake.Subscribe(ctx, db.Plan{ID: "p2", Interval: db.PlanYearly}, "u1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if yearly.Status != db.SubscriptionActive || !yearly.CurrentPeriodEnd.Equal(now.AddDate(1, 0, 0)) || yearly.ID == trial.ID {
+		t.Fatalf("unexpected yearly subscription %+v", yearly)
+	}
+
+	if err := fake.Cancel(ctx, trial.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if sub, ok := fake.Subscription(trial.ID); !ok || sub.Status != db.SubscriptionCanceled {
+		t.Fatalf("expected the subscription to be canceled, got %+v, %v", sub, ok)
+	}
+	if err := fake.Cancel(ctx, "sub_missing"); err == nil {
+		t.Fatalf("expected error canceling an unknown subscription")
+	}
+
+	payload, header := fake.Webhook(Event{SubscriptionID: yearly.ID, Status: db.SubscriptionPastDue})
+	event, err := fake.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("parse webhook: %v", err)
+	}
+	if event.ID == "" || event.Type != "subscription.updated" || event.SubscriptionID != yearly.ID || event.Status != db.SubscriptionPastDue {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if _, err := NewFake("other").ParseWebhook(payload, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature with another secret, got %v", err)
+	}
+	if _, err := fake.ParseWebhook([]byte(`{}`), header); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a tampered payload, got %v", err)
+	}
+}
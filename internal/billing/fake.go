@@ -0,0 +1,121 @@
+package billing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// Fake is a Provider that charges nothing, for tests and local development.
+// Subscriptions live in memory and webhooks are Events signed with the
+// secret, which Webhook builds as the provider would send them.
+type Fake struct {
+	secret []byte
+	now    func() time.Time
+
+	mu            sync.Mutex
+	subscriptions map[string]db.Subscription
+}
+
+// NewFake returns a Fake that signs and verifies webhooks with secret.
+func NewFake(secret string) *Fake {
+	return &Fake{
+		secret:        []byte(secret),
+		now:           time.Now,
+		subscriptions: make(map[string]db.Subscription),
+	}
+}
+
+// Subscribe starts a trialing subscription when the plan has a trial, and an
+// active one paid for the first interval otherwise.
+func (f *Fake) Subscribe(_ context.Context, plan db.Plan, userID string) (db.Subscription, error) {
+	if userID == "" {
+		return db.Subscription{}, fmt.Errorf("billing: user is required")
+	}
+	now := f.now().UTC()
+	sub := db.Subscription{ID: "sub_" + randomHex(12), PlanID: plan.ID, Status: db.SubscriptionActive}
+	end := now.AddDate(0, 1, 0)
+	switch {
+	case plan.TrialDays > 0:
+		sub.Status = db.SubscriptionTrialing
+		end = now.AddDate(0, 0, plan.TrialDays)
+	case plan.Interval == db.PlanYearly:
+		end = now.AddDate(1, 0, 0)
+	}
+	sub.CurrentPeriodEnd = &end
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (f *Fake) Cancel(_ context.Context, subscriptionID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subscriptions[subscriptionID]
+	if !ok {
+		return fmt.Errorf("billing: unknown subscription %q", subscriptionID)
+	}
+	sub.Status = db.SubscriptionCanceled
+	f.subscriptions[subscriptionID] = sub
+	return nil
+}
+
+// Subscription returns the provider's view of a subscription.
+func (f *Fake) Subscription(subscriptionID string) (db.Subscription, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subscriptions[subscriptionID]
+	return sub, ok
+}
+
+func (f *Fake) ParseWebhook(payload []byte, header http.Header) (Event, error) {
+	if err := Verify(f.secret, payload, header.Get(SignatureHeader), f.now()); err != nil {
+		return Event{}, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("billing: decode event: %w", err)
+	}
+	if event.ID == "" || event.SubscriptionID == "" {
+		return Event{}, fmt.Errorf("billing: event without id or subscription")
+	}
+	switch event.Status {
+	case db.SubscriptionTrialing, db.SubscriptionActive, db.SubscriptionPastDue, db.SubscriptionCanceled:
+	default:
+		return Event{}, fmt.Errorf("billing: unknown status %q", event.Status)
+	}
+	return event, nil
+}
+
+// Webhook returns a signed delivery of event, giving it an ID and type when
+// they are empty.
+func (f *Fake) Webhook(event Event) (payload []byte, header http.Header) {
+	if event.ID == "" {
+		event.ID = "evt_" + randomHex(12)
+	}
+	if event.Type == "" {
+		event.Type = "subscription.updated"
+	}
+	// Event always encodes.
+	payload, _ = json.Marshal(event)
+	header = make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set(SignatureHeader, Sign(f.secret, payload, f.now()))
+	return payload, header
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand.Read never returns an error.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
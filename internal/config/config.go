@@ -1,28 +1,519 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds runtime configuration for the API server.
+//
+// Values are resolved in increasing order of precedence: built-in defaults, the
+// config file (--config or CONFIG_FILE), environment variables (or their _FILE
+// variants), then command-line flags.
 type Config struct {
-	Port     string
-	LogLevel string
+	Port        string
+	LogLevel    string
 	DatabaseURL string
+
+	// Postgres read replicas (comma-separated DSNs) and how reads are routed
+	// to them.
+	DatabaseReadURL        string
+	DBReadYourWritesWindow time.Duration
+	DBReplicaCheckInterval time.Duration
+
+	// Cache for community and post lists read from DATABASE_URL; a zero TTL
+	// disables it.
+	CacheTTL  time.Duration
+	CacheSize int
+
+	// HTTP server timeouts.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// CompressMinSize is the smallest response body that is compressed; a
+	// negative value disables compression.
+	CompressMinSize int
+
+	// ShutdownTimeout bounds how long in-flight requests may run after a
+	// shutdown signal; DrainDelay is how long /readyz fails before that starts.
+	ShutdownTimeout time.Duration
+	DrainDelay      time.Duration
+
+	// File-backed store used when DatabaseURL is empty and DataDir is set.
+	DataDir           string
+	DataFsync         string
+	DataFsyncInterval time.Duration
+	DataSnapshotEvery int
+
+	// SeedFile is a fixture file loaded into the store at startup when the
+	// store has no communities yet.
+	SeedFile string
+
+	// Postgres connection pool.
+	DBMaxOpenConns    int
+	DBMinConns        int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	// AdminToken enables the /admin endpoints when set; callers must send it
+	// as a bearer token.
+	AdminToken string
+
+	// Billing provider for paid communities, and the secret its webhooks are
+	// signed with. Plans can be created but not subscribed to without one.
+	BillingProvider      string
+	BillingWebhookSecret string
+
+	// OpenAPI contract enforcement. Response validation buffers every
+	// response and is intended for development.
+	ValidateRequests  bool
+	ValidateResponses bool
+
+	// DocsReDoc serves the vendored ReDoc view alongside Swagger UI.
+	DocsReDoc bool
+
+	// Hot-reloadable settings; see Reloader.
+	RateLimitRPS   int
+	RateLimitBurst int
+	Features       map[string]bool
+}
+
+// setting describes one configuration value and every source it can come from.
+// key is the (dotted) config file key; the flag name is derived from it.
+// Reloadable settings are safe to change on SIGHUP without a restart. List
+// settings hold comma-separated values, which are redacted one by one.
+type setting struct {
+	key        string
+	env        string
+	def        string
+	usage      string
+	secret     bool
+	list       bool
+	reloadable bool
+	field      func(*Config) any
+}
+
+var settings = []setting{
+	{key: "port", env: "PORT", def: "8080", usage: "HTTP listen port",
+		field: func(c *Config) any { return &c.Port }},
+	{key: "log_level", env: "LOG_LEVEL", def: "info", usage: "log level (debug, info, warn, error)", reloadable: true,
+		field: func(c *Config) any { return &c.LogLevel }},
+	{key: "database_url", env: "DATABASE_URL", usage: "Postgres DSN, or sqlite:///path/to/file.db for an embedded SQLite database; in-memory store when empty", secret: true,
+		field: func(c *Config) any { return &c.DatabaseURL }},
+	{key: "database_read_url", env: "DATABASE_READ_URL", usage: "comma-separated Postgres read replica DSNs for list reads; primary only when empty", secret: true, list: true,
+		field: func(c *Config) any { return &c.DatabaseReadURL }},
+	{key: "db.read_your_writes_window", env: "DB_READ_YOUR_WRITES_WINDOW", def: "2s", usage: "how long a session reads from the primary after it writes",
+		field: func(c *Config) any { return &c.DBReadYourWritesWindow }},
+	{key: "db.replica_check_interval", env: "DB_REPLICA_CHECK_INTERVAL", def: "5s", usage: "how often read replicas are health checked",
+		field: func(c *Config) any { return &c.DBReplicaCheckInterval }},
+	{key: "cache.ttl", env: "CACHE_TTL", def: "5s", usage: "how long community and post lists from DATABASE_URL are cached (0 = no cache)",
+		field: func(c *Config) any { return &c.CacheTTL }},
+	{key: "cache.size", env: "CACHE_SIZE", def: "1000", usage: "maximum number of cached lists",
+		field: func(c *Config) any { return &c.CacheSize }},
+	{key: "data.dir", env: "DATA_DIR", usage: "directory for the file-backed store used when DATABASE_URL is empty; memory only when both are empty",
+		field: func(c *Config) any { return &c.DataDir }},
+	{key: "data.fsync", env: "DATA_FSYNC", def: "always", usage: "when to fsync the journal: always, interval or never",
+		field: func(c *Config) any { return &c.DataFsync }},
+	{key: "data.fsync_interval", env: "DATA_FSYNC_INTERVAL", def: "1s", usage: "how often the journal is fsynced when DATA_FSYNC=interval",
+		field: func(c *Config) any { return &c.DataFsyncInterval }},
+	{key: "data.snapshot_every", env: "DATA_SNAPSHOT_EVERY", def: "1000", usage: "compact the journal into a snapshot after this many writes (0 = only on shutdown)",
+		field: func(c *Config) any { return &c.DataSnapshotEvery }},
+	{key: "seed", env: "SEED_FILE", usage: "fixture file (YAML or JSON) to load into an empty store at startup",
+		field: func(c *Config) any { return &c.SeedFile }},
+	{key: "http.read_timeout", env: "HTTP_READ_TIMEOUT", def: "15s", usage: "maximum duration for reading a request",
+		field: func(c *Config) any { return &c.ReadTimeout }},
+	{key: "http.read_header_timeout", env: "HTTP_READ_HEADER_TIMEOUT", def: "5s", usage: "maximum duration for reading request headers",
+		field: func(c *Config) any { return &c.ReadHeaderTimeout }},
+	{key: "http.write_timeout", env: "HTTP_WRITE_TIMEOUT", def: "30s", usage: "maximum duration before timing out response writes",
+		field: func(c *Config) any { return &c.WriteTimeout }},
+	{key: "http.idle_timeout", env: "HTTP_IDLE_TIMEOUT", def: "120s", usage: "maximum keep-alive idle time",
+		field: func(c *Config) any { return &c.IdleTimeout }},
+	{key: "http.compress_min_size", env: "HTTP_COMPRESS_MIN_SIZE", def: "1024", usage: "smallest response body in bytes compressed with gzip, br or zstd (-1 = never compress)",
+		field: func(c *Config) any { return &c.CompressMinSize }},
+	{key: "shutdown.timeout", env: "SHUTDOWN_TIMEOUT", def: "5s", usage: "budget for in-flight requests after a shutdown signal",
+		field: func(c *Config) any { return &c.ShutdownTimeout }},
+	{key: "shutdown.drain_delay", env: "SHUTDOWN_DRAIN_DELAY", def: "5s", usage: "how long /readyz fails before the listener closes",
+		field: func(c *Config) any { return &c.DrainDelay }},
+	{key: "db.max_open_conns", env: "DB_MAX_OPEN_CONNS", def: "10", usage: "maximum open Postgres connections",
+		field: func(c *Config) any { return &c.DBMaxOpenConns }},
+	{key: "db.min_conns", env: "DB_MIN_CONNS", def: "0", usage: "Postgres connections kept open even when idle",
+		field: func(c *Config) any { return &c.DBMinConns }},
+	{key: "db.conn_max_lifetime", env: "DB_CONN_MAX_LIFETIME", def: "30m", usage: "maximum lifetime of a Postgres connection",
+		field: func(c *Config) any { return &c.DBConnMaxLifetime }},
+	{key: "db.conn_max_idle_time", env: "DB_CONN_MAX_IDLE_TIME", def: "0s", usage: "maximum idle time of a Postgres connection (0 = pgx default of 30m)",
+		field: func(c *Config) any { return &c.DBConnMaxIdleTime }},
+	{key: "openapi.validate_requests", env: "VALIDATE_REQUESTS", def: "false", usage: "reject requests that do not match docs/openapi.yaml",
+		field: func(c *Config) any { return &c.ValidateRequests }},
+	{key: "openapi.validate_responses", env: "VALIDATE_RESPONSES", def: "false", usage: "log responses that do not match docs/openapi.yaml (dev only)",
+		field: func(c *Config) any { return &c.ValidateResponses }},
+	{key: "docs.redoc", env: "DOCS_REDOC", def: "false", usage: "serve the ReDoc view at /swagger/redoc/",
+		field: func(c *Config) any { return &c.DocsReDoc }},
+	{key: "admin.token", env: "ADMIN_TOKEN", usage: "bearer token for /admin endpoints; admin API disabled when empty", secret: true,
+		field: func(c *Config) any { return &c.AdminToken }},
+	{key: "billing.provider", env: "BILLING_PROVIDER", usage: "billing provider for paid communities: fake; subscriptions disabled when empty",
+		field: func(c *Config) any { return &c.BillingProvider }},
+	{key: "billing.webhook_secret", env: "BILLING_WEBHOOK_SECRET", usage: "secret the billing provider signs webhooks with", secret: true,
+		field: func(c *Config) any { return &c.BillingWebhookSecret }},
+	{key: "rate_limit.rps", env: "RATE_LIMIT_RPS", def: "0", usage: "sustained API requests per second (0 = unlimited)", reloadable: true,
+		field: func(c *Config) any { return &c.RateLimitRPS }},
+	{key: "rate_limit.burst", env: "RATE_LIMIT_BURST", def: "20", usage: "API request burst size", reloadable: true,
+		field: func(c *Config) any { return &c.RateLimitBurst }},
+	{key: "features", env: "FEATURES", usage: "feature flag overrides as name=true,name=false", reloadable: true,
+		field: func(c *Config) any { return &c.Features }},
+}
+
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// Load resolves configuration from defaults, an optional config file,
+// environment variables and the command-line args (without the program name).
+// It returns an error describing every invalid value, or flag.ErrHelp when
+// usage was requested.
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("skool-mvp-api", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or JSON config file (env CONFIG_FILE)")
+	flagVals := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagVals[s.key] = fs.String(s.flagName(), "", fmt.Sprintf("%s (env %s, default %q)", s.usage, s.env, s.def))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	flagsSet := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { flagsSet[f.Name] = true })
+
+	var fileVals map[string]string
+	if *configPath != "" {
+		var err error
+		if fileVals, err = readFile(*configPath); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var (
+		cfg  Config
+		errs []error
+	)
+	for _, s := range settings {
+		raw, source, err := resolve(s, fileVals, flagsSet[s.flagName()], *flagVals[s.key])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := s.apply(&cfg, raw); err != nil {
+			shown := raw
+			if s.secret {
+				shown = "<redacted>"
+			}
+			errs = append(errs, fmt.Errorf("%s=%q (from %s): %w", s.env, shown, source, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// resolve picks the highest-precedence raw value for s and names its source.
+func resolve(s setting, fileVals map[string]string, flagSet bool, flagVal string) (string, string, error) {
+	if flagSet {
+		return flagVal, "flag --" + s.flagName(), nil
+	}
+	if val := os.Getenv(s.env); val != "" {
+		if os.Getenv(s.env+"_FILE") != "" {
+			return "", "", fmt.Errorf("%s and %s_FILE are both set", s.env, s.env)
+		}
+		return val, "env " + s.env, nil
+	}
+	if path := os.Getenv(s.env + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("%s_FILE: %w", s.env, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), "env " + s.env + "_FILE", nil
+	}
+	if val, ok := fileVals[s.key]; ok {
+		return val, "config file key " + s.key, nil
+	}
+	return s.def, "default", nil
+}
+
+// readFile parses a YAML or JSON config file into dotted keys. Nested mappings
+// are flattened, so `http: {read_timeout: 5s}` becomes http.read_timeout.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var doc map[string]any
+	// JSON is a subset of YAML, so one decoder covers both formats.
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+	}
+
+	out := make(map[string]string)
+	flatten("", doc, known, out)
+	var errs []error
+	for key := range out {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("config file %s: unknown key %q", path, key))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// flatten stops descending at known keys so map-valued settings such as
+// features are kept whole and encoded in their env var form.
+func flatten(prefix string, in map[string]any, known map[string]bool, out map[string]string) {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		nested, isMap := v.(map[string]any)
+		switch {
+		case isMap && known[key]:
+			out[key] = formatMap(nested)
+		case isMap:
+			flatten(key, nested, known, out)
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func formatMap(m map[string]any) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Validate checks cross-field constraints and value ranges.
+func (c Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT=%q: must be a number between 1 and 65535", c.Port))
+	}
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL=%q: %w", c.LogLevel, err))
+	}
+	if c.ReadHeaderTimeout <= 0 {
+		errs = append(errs, errors.New("HTTP_READ_HEADER_TIMEOUT: must be positive"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT: must be positive"))
+	}
+	if c.DBMaxOpenConns < 1 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS: must be at least 1"))
+	}
+	if c.DatabaseReadURL != "" {
+		if c.DatabaseURL == "" || strings.HasPrefix(c.DatabaseURL, "sqlite://") {
+			errs = append(errs, errors.New("DATABASE_READ_URL: requires a Postgres DATABASE_URL"))
+		}
+		if c.DBReadYourWritesWindow < 0 {
+			errs = append(errs, errors.New("DB_READ_YOUR_WRITES_WINDOW: must not be negative"))
+		}
+		if c.DBReplicaCheckInterval <= 0 {
+			errs = append(errs, errors.New("DB_REPLICA_CHECK_INTERVAL: must be positive"))
+		}
+	}
+	if c.CacheTTL < 0 {
+		errs = append(errs, errors.New("CACHE_TTL: must not be negative"))
+	}
+	if c.CacheTTL > 0 && c.CacheSize < 1 {
+		errs = append(errs, errors.New("CACHE_SIZE: must be at least 1"))
+	}
+	if c.DBMinConns < 0 || c.DBMinConns > c.DBMaxOpenConns {
+		errs = append(errs, fmt.Errorf("DB_MIN_CONNS: must be between 0 and DB_MAX_OPEN_CONNS (%d)", c.DBMaxOpenConns))
+	}
+	switch c.DataFsync {
+	case "always", "never":
+	case "interval":
+		if c.DataFsyncInterval <= 0 {
+			errs = append(errs, errors.New("DATA_FSYNC_INTERVAL: must be positive when DATA_FSYNC=interval"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("DATA_FSYNC=%q: must be always, interval or never", c.DataFsync))
+	}
+	if c.DataSnapshotEvery < 0 {
+		errs = append(errs, errors.New("DATA_SNAPSHOT_EVERY: must not be negative"))
+	}
+	switch c.BillingProvider {
+	case "":
+	case "fake":
+		if c.BillingWebhookSecret == "" {
+			errs = append(errs, errors.New("BILLING_WEBHOOK_SECRET: required when BILLING_PROVIDER is set"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("BILLING_PROVIDER=%q: must be fake or empty", c.BillingProvider))
+	}
+	if c.RateLimitRPS > 0 && c.RateLimitBurst < 1 {
+		errs = append(errs, errors.New("RATE_LIMIT_BURST: must be at least 1 when RATE_LIMIT_RPS is set"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns the effective configuration keyed by config file key, with
+// secrets masked, suitable for logging at startup.
+func (c Config) Redacted() map[string]string {
+	out := make(map[string]string, len(settings))
+	for _, s := range settings {
+		val := s.format(&c)
+		if s.secret && val != "" {
+			val = s.redact(val)
+		}
+		out[s.key] = val
+	}
+	return out
+}
+
+// redact masks a secret value, element by element for list settings.
+func (s setting) redact(val string) string {
+	if !s.list {
+		return redact(val)
+	}
+	parts := strings.Split(val, ",")
+	for i, part := range parts {
+		parts[i] = redact(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ",")
+}
+
+// credentialParams are DSN query parameters that carry passwords.
+var credentialParams = []string{"password", "sslpassword"}
+
+// redact hides the passwords of URL-shaped secrets, in the userinfo and the
+// query, and everything else entirely.
+func redact(val string) string {
+	u, err := url.Parse(val)
+	if err != nil || u.Scheme == "" {
+		return "<redacted>"
+	}
+	query := u.Query()
+	masked := false
+	for _, param := range credentialParams {
+		if query.Has(param) {
+			query.Set(param, "xxxxx")
+			masked = true
+		}
+	}
+	if masked {
+		u.RawQuery = query.Encode()
+	}
+	if u.User == nil && !masked {
+		return "<redacted>"
+	}
+	return u.Redacted()
+}
+
+func (s setting) apply(c *Config, raw string) error {
+	switch p := s.field(c).(type) {
+	case *string:
+		*p = raw
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		if v < 0 {
+			return errors.New("must not be negative")
+		}
+		*p = v
+	case *time.Duration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+		if v < 0 {
+			return errors.New("must not be negative")
+		}
+		*p = v
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*p = v
+	case *map[string]bool:
+		v, err := parseBoolMap(raw)
+		if err != nil {
+			return err
+		}
+		*p = v
+	default:
+		return fmt.Errorf("unsupported field type %T", p)
+	}
+	return nil
 }
 
-// Load reads configuration from environment variables, supplying defaults when unset.
-func Load() Config {
-	return Config{
-		Port:     getEnv("PORT", "8080"),
-		LogLevel: getEnv("LOG_LEVEL", "info"),
-		DatabaseURL: os.Getenv("DATABASE_URL"),
+// parseBoolMap parses "a=true,b=false"; a bare name means true.
+func parseBoolMap(raw string) (map[string]bool, error) {
+	out := make(map[string]bool)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, val, found := strings.Cut(pair, "=")
+		enabled := true
+		if found {
+			b, err := strconv.ParseBool(strings.TrimSpace(val))
+			if err != nil {
+				return nil, fmt.Errorf("%s: must be true or false", name)
+			}
+			enabled = b
+		}
+		out[strings.TrimSpace(name)] = enabled
 	}
+	return out, nil
 }
 
-func getEnv(key, fallback string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+func (s setting) format(c *Config) string {
+	switch p := s.field(c).(type) {
+	case *string:
+		return *p
+	case *int:
+		return strconv.Itoa(*p)
+	case *time.Duration:
+		return p.String()
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *map[string]bool:
+		pairs := make(map[string]any, len(*p))
+		for k, v := range *p {
+			pairs[k] = v
+		}
+		return formatMap(pairs)
+	default:
+		return fmt.Sprint(p)
 	}
-	return fallback
 }
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Port != "8080" || cfg.LogLevel != "info" {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+	if cfg.ShutdownTimeout != 5*time.Second || cfg.DBMaxOpenConns != 10 || cfg.DBConnMaxLifetime != 30*time.Minute {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	t.Setenv("HTTP_WRITE_TIMEOUT", "45s")
+	t.Setenv("DB_MAX_OPEN_CONNS", "25")
+	t.Setenv("DB_MIN_CONNS", "25")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.WriteTimeout != 45*time.Second || cfg.DBMaxOpenConns != 25 || cfg.DBMinConns != 25 {
+		t.Fatalf("overrides not applied: %+v", cfg)
+	}
+}
+
+func TestLoadReportsEveryInvalidValue(t *testing.T) {
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+
+	_, err := Load(nil)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	for _, want := range []string{"HTTP_READ_TIMEOUT", "DB_MAX_OPEN_CONNS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestValidateCrossFieldConstraints(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "4")
+	t.Setenv("DB_MIN_CONNS", "8")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("DATA_FSYNC", "sometimes")
+	t.Setenv("DATABASE_READ_URL", "postgres://replica/skool")
+	t.Setenv("CACHE_SIZE", "0")
+	t.Setenv("BILLING_PROVIDER", "fake")
+
+	_, err := Load(nil)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	for _, want := range []string{"DB_MIN_CONNS", "LOG_LEVEL", "DATA_FSYNC", "DATABASE_READ_URL", "CACHE_SIZE", "BILLING_WEBHOOK_SECRET"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, `
+port: 9000
+log_level: debug
+http:
+  write_timeout: 10s
+db:
+  max_open_conns: 20
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := Load([]string{"--http-write-timeout", "12s"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Port != "9000" {
+		t.Fatalf("expected port from file, got %s", cfg.Port)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Fatalf("expected env to override file, got %s", cfg.LogLevel)
+	}
+	if cfg.WriteTimeout != 12*time.Second {
+		t.Fatalf("expected flag to override file, got %s", cfg.WriteTimeout)
+	}
+	if cfg.DBMaxOpenConns != 20 {
+		t.Fatalf("expected nested file key, got %d", cfg.DBMaxOpenConns)
+	}
+}
+
+func TestLoadJSONFileRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, path, `{"port": "9000", "prot": "9001"}`)
+
+	_, err := Load([]string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), `unknown key "prot"`) {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestLoadSecretFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database_url")
+	writeFile(t, path, "postgres://skool:s3cret@db:5432/skool\n")
+	t.Setenv("DATABASE_URL_FILE", path)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.DatabaseURL != "postgres://skool:s3cret@db:5432/skool" {
+		t.Fatalf("unexpected database url: %q", cfg.DatabaseURL)
+	}
+
+	redacted := cfg.Redacted()["database_url"]
+	if strings.Contains(redacted, "s3cret") {
+		t.Fatalf("password leaked in redacted config: %s", redacted)
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://other")
+	if _, err := Load(nil); err == nil {
+		t.Fatalf("expected error when both DATABASE_URL and DATABASE_URL_FILE are set")
+	}
+}
+
+func TestRedactedMasksEveryReplicaDSN(t *testing.T) {
+	cfg := Config{DatabaseReadURL: "postgres://a:pw1@h1/db,postgres://b:pw2@h2/db"}
+
+	redacted := cfg.Redacted()["database_read_url"]
+	if strings.Contains(redacted, "pw1") || strings.Contains(redacted, "pw2") {
+		t.Fatalf("replica password leaked in redacted config: %s", redacted)
+	}
+	if want := "postgres://a:xxxxx@h1/db,postgres://b:xxxxx@h2/db"; redacted != want {
+		t.Fatalf("expected %q, got %q", want, redacted)
+	}
+}
+
+func TestRedactedMasksQueryPasswords(t *testing.T) {
+	cases := map[string]string{
+		"postgres://u@h/db?password=secret":                       "postgres://u@h/db?password=xxxxx",
+		"postgres://u:pw@h/db?sslmode=require&sslpassword=secret": "postgres://u:xxxxx@h/db?sslmode=require&sslpassword=xxxxx",
+	}
+	for dsn, want := range cases {
+		cfg := Config{DatabaseURL: dsn}
+		redacted := cfg.Redacted()["database_url"]
+		if strings.Contains(redacted, "secret") {
+			t.Fatalf("query password leaked in redacted config: %s", redacted)
+		}
+		if redacted != want {
+			t.Fatalf("redact(%q): expected %q, got %q", dsn, want, redacted)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
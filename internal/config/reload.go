@@ -0,0 +1,71 @@
+package config
+
+import (
+	"sync"
+)
+
+// Reloader re-reads configuration on demand (typically on SIGHUP) and applies
+// only the settings marked reloadable. Everything else keeps its startup value
+// until the process restarts.
+type Reloader struct {
+	args []string
+
+	mu          sync.Mutex
+	current     Config
+	subscribers []func(old, updated Config)
+}
+
+// NewReloader starts from initial and reloads using the same command-line args
+// that produced it, so flag precedence is preserved across reloads.
+func NewReloader(initial Config, args []string) *Reloader {
+	return &Reloader{args: args, current: initial}
+}
+
+// Current returns the configuration currently in effect.
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe registers fn to be called after each successful reload.
+func (r *Reloader) Subscribe(fn func(old, updated Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Reload loads a fresh configuration and applies its reloadable settings. It
+// returns the keys of non-reloadable settings whose new value was ignored. On
+// error the current configuration is left untouched.
+func (r *Reloader) Reload() (ignored []string, err error) {
+	next, err := Load(r.args)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	old := r.current
+	updated := old
+	for _, s := range settings {
+		val := s.format(&next)
+		if !s.reloadable {
+			if val != s.format(&old) {
+				ignored = append(ignored, s.key)
+			}
+			continue
+		}
+		if err := s.apply(&updated, val); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+	}
+	r.current = updated
+	subscribers := append([]func(old, updated Config){}, r.subscribers...)
+	r.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, updated)
+	}
+	return ignored, nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReloaderAppliesOnlyReloadableSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080\nlog_level: info\n")
+	t.Setenv("CONFIG_FILE", path)
+
+	initial, err := Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	r := NewReloader(initial, nil)
+
+	var notified Config
+	r.Subscribe(func(_, updated Config) { notified = updated })
+
+	writeFile(t, path, `
+port: 9090
+log_level: debug
+rate_limit:
+  rps: 50
+features:
+  comments: true
+  reactions: false
+`)
+	ignored, err := r.Reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !reflect.DeepEqual(ignored, []string{"port"}) {
+		t.Fatalf("expected port to be ignored, got %v", ignored)
+	}
+
+	cur := r.Current()
+	if cur.Port != "8080" {
+		t.Fatalf("port must not change on reload, got %s", cur.Port)
+	}
+	if cur.LogLevel != "debug" || cur.RateLimitRPS != 50 {
+		t.Fatalf("reloadable settings not applied: %+v", cur)
+	}
+	if !reflect.DeepEqual(cur.Features, map[string]bool{"comments": true, "reactions": false}) {
+		t.Fatalf("unexpected features: %v", cur.Features)
+	}
+	if notified.LogLevel != "debug" {
+		t.Fatalf("subscriber not notified")
+	}
+
+	// Invalid config keeps the current settings.
+	writeFile(t, path, "log_level: chatty\n")
+	if _, err := r.Reload(); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if r.Current().LogLevel != "debug" {
+		t.Fatalf("failed reload must not change config")
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// inviteCodeBytes is the entropy of an invite code, which is all that
+// guards a hidden community.
+const inviteCodeBytes = 16
+
+// validateVisibility accepts the known visibilities, and empty for the default.
+func validateVisibility(v Visibility) error {
+	switch v {
+	case "", VisibilityPublic, VisibilityPrivate, VisibilityHidden:
+		return nil
+	}
+	return fmt.Errorf("visibility must be public, private or hidden")
+}
+
+// visibilityOrDefault returns v, or VisibilityPublic when it is empty.
+func visibilityOrDefault(v Visibility) Visibility {
+	if v == "" {
+		return VisibilityPublic
+	}
+	return v
+}
+
+func validateInvite(input InviteInput) error {
+	if input.MaxUses < 0 {
+		return fmt.Errorf("maxUses must not be negative")
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expiresAt must be in the future")
+	}
+	return nil
+}
+
+func validateDecision(status JoinRequestStatus) error {
+	if status != JoinRequestApproved && status != JoinRequestRejected {
+		return fmt.Errorf("status must be approved or rejected")
+	}
+	return nil
+}
+
+// newInvite builds an invite for communityID with a fresh random code.
+// Timestamps are truncated to microseconds, the resolution of Postgres, so
+// every store returns the values later reads see.
+func newInvite(communityID string, input InviteInput) Invite {
+	b := make([]byte, inviteCodeBytes)
+	// crypto/rand.Read never returns an error.
+	_, _ = rand.Read(b)
+	invite := Invite{
+		Code:        base64.RawURLEncoding.EncodeToString(b),
+		CommunityID: communityID,
+		MaxUses:     input.MaxUses,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if input.ExpiresAt != nil {
+		expires := input.ExpiresAt.UTC().Truncate(time.Microsecond)
+		invite.ExpiresAt = &expires
+	}
+	return invite
+}
+
+// usable returns ErrInviteExpired once the invite has expired or been used
+// up.
+func (i Invite) usable(now time.Time) error {
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return ErrInviteExpired
+	}
+	if i.MaxUses > 0 && i.Uses >= i.MaxUses {
+		return ErrInviteExpired
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/hcuri/skool-mvp-app/internal/metrics"
+)
+
+// CacheOptions bounds a CachingStore.
+type CacheOptions struct {
+	// TTL is how long a cached list is served. Writes through the store
+	// invalidate immediately; TTL bounds staleness from writes made elsewhere,
+	// such as by other API replicas.
+	TTL time.Duration
+	// Size is the maximum number of cached lists; the least recently used is
+	// evicted first.
+	Size int
+}
+
+// DefaultCacheOptions returns the cache settings used when none are configured.
+func DefaultCacheOptions() CacheOptions {
+	return CacheOptions{TTL: 5 * time.Second, Size: 1000}
+}
+
+const communitiesCacheKey = "communities"
+
+func postsCacheKey(communityID string) string {
+	return "posts:" + communityID
+}
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// CachingStore wraps a Store and caches the community list and per-community
+// post lists. Concurrent misses for the same list share one load, and
+// creating or deleting a community or post invalidates the affected lists.
+// Errors are never cached.
+type CachingStore struct {
+	next  Store
+	opts  CacheOptions
+	now   func() time.Time
+	group singleflight.Group
+
+	mu  sync.Mutex
+	lru *simplelru.LRU[string, cacheEntry]
+	// gen counts invalidations. A load only fills the cache if no
+	// invalidation happened while it ran, so it cannot resurrect stale data.
+	gen uint64
+}
+
+// NewCachingStore decorates next with a read-through cache.
+func NewCachingStore(next Store, opts CacheOptions) (*CachingStore, error) {
+	if opts.TTL <= 0 {
+		return nil, fmt.Errorf("cache ttl must be positive, got %s", opts.TTL)
+	}
+	lru, err := simplelru.NewLRU[string, cacheEntry](opts.Size, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cache size: %w", err)
+	}
+	return &CachingStore{next: next, opts: opts, now: time.Now, lru: lru}, nil
+}
+
+// Unwrap returns the decorated store.
+func (s *CachingStore) Unwrap() Store {
+	return s.next
+}
+
+// cachedList serves key from the cache or loads it with load, sharing the
+// load between concurrent callers. Callers get their own copy of the slice.
+func cachedList[T any](ctx context.Context, s *CachingStore, cache, key string, load func(context.Context) ([]T, error)) ([]T, error) {
+	s.mu.Lock()
+	entry, ok := s.lru.Get(key)
+	if ok && !s.now().Before(entry.expires) {
+		s.lru.Remove(key)
+		ok = false
+	}
+	gen := s.gen
+	s.mu.Unlock()
+
+	metrics.ObserveCache(cache, ok)
+	if ok {
+		return slices.Clone(entry.value.([]T)), nil
+	}
+
+	// Callers that arrive after an invalidation start a fresh load rather
+	// than joining one that may have read the old data.
+	v, err, _ := s.group.Do(fmt.Sprintf("%s@%d", key, gen), func() (any, error) {
+		// The load is shared, so one caller giving up must not fail the rest.
+		items, err := load(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		if s.gen == gen {
+			s.lru.Add(key, cacheEntry{value: items, expires: s.now().Add(s.opts.TTL)})
+		}
+		s.mu.Unlock()
+		return items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(v.([]T)), nil
+}
+
+func (s *CachingStore) invalidate(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	for _, key := range keys {
+		s.lru.Remove(key)
+	}
+}
+
+func (s *CachingStore) ListCommunities(ctx context.Context) ([]Community, error) {
+	return cachedList(ctx, s, "communities", communitiesCacheKey, s.next.ListCommunities)
+}
+
+func (s *CachingStore) GetCommunity(ctx context.Context, communityID string) (CommunityDetail, error) {
+	return s.next.GetCommunity(ctx, communityID)
+}
+
+func (s *CachingStore) CreateCommunity(ctx context.Context, input CommunityInput) (Community, error) {
+	community, err := s.next.CreateCommunity(ctx, input)
+	if err == nil {
+		s.invalidate(communitiesCacheKey)
+	}
+	return community, err
+}
+
+func (s *CachingStore) GetCommunityBySlug(ctx context.Context, slug string) (Community, error) {
+	return s.next.GetCommunityBySlug(ctx, slug)
+}
+
+func (s *CachingStore) UpdateCommunity(ctx context.Context, communityID string, input CommunityInput) (Community, error) {
+	community, err := s.next.UpdateCommunity(ctx, communityID, input)
+	if err == nil {
+		s.invalidate(communitiesCacheKey)
+	}
+	return community, err
+}
+
+func (s *CachingStore) DeleteCommunity(ctx context.Context, communityID string) error {
+	err := s.next.DeleteCommunity(ctx, communityID)
+	if err == nil {
+		s.invalidate(communitiesCacheKey, postsCacheKey(communityID))
+	}
+	return err
+}
+
+func (s *CachingStore) ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error) {
+	return cachedList(ctx, s, "posts", postsCacheKey(communityID), func(ctx context.Context) ([]Post, error) {
+		return s.next.ListPostsByCommunity(ctx, communityID)
+	})
+}
+
+func (s *CachingStore) GetPost(ctx context.Context, communityID, postID string) (Post, error) {
+	return s.next.GetPost(ctx, communityID, postID)
+}
+
+func (s *CachingStore) CreatePost(ctx context.Context, communityID string, input PostInput) (Post, error) {
+	post, err := s.next.CreatePost(ctx, communityID, input)
+	if err == nil {
+		s.invalidate(postsCacheKey(communityID))
+	}
+	return post, err
+}
+
+func (s *CachingStore) DeletePost(ctx context.Context, communityID, postID string) error {
+	err := s.next.DeletePost(ctx, communityID, postID)
+	if err == nil {
+		s.invalidate(postsCacheKey(communityID))
+	}
+	return err
+}
+
+func (s *CachingStore) Stats(ctx context.Context) (Stats, error) {
+	return s.next.Stats(ctx)
+}
+
+func (s *CachingStore) ListUsers(ctx context.Context) ([]User, error) {
+	return s.next.ListUsers(ctx)
+}
+
+func (s *CachingStore) CreateUser(ctx context.Context, input UserInput) (User, error) {
+	return s.next.CreateUser(ctx, input)
+}
+
+func (s *CachingStore) AddMember(ctx context.Context, communityID, userID string) error {
+	return s.next.AddMember(ctx, communityID, userID)
+}
+
+func (s *CachingStore) ListMembers(ctx context.Context, communityID string) ([]User, error) {
+	return s.next.ListMembers(ctx, communityID)
+}
+
+func (s *CachingStore) GetMembership(ctx context.Context, communityID, userID string) (Membership, error) {
+	return s.next.GetMembership(ctx, communityID, userID)
+}
+
+func (s *CachingStore) ListMemberships(ctx context.Context, userID string) ([]Membership, error) {
+	return s.next.ListMemberships(ctx, userID)
+}
+
+func (s *CachingStore) CreateInvite(ctx context.Context, communityID string, input InviteInput) (Invite, error) {
+	return s.next.CreateInvite(ctx, communityID, input)
+}
+
+func (s *CachingStore) RedeemInvite(ctx context.Context, code, userID string) (Membership, error) {
+	return s.next.RedeemInvite(ctx, code, userID)
+}
+
+func (s *CachingStore) CreateJoinRequest(ctx context.Context, communityID, userID string) (JoinRequest, error) {
+	return s.next.CreateJoinRequest(ctx, communityID, userID)
+}
+
+func (s *CachingStore) ListJoinRequests(ctx context.Context, communityID string) ([]JoinRequest, error) {
+	return s.next.ListJoinRequests(ctx, communityID)
+}
+
+func (s *CachingStore) DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (JoinRequest, error) {
+	return s.next.DecideJoinRequest(ctx, communityID, requestID, status)
+}
+
+func (s *CachingStore) CreatePlan(ctx context.Context, communityID string, input PlanInput) (Plan, error) {
+	return s.next.CreatePlan(ctx, communityID, input)
+}
+
+func (s *CachingStore) ListPlans(ctx context.Context, communityID string) ([]Plan, error) {
+	return s.next.ListPlans(ctx, communityID)
+}
+
+func (s *CachingStore) GetPlan(ctx context.Context, communityID, planID string) (Plan, error) {
+	return s.next.GetPlan(ctx, communityID, planID)
+}
+
+func (s *CachingStore) SetSubscription(ctx context.Context, communityID, userID string, sub Subscription) (Membership, error) {
+	return s.next.SetSubscription(ctx, communityID, userID, sub)
+}
+
+func (s *CachingStore) UpdateSubscription(ctx context.Context, subscriptionID string, update SubscriptionUpdate) (Membership, error) {
+	return s.next.UpdateSubscription(ctx, subscriptionID, update)
+}
+
+func (s *CachingStore) ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	return s.next.ListFeatureFlags(ctx)
+}
+
+func (s *CachingStore) GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error) {
+	return s.next.GetFeatureFlag(ctx, name)
+}
+
+func (s *CachingStore) UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (FeatureFlag, error) {
+	return s.next.UpsertFeatureFlag(ctx, name, input)
+}
+
+func (s *CachingStore) DeleteFeatureFlag(ctx context.Context, name string) error {
+	return s.next.DeleteFeatureFlag(ctx, name)
+}
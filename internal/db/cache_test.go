@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore counts list loads. If release is set, community loads read
+// the list, signal started and then wait for release before returning.
+type countingStore struct {
+	Store
+	communityLoads atomic.Int32
+	postLoads      atomic.Int32
+	started        chan struct{}
+	release        chan struct{}
+}
+
+func (s *countingStore) ListCommunities(ctx context.Context) ([]Community, error) {
+	s.communityLoads.Add(1)
+	communities, err := s.Store.ListCommunities(ctx)
+	if s.release != nil {
+		s.started <- struct{}{}
+		<-s.release
+	}
+	return communities, err
+}
+
+func (s *countingStore) ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error) {
+	s.postLoads.Add(1)
+	return s.Store.ListPostsByCommunity(ctx, communityID)
+}
+
+func newTestCache(t *testing.T, next Store) *CachingStore {
+	t.Helper()
+	store, err := NewCachingStore(next, CacheOptions{TTL: time.Minute, Size: 10})
+	if err != nil {
+		t.Fatalf("new caching store: %v", err)
+	}
+	return store
+}
+
+func TestCachingStoreServesHitsAndInvalidatesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	next := &countingStore{Store: NewInMemoryStore()}
+	store := newTestCache(t, next)
+
+	community, err := store.CreateCommunity(ctx, CommunityInput{Name: "Go"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	for range 3 {
+		if _, err := store.ListCommunities(ctx); err != nil {
+			t.Fatalf("list communities: %v", err)
+		}
+		if _, err := store.ListPostsByCommunity(ctx, community.ID); err != nil {
+			t.Fatalf("list posts: %v", err)
+		}
+	}
+	if got := next.communityLoads.Load(); got != 1 {
+		t.Fatalf("expected 1 community load, got %d", got)
+	}
+	if got := next.postLoads.Load(); got != 1 {
+		t.Fatalf("expected 1 post load, got %d", got)
+	}
+
+	post, err := store.CreatePost(ctx, community.ID, PostInput{Title: "Hello", Content: "World"})
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	posts, err := store.ListPostsByCommunity(ctx, community.ID)
+	if err != nil || len(posts) != 1 || posts[0].ID != post.ID {
+		t.Fatalf("expected new post after invalidation, got %+v (err %v)", posts, err)
+	}
+
+	if err := store.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	communities, err := store.ListCommunities(ctx)
+	if err != nil || len(communities) != 0 {
+		t.Fatalf("expected no communities after delete, got %+v (err %v)", communities, err)
+	}
+	if _, err := store.ListPostsByCommunity(ctx, community.ID); err != ErrCommunityNotFound {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func TestCachingStoreExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	next := &countingStore{Store: NewInMemoryStore()}
+	store := newTestCache(t, next)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	store.ListCommunities(ctx)
+	now = now.Add(time.Minute)
+	store.ListCommunities(ctx)
+	if got := next.communityLoads.Load(); got != 2 {
+		t.Fatalf("expected reload after TTL, got %d loads", got)
+	}
+}
+
+func TestCachingStoreReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	store := newTestCache(t, NewInMemoryStore())
+	if _, err := store.CreateCommunity(ctx, CommunityInput{Name: "Go"}); err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+
+	first, _ := store.ListCommunities(ctx)
+	first[0].Name = "mutated"
+	second, _ := store.ListCommunities(ctx)
+	if second[0].Name != "Go" {
+		t.Fatalf("cached list was mutated through a returned slice: %+v", second)
+	}
+}
+
+func TestCachingStoreSharesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	next := &countingStore{
+		Store:   NewInMemoryStore(),
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	store := newTestCache(t, next)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.ListCommunities(ctx); err != nil {
+				t.Errorf("list communities: %v", err)
+			}
+		}()
+	}
+	<-next.started
+	// Give the other callers time to join the in-flight load.
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	if got := next.communityLoads.Load(); got != 1 {
+		t.Fatalf("expected 1 shared load, got %d", got)
+	}
+}
+
+func TestCachingStoreDiscardsFillRacingAnInvalidation(t *testing.T) {
+	ctx := context.Background()
+	next := &countingStore{
+		Store:   NewInMemoryStore(),
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	store := newTestCache(t, next)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.ListCommunities(ctx)
+	}()
+	<-next.started
+	if _, err := store.CreateCommunity(ctx, CommunityInput{Name: "Go"}); err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	close(next.release)
+	<-done
+
+	// The in-flight load read the list before the write; it must not be
+	// cached.
+	communities, err := store.ListCommunities(ctx)
+	if err != nil || len(communities) != 1 {
+		t.Fatalf("expected the created community, got %+v (err %v)", communities, err)
+	}
+}
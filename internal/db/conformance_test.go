@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/db/dbtest"
+)
+
+func TestInMemoryStoreConformance(t *testing.T) {
+	dbtest.Run(t, func(*testing.T) db.Store { return db.NewInMemoryStore() })
+}
+
+func TestInstrumentedStoreConformance(t *testing.T) {
+	dbtest.Run(t, func(*testing.T) db.Store { return db.NewInstrumentedStore(db.NewInMemoryStore()) })
+}
+
+func TestCachingStoreConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store {
+		store, err := db.NewCachingStore(db.NewInMemoryStore(), db.DefaultCacheOptions())
+		if err != nil {
+			t.Fatalf("new caching store: %v", err)
+		}
+		return store
+	})
+}
+
+func TestFileStoreConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store {
+		store, err := db.NewFileStore(t.TempDir(), db.DefaultFileStoreOptions(), zaptest.NewLogger(t))
+		if err != nil {
+			t.Fatalf("open file store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store {
+		dsn := db.SQLiteScheme + filepath.Join(t.TempDir(), "skool.db")
+		store, err := db.NewSQLiteStore(context.Background(), dsn, zaptest.NewLogger(t))
+		if err != nil {
+			t.Fatalf("open sqlite store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+// TestPostgresStoreConformance runs against the database in TEST_DATABASE_URL
+// (see `make test-postgres`), emptying its tables before every subtest.
+func TestPostgresStoreConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	dbtest.Run(t, func(t *testing.T) db.Store {
+		ctx := context.Background()
+		store, err := db.NewPostgresStore(ctx, dsn, db.DefaultPoolConfig(), zaptest.NewLogger(t))
+		if err != nil {
+			t.Fatalf("open postgres store: %v", err)
+		}
+		t.Cleanup(func() { store.(*db.PostgresStore).Close() })
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			t.Fatalf("connect postgres: %v", err)
+		}
+		defer conn.Close(ctx)
+		if _, err := conn.Exec(ctx, `TRUNCATE communities, posts, users, community_memberships, billing_events, feature_flags CASCADE`); err != nil {
+			t.Fatalf("truncate tables: %v", err)
+		}
+		return store
+	})
+}
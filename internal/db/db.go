@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,31 +16,138 @@ var (
 	ErrCommunityNotFound = errors.New("community not found")
 	// ErrPostNotFound indicates the requested post does not exist.
 	ErrPostNotFound = errors.New("post not found")
+	// ErrFeatureFlagNotFound indicates the requested feature flag does not exist.
+	ErrFeatureFlagNotFound = errors.New("feature flag not found")
+	// ErrUserNotFound indicates the requested user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSlugTaken indicates the requested slug belongs to another community,
+	// now or in its redirect history.
+	ErrSlugTaken = errors.New("slug already taken")
+	// ErrNotMember indicates the user does not belong to the community.
+	ErrNotMember = errors.New("not a member")
+	// ErrInviteNotFound indicates the invite code does not exist.
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrInviteExpired indicates the invite has expired or reached its
+	// usage limit.
+	ErrInviteExpired = errors.New("invite expired or used up")
+	// ErrJoinRequestNotFound indicates the join request does not exist.
+	ErrJoinRequestNotFound = errors.New("join request not found")
+	// ErrJoinRequestDecided indicates the join request was already approved
+	// or rejected.
+	ErrJoinRequestDecided = errors.New("join request already decided")
+	// ErrPlanNotFound indicates the plan does not exist in the community.
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrSubscriptionNotFound indicates no membership holds the
+	// subscription.
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionCanceled indicates an update would revive a canceled
+	// subscription. Resubscribing starts a new one instead.
+	ErrSubscriptionCanceled = errors.New("subscription canceled")
+	// ErrDuplicateEvent indicates the billing event was already applied.
+	ErrDuplicateEvent = errors.New("billing event already applied")
 )
 
 // Store defines the persistence contract for the application.
 type Store interface {
 	ListCommunities(ctx context.Context) ([]Community, error)
+	GetCommunity(ctx context.Context, communityID string) (CommunityDetail, error)
+	GetCommunityBySlug(ctx context.Context, slug string) (Community, error)
 	CreateCommunity(ctx context.Context, input CommunityInput) (Community, error)
+	UpdateCommunity(ctx context.Context, communityID string, input CommunityInput) (Community, error)
 	DeleteCommunity(ctx context.Context, communityID string) error
 	ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error)
+	GetPost(ctx context.Context, communityID, postID string) (Post, error)
 	CreatePost(ctx context.Context, communityID string, input PostInput) (Post, error)
 	DeletePost(ctx context.Context, communityID, postID string) error
+	Stats(ctx context.Context) (Stats, error)
+
+	ListUsers(ctx context.Context) ([]User, error)
+	CreateUser(ctx context.Context, input UserInput) (User, error)
+	AddMember(ctx context.Context, communityID, userID string) error
+	ListMembers(ctx context.Context, communityID string) ([]User, error)
+	GetMembership(ctx context.Context, communityID, userID string) (Membership, error)
+	ListMemberships(ctx context.Context, userID string) ([]Membership, error)
+
+	CreateInvite(ctx context.Context, communityID string, input InviteInput) (Invite, error)
+	RedeemInvite(ctx context.Context, code, userID string) (Membership, error)
+	CreateJoinRequest(ctx context.Context, communityID, userID string) (JoinRequest, error)
+	ListJoinRequests(ctx context.Context, communityID string) ([]JoinRequest, error)
+	DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (JoinRequest, error)
+
+	CreatePlan(ctx context.Context, communityID string, input PlanInput) (Plan, error)
+	ListPlans(ctx context.Context, communityID string) ([]Plan, error)
+	GetPlan(ctx context.Context, communityID, planID string) (Plan, error)
+	// SetSubscription records sub on userID's membership, adding the user as
+	// a member first if needed.
+	SetSubscription(ctx context.Context, communityID, userID string, sub Subscription) (Membership, error)
+	// UpdateSubscription applies a status change reported by the billing
+	// provider. It returns ErrDuplicateEvent for an event it already applied
+	// and ErrSubscriptionCanceled when the subscription is canceled, so
+	// replayed and late events cannot undo a cancellation.
+	UpdateSubscription(ctx context.Context, subscriptionID string, update SubscriptionUpdate) (Membership, error)
+
+	ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error)
+	GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error)
+	UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (FeatureFlag, error)
+	DeleteFeatureFlag(ctx context.Context, name string) error
+}
+
+// HealthChecker is implemented by stores that depend on an external service and
+// can verify that it is reachable.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
 }
 
+// HealthCheckerFor returns the HealthChecker behind store, looking through any
+// decorators that expose Unwrap.
+func HealthCheckerFor(store Store) (HealthChecker, bool) {
+	for store != nil {
+		if hc, ok := store.(HealthChecker); ok {
+			return hc, true
+		}
+		u, ok := store.(interface{ Unwrap() Store })
+		if !ok {
+			break
+		}
+		store = u.Unwrap()
+	}
+	return nil, false
+}
+
+// ActiveMemberWindow is how recently a member must have posted to count as active.
+const ActiveMemberWindow = 30 * 24 * time.Hour
+
 // InMemoryStore is a simple, concurrency-safe store backed by in-memory maps.
 type InMemoryStore struct {
 	mu             sync.RWMutex
 	communities    map[string]Community
 	communityOrder []string
+	slugs          map[string]string // current and former slugs to community ID
 	posts          map[string][]Post
+	users          map[string]User
+	members        map[string]map[string]Membership
+	invites        map[string]Invite
+	joinRequests   map[string]JoinRequest
+	plans          map[string]Plan
+	subscriptions  map[string]Membership // subscription ID to holder
+	billingEvents  map[string]struct{}   // IDs of applied billing events
+	flags          map[string]FeatureFlag
 }
 
 // NewInMemoryStore initializes an empty in-memory store.
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
-		communities: make(map[string]Community),
-		posts:       make(map[string][]Post),
+		communities:   make(map[string]Community),
+		slugs:         make(map[string]string),
+		posts:         make(map[string][]Post),
+		users:         make(map[string]User),
+		members:       make(map[string]map[string]Membership),
+		invites:       make(map[string]Invite),
+		joinRequests:  make(map[string]JoinRequest),
+		plans:         make(map[string]Plan),
+		subscriptions: make(map[string]Membership),
+		billingEvents: make(map[string]struct{}),
+		flags:         make(map[string]FeatureFlag),
 	}
 }
 
@@ -51,29 +159,122 @@ func (s *InMemoryStore) ListCommunities(_ context.Context) ([]Community, error)
 	for _, id := range s.communityOrder {
 		communities = append(communities, s.communities[id])
 	}
+	sortCommunities(communities)
 	return communities, nil
 }
 
+func (s *InMemoryStore) GetCommunity(_ context.Context, communityID string) (CommunityDetail, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	community, ok := s.communities[communityID]
+	if !ok {
+		return CommunityDetail{}, ErrCommunityNotFound
+	}
+	detail := CommunityDetail{
+		Community:   community,
+		MemberCount: len(s.members[communityID]),
+		PostCount:   len(s.posts[communityID]),
+	}
+	for _, m := range s.members[communityID] {
+		if m.Role == RoleAdmin {
+			detail.AdminCount++
+		}
+	}
+	for _, plan := range s.plans {
+		if plan.CommunityID == communityID {
+			detail.PlanCount++
+		}
+	}
+	return detail, nil
+}
+
+func (s *InMemoryStore) GetCommunityBySlug(_ context.Context, slug string) (Community, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	community, ok := s.communities[s.slugs[slug]]
+	if !ok {
+		return Community{}, ErrCommunityNotFound
+	}
+	return community, nil
+}
+
 func (s *InMemoryStore) CreateCommunity(_ context.Context, input CommunityInput) (Community, error) {
-	if input.Name == "" {
-		return Community{}, fmt.Errorf("name is required")
+	if err := validateCommunity(input); err != nil {
+		return Community{}, err
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, ok := s.users[input.AdminID]; input.AdminID != "" && !ok {
+		return Community{}, ErrUserNotFound
+	}
+	slug := input.Slug
+	if slug == "" {
+		slug = s.freeSlugLocked(slugify(input.Name))
+	} else if _, taken := s.slugs[slug]; taken {
+		return Community{}, ErrSlugTaken
+	}
+
 	id := uuid.NewString()
 	community := Community{
 		ID:          id,
+		Slug:        slug,
 		Name:        input.Name,
 		Description: input.Description,
+		Visibility:  visibilityOrDefault(input.Visibility),
 	}
 	s.communities[id] = community
 	s.communityOrder = append(s.communityOrder, id)
+	s.slugs[slug] = id
+	if input.AdminID != "" {
+		s.addMemberLocked(id, input.AdminID, RoleAdmin)
+	}
 
 	return community, nil
 }
 
+func (s *InMemoryStore) UpdateCommunity(_ context.Context, communityID string, input CommunityInput) (Community, error) {
+	if err := validateCommunity(input); err != nil {
+		return Community{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	community, ok := s.communities[communityID]
+	if !ok {
+		return Community{}, ErrCommunityNotFound
+	}
+	if input.Slug != "" {
+		// A community may take back one of its own former slugs.
+		if owner, taken := s.slugs[input.Slug]; taken && owner != communityID {
+			return Community{}, ErrSlugTaken
+		}
+		community.Slug = input.Slug
+		s.slugs[input.Slug] = communityID
+	}
+	community.Name = input.Name
+	community.Description = input.Description
+	if input.Visibility != "" {
+		community.Visibility = input.Visibility
+	}
+	s.communities[communityID] = community
+
+	return community, nil
+}
+
+// freeSlugLocked returns the first free slug for base. s.mu must be held.
+func (s *InMemoryStore) freeSlugLocked(base string) string {
+	slug, _ := freeSlug(base, func(slug string) (bool, error) {
+		_, taken := s.slugs[slug]
+		return taken, nil
+	})
+	return slug
+}
+
 func (s *InMemoryStore) DeleteCommunity(_ context.Context, communityID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -84,6 +285,32 @@ func (s *InMemoryStore) DeleteCommunity(_ context.Context, communityID string) e
 
 	delete(s.communities, communityID)
 	delete(s.posts, communityID)
+	for _, m := range s.members[communityID] {
+		if m.Subscription != nil {
+			delete(s.subscriptions, m.Subscription.ID)
+		}
+	}
+	delete(s.members, communityID)
+	for slug, id := range s.slugs {
+		if id == communityID {
+			delete(s.slugs, slug)
+		}
+	}
+	for code, invite := range s.invites {
+		if invite.CommunityID == communityID {
+			delete(s.invites, code)
+		}
+	}
+	for id, req := range s.joinRequests {
+		if req.CommunityID == communityID {
+			delete(s.joinRequests, id)
+		}
+	}
+	for id, plan := range s.plans {
+		if plan.CommunityID == communityID {
+			delete(s.plans, id)
+		}
+	}
 
 	// remove from order slice
 	for i, id := range s.communityOrder {
@@ -106,9 +333,25 @@ func (s *InMemoryStore) ListPostsByCommunity(_ context.Context, communityID stri
 	posts := s.posts[communityID]
 	out := make([]Post, len(posts))
 	copy(out, posts)
+	sortPosts(out)
 	return out, nil
 }
 
+func (s *InMemoryStore) GetPost(_ context.Context, communityID, postID string) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return Post{}, ErrCommunityNotFound
+	}
+	for _, p := range s.posts[communityID] {
+		if p.ID == postID {
+			return p, nil
+		}
+	}
+	return Post{}, ErrPostNotFound
+}
+
 func (s *InMemoryStore) CreatePost(_ context.Context, communityID string, input PostInput) (Post, error) {
 	if input.Title == "" {
 		return Post{}, fmt.Errorf("title is required")
@@ -160,6 +403,480 @@ func (s *InMemoryStore) DeletePost(_ context.Context, communityID, postID string
 	return ErrPostNotFound
 }
 
+func (s *InMemoryStore) Stats(_ context.Context) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	since := time.Now().UTC().Add(-ActiveMemberWindow)
+	authors := make(map[string]struct{})
+	stats := Stats{Communities: len(s.communities)}
+	for _, posts := range s.posts {
+		stats.Posts += len(posts)
+		for _, p := range posts {
+			if p.AuthorID != "" && !p.CreatedAt.Before(since) {
+				authors[p.AuthorID] = struct{}{}
+			}
+		}
+	}
+	stats.ActiveMembers = len(authors)
+	return stats, nil
+}
+
+func (s *InMemoryStore) ListUsers(_ context.Context) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+func (s *InMemoryStore) CreateUser(_ context.Context, input UserInput) (User, error) {
+	if err := validateUser(input); err != nil {
+		return User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := User{ID: newID(), Email: input.Email, Name: input.Name}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *InMemoryStore) AddMember(_ context.Context, communityID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return ErrCommunityNotFound
+	}
+	if _, ok := s.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+	s.addMemberLocked(communityID, userID, RoleMember)
+	return nil
+}
+
+// addMemberLocked adds userID to communityID with role, keeping the role of
+// an existing member. s.mu must be held.
+func (s *InMemoryStore) addMemberLocked(communityID, userID string, role Role) Membership {
+	if existing, ok := s.members[communityID][userID]; ok {
+		return existing
+	}
+	m := Membership{CommunityID: communityID, UserID: userID, Role: role}
+	s.putMembershipLocked(m)
+	return m
+}
+
+// putMembershipLocked stores m as is, indexing its subscription. s.mu must
+// be held.
+func (s *InMemoryStore) putMembershipLocked(m Membership) {
+	if s.members[m.CommunityID] == nil {
+		s.members[m.CommunityID] = make(map[string]Membership)
+	}
+	if old := s.members[m.CommunityID][m.UserID].Subscription; old != nil {
+		delete(s.subscriptions, old.ID)
+	}
+	s.members[m.CommunityID][m.UserID] = m
+	if m.Subscription != nil {
+		s.subscriptions[m.Subscription.ID] = m
+	}
+}
+
+func (s *InMemoryStore) ListMembers(_ context.Context, communityID string) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return nil, ErrCommunityNotFound
+	}
+	users := make([]User, 0, len(s.members[communityID]))
+	for id := range s.members[communityID] {
+		users = append(users, s.users[id])
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+func (s *InMemoryStore) GetMembership(_ context.Context, communityID, userID string) (Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return Membership{}, ErrCommunityNotFound
+	}
+	m, ok := s.members[communityID][userID]
+	if !ok {
+		return Membership{}, ErrNotMember
+	}
+	return m, nil
+}
+
+func (s *InMemoryStore) ListMemberships(_ context.Context, userID string) ([]Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	memberships := []Membership{}
+	for _, members := range s.members {
+		if m, ok := members[userID]; ok {
+			memberships = append(memberships, m)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool { return memberships[i].CommunityID < memberships[j].CommunityID })
+	return memberships, nil
+}
+
+func (s *InMemoryStore) CreateInvite(_ context.Context, communityID string, input InviteInput) (Invite, error) {
+	if err := validateInvite(input); err != nil {
+		return Invite{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return Invite{}, ErrCommunityNotFound
+	}
+	invite := newInvite(communityID, input)
+	s.invites[invite.Code] = invite
+	return invite, nil
+}
+
+func (s *InMemoryStore) RedeemInvite(_ context.Context, code, userID string) (Membership, error) {
+	membership, _, err := s.redeemInvite(code, userID)
+	return membership, err
+}
+
+// redeemInvite also returns the invite with its use counted, for FileStore to
+// journal. Redeeming an invite to a community the user already belongs to
+// does not count as a use.
+func (s *InMemoryStore) redeemInvite(code, userID string) (Membership, Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.invites[code]
+	if !ok {
+		return Membership{}, Invite{}, ErrInviteNotFound
+	}
+	if err := invite.usable(time.Now()); err != nil {
+		return Membership{}, Invite{}, err
+	}
+	if _, ok := s.users[userID]; !ok {
+		return Membership{}, Invite{}, ErrUserNotFound
+	}
+	if m, ok := s.members[invite.CommunityID][userID]; ok {
+		return m, invite, nil
+	}
+	invite.Uses++
+	s.invites[code] = invite
+	return s.addMemberLocked(invite.CommunityID, userID, RoleMember), invite, nil
+}
+
+func (s *InMemoryStore) CreateJoinRequest(_ context.Context, communityID, userID string) (JoinRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return JoinRequest{}, ErrCommunityNotFound
+	}
+	if _, ok := s.users[userID]; !ok {
+		return JoinRequest{}, ErrUserNotFound
+	}
+	for _, req := range s.joinRequests {
+		if req.CommunityID == communityID && req.UserID == userID && req.Status == JoinRequestPending {
+			return req, nil
+		}
+	}
+	req := JoinRequest{
+		ID:          newID(),
+		CommunityID: communityID,
+		UserID:      userID,
+		Status:      JoinRequestPending,
+		CreatedAt:   time.Now().UTC(),
+	}
+	s.joinRequests[req.ID] = req
+	return req, nil
+}
+
+func (s *InMemoryStore) ListJoinRequests(_ context.Context, communityID string) ([]JoinRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return nil, ErrCommunityNotFound
+	}
+	requests := []JoinRequest{}
+	for _, req := range s.joinRequests {
+		if req.CommunityID == communityID && req.Status == JoinRequestPending {
+			requests = append(requests, req)
+		}
+	}
+	sortJoinRequests(requests)
+	return requests, nil
+}
+
+func (s *InMemoryStore) DecideJoinRequest(_ context.Context, communityID, requestID string, status JoinRequestStatus) (JoinRequest, error) {
+	if err := validateDecision(status); err != nil {
+		return JoinRequest{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return JoinRequest{}, ErrCommunityNotFound
+	}
+	req, ok := s.joinRequests[requestID]
+	if !ok || req.CommunityID != communityID {
+		return JoinRequest{}, ErrJoinRequestNotFound
+	}
+	if req.Status != JoinRequestPending {
+		return JoinRequest{}, ErrJoinRequestDecided
+	}
+	decided := time.Now().UTC()
+	req.Status, req.DecidedAt = status, &decided
+	s.putJoinRequestLocked(req)
+	return req, nil
+}
+
+// putJoinRequestLocked stores req, adding the user as a member when it is
+// approved. s.mu must be held.
+func (s *InMemoryStore) putJoinRequestLocked(req JoinRequest) {
+	s.joinRequests[req.ID] = req
+	if req.Status == JoinRequestApproved {
+		s.addMemberLocked(req.CommunityID, req.UserID, RoleMember)
+	}
+}
+
+func (s *InMemoryStore) CreatePlan(_ context.Context, communityID string, input PlanInput) (Plan, error) {
+	if err := validatePlan(input); err != nil {
+		return Plan{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return Plan{}, ErrCommunityNotFound
+	}
+	plan := newPlan(communityID, input)
+	s.plans[plan.ID] = plan
+	return plan, nil
+}
+
+func (s *InMemoryStore) ListPlans(_ context.Context, communityID string) ([]Plan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return nil, ErrCommunityNotFound
+	}
+	plans := []Plan{}
+	for _, plan := range s.plans {
+		if plan.CommunityID == communityID {
+			plans = append(plans, plan)
+		}
+	}
+	sortPlans(plans)
+	return plans, nil
+}
+
+func (s *InMemoryStore) GetPlan(_ context.Context, communityID, planID string) (Plan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return Plan{}, ErrCommunityNotFound
+	}
+	plan, ok := s.plans[planID]
+	if !ok || plan.CommunityID != communityID {
+		return Plan{}, ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+func (s *InMemoryStore) SetSubscription(_ context.Context, communityID, userID string, sub Subscription) (Membership, error) {
+	if err := validateSubscription(sub); err != nil {
+		return Membership{}, err
+	}
+	sub.CurrentPeriodEnd = truncatePeriodEnd(sub.CurrentPeriodEnd)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.communities[communityID]; !ok {
+		return Membership{}, ErrCommunityNotFound
+	}
+	if plan, ok := s.plans[sub.PlanID]; !ok || plan.CommunityID != communityID {
+		return Membership{}, ErrPlanNotFound
+	}
+	if _, ok := s.users[userID]; !ok {
+		return Membership{}, ErrUserNotFound
+	}
+	m := s.addMemberLocked(communityID, userID, RoleMember)
+	m.Subscription = &sub
+	s.putMembershipLocked(m)
+	return m, nil
+}
+
+func (s *InMemoryStore) UpdateSubscription(_ context.Context, subscriptionID string, update SubscriptionUpdate) (Membership, error) {
+	if err := validateSubscriptionStatus(update.Status); err != nil {
+		return Membership{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.subscriptions[subscriptionID]
+	if !ok {
+		return Membership{}, ErrSubscriptionNotFound
+	}
+	if _, ok := s.billingEvents[update.EventID]; ok && update.EventID != "" {
+		return Membership{}, ErrDuplicateEvent
+	}
+	sub, err := applySubscriptionUpdate(*m.Subscription, update)
+	if err != nil {
+		return Membership{}, err
+	}
+	if update.EventID != "" {
+		s.billingEvents[update.EventID] = struct{}{}
+	}
+	m.Subscription = &sub
+	s.putMembershipLocked(m)
+	return m, nil
+}
+
+func (s *InMemoryStore) ListFeatureFlags(_ context.Context) ([]FeatureFlag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flags := make([]FeatureFlag, 0, len(s.flags))
+	for _, f := range s.flags {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+func (s *InMemoryStore) GetFeatureFlag(_ context.Context, name string) (FeatureFlag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flag, ok := s.flags[name]
+	if !ok {
+		return FeatureFlag{}, ErrFeatureFlagNotFound
+	}
+	return flag, nil
+}
+
+func (s *InMemoryStore) UpsertFeatureFlag(_ context.Context, name string, input FeatureFlagInput) (FeatureFlag, error) {
+	if err := validateFeatureFlag(name, input); err != nil {
+		return FeatureFlag{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flag := FeatureFlag{
+		Name:        name,
+		Enabled:     input.Enabled,
+		Percentage:  input.Percentage,
+		Communities: append([]string{}, input.Communities...),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	s.flags[name] = flag
+	return flag, nil
+}
+
+func (s *InMemoryStore) DeleteFeatureFlag(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.flags[name]; !ok {
+		return ErrFeatureFlagNotFound
+	}
+	delete(s.flags, name)
+	return nil
+}
+
+func validateCommunity(input CommunityInput) error {
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.Slug != "" {
+		if err := validateSlug(input.Slug); err != nil {
+			return err
+		}
+	}
+	return validateVisibility(input.Visibility)
+}
+
+func validateUser(input UserInput) error {
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	return nil
+}
+
+// sortCommunities orders communities by name, then ID, matching the SQL stores.
+func sortCommunities(communities []Community) {
+	sort.Slice(communities, func(i, j int) bool {
+		if communities[i].Name != communities[j].Name {
+			return communities[i].Name < communities[j].Name
+		}
+		return communities[i].ID < communities[j].ID
+	})
+}
+
+// sortPosts orders posts newest first, then by descending ID, matching the SQL
+// stores.
+func sortPosts(posts []Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+}
+
+// sortJoinRequests orders join requests oldest first, then by ID, matching
+// the SQL stores.
+func sortJoinRequests(requests []JoinRequest) {
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].CreatedAt.Equal(requests[j].CreatedAt) {
+			return requests[i].CreatedAt.Before(requests[j].CreatedAt)
+		}
+		return requests[i].ID < requests[j].ID
+	})
+}
+
+// sortUsers orders users by name, then ID, matching the Postgres queries.
+func sortUsers(users []User) {
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Name != users[j].Name {
+			return users[i].Name < users[j].Name
+		}
+		return users[i].ID < users[j].ID
+	})
+}
+
+func validateFeatureFlag(name string, input FeatureFlagInput) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.Percentage < 0 || input.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100")
+	}
+	return nil
+}
+
 func newID() string {
 	return uuid.NewString()
 }
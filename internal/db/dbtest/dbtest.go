@@ -0,0 +1,1069 @@
+// Package dbtest is a conformance suite for db.Store implementations. Every
+// store must pass it so handlers behave the same whichever backend is
+// configured.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// Opener returns a new, empty store. It is called once per subtest and should
+// register any cleanup with t.
+type Opener func(t *testing.T) db.Store
+
+// Run exercises open's store against the Store contract: validation, ordering,
+// empty results, error sentinels, cascades and concurrent writers.
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store db.Store)
+	}{
+		{"Communities", testCommunities},
+		{"Posts", testPosts},
+		{"DeletePost", testDeletePost},
+		{"GetCommunity", testGetCommunity},
+		{"GetPost", testGetPost},
+		{"UpdateCommunity", testUpdateCommunity},
+		{"Slugs", testSlugs},
+		{"SlugHistory", testSlugHistory},
+		{"Stats", testStats},
+		{"UsersAndMembers", testUsersAndMembers},
+		{"Visibility", testVisibility},
+		{"Memberships", testMemberships},
+		{"Invites", testInvites},
+		{"JoinRequests", testJoinRequests},
+		{"Plans", testPlans},
+		{"Subscriptions", testSubscriptions},
+		{"FeatureFlags", testFeatureFlags},
+		{"EmptyListsAreNotNil", testEmptyLists},
+		{"DeleteCommunityCascades", testDeleteCommunityCascades},
+		{"ConcurrentWrites", testConcurrentWrites},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.fn(t, open(t)) })
+	}
+}
+
+func testCommunities(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	if _, err := store.CreateCommunity(ctx, db.CommunityInput{}); err == nil {
+		t.Fatalf("expected error for missing name")
+	}
+
+	var created []db.Community
+	for _, name := range []string{"Rust", "Go", "Go"} {
+		c, err := store.CreateCommunity(ctx, db.CommunityInput{Name: name, Description: name + " fans"})
+		if err != nil {
+			t.Fatalf("create community: %v", err)
+		}
+		if c.ID == "" || c.Name != name || c.Description != name+" fans" {
+			t.Fatalf("unexpected community %+v", c)
+		}
+		created = append(created, c)
+	}
+
+	// Ordered by name, then ID.
+	want := append([]db.Community{}, created...)
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].Name != want[j].Name {
+			return want[i].Name < want[j].Name
+		}
+		return want[i].ID < want[j].ID
+	})
+	list, err := store.ListCommunities(ctx)
+	if err != nil {
+		t.Fatalf("list communities: %v", err)
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Fatalf("expected communities ordered by name then ID:\n got %+v\nwant %+v", list, want)
+	}
+
+	if err := store.DeleteCommunity(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func testPosts(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Tech")
+
+	for _, input := range []db.PostInput{{Content: "no title"}, {Title: "no content"}} {
+		if _, err := store.CreatePost(ctx, community.ID, input); err == nil {
+			t.Fatalf("expected error for %+v", input)
+		}
+	}
+	if _, err := store.CreatePost(ctx, "missing", db.PostInput{Title: "Oops", Content: "No community"}); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	var created []db.Post
+	for i := range 5 {
+		p, err := store.CreatePost(ctx, community.ID, db.PostInput{AuthorID: "user-1", Title: fmt.Sprintf("Post %d", i), Content: "Hello"})
+		if err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+		if p.ID == "" || p.CommunityID != community.ID || p.CreatedAt.IsZero() {
+			t.Fatalf("unexpected post %+v", p)
+		}
+		created = append(created, p)
+	}
+
+	// Newest first, ties broken by descending ID.
+	want := append([]db.Post{}, created...)
+	sort.Slice(want, func(i, j int) bool {
+		if !want[i].CreatedAt.Equal(want[j].CreatedAt) {
+			return want[i].CreatedAt.After(want[j].CreatedAt)
+		}
+		return want[i].ID > want[j].ID
+	})
+	posts, err := store.ListPostsByCommunity(ctx, community.ID)
+	if err != nil {
+		t.Fatalf("list posts: %v", err)
+	}
+	if len(posts) != len(want) {
+		t.Fatalf("expected %d posts, got %d", len(want), len(posts))
+	}
+	for i := range want {
+		got := posts[i]
+		if got.ID != want[i].ID || got.Title != want[i].Title || got.AuthorID != want[i].AuthorID || !got.CreatedAt.Equal(want[i].CreatedAt) {
+			t.Fatalf("post %d: expected %+v, got %+v", i, want[i], got)
+		}
+	}
+
+	if _, err := store.ListPostsByCommunity(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func testDeletePost(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Tech")
+	other := mustCommunity(t, store, "Other")
+	post, err := store.CreatePost(ctx, community.ID, db.PostInput{Title: "t", Content: "c"})
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+
+	if err := store.DeletePost(ctx, "missing", post.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("missing community: expected ErrCommunityNotFound, got %v", err)
+	}
+	if err := store.DeletePost(ctx, other.ID, post.ID); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("wrong community: expected ErrPostNotFound, got %v", err)
+	}
+	if err := store.DeletePost(ctx, community.ID, "missing"); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("missing post: expected ErrPostNotFound, got %v", err)
+	}
+	if err := store.DeletePost(ctx, community.ID, post.ID); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	if err := store.DeletePost(ctx, community.ID, post.ID); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("second delete: expected ErrPostNotFound, got %v", err)
+	}
+}
+
+func testGetCommunity(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Tech")
+	mustCommunity(t, store, "Other")
+
+	got, err := store.GetCommunity(ctx, community.ID)
+	if err != nil {
+		t.Fatalf("get community: %v", err)
+	}
+	if got.Community != community || got.MemberCount != 0 || got.PostCount != 0 {
+		t.Fatalf("unexpected empty community %+v", got)
+	}
+
+	for _, name := range []string{"Ada", "Grace"} {
+		if err := store.AddMember(ctx, community.ID, mustUser(t, store, name).ID); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+	}
+	for i := range 3 {
+		if _, err := store.CreatePost(ctx, community.ID, db.PostInput{Title: fmt.Sprintf("Post %d", i), Content: "c"}); err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+	}
+	got, err = store.GetCommunity(ctx, community.ID)
+	if err != nil {
+		t.Fatalf("get community: %v", err)
+	}
+	if got.MemberCount != 2 || got.PostCount != 3 {
+		t.Fatalf("expected 2 members and 3 posts, got %+v", got)
+	}
+
+	if _, err := store.GetCommunity(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func testGetPost(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Tech")
+	other := mustCommunity(t, store, "Other")
+	post, err := store.CreatePost(ctx, community.ID, db.PostInput{AuthorID: "user-1", Title: "t", Content: "c"})
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+
+	got, err := store.GetPost(ctx, community.ID, post.ID)
+	if err != nil {
+		t.Fatalf("get post: %v", err)
+	}
+	if got.ID != post.ID || got.CommunityID != community.ID || got.AuthorID != post.AuthorID ||
+		got.Title != post.Title || got.Content != post.Content || !got.CreatedAt.Equal(post.CreatedAt) {
+		t.Fatalf("expected %+v, got %+v", post, got)
+	}
+
+	if _, err := store.GetPost(ctx, "missing", post.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("missing community: expected ErrCommunityNotFound, got %v", err)
+	}
+	if _, err := store.GetPost(ctx, other.ID, post.ID); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("wrong community: expected ErrPostNotFound, got %v", err)
+	}
+	if _, err := store.GetPost(ctx, community.ID, "missing"); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("missing post: expected ErrPostNotFound, got %v", err)
+	}
+}
+
+func testUpdateCommunity(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Tech")
+
+	updated, err := store.UpdateCommunity(ctx, community.ID, db.CommunityInput{Name: "Technology", Description: "all things tech"})
+	if err != nil {
+		t.Fatalf("update community: %v", err)
+	}
+	want := db.Community{ID: community.ID, Slug: community.Slug, Name: "Technology", Description: "all things tech", Visibility: db.VisibilityPublic}
+	if updated != want {
+		t.Fatalf("expected %+v with the slug unchanged, got %+v", want, updated)
+	}
+	got, err := store.GetCommunity(ctx, community.ID)
+	if err != nil || got.Community != want {
+		t.Fatalf("get community = %+v, %v; want %+v", got.Community, err, want)
+	}
+
+	if _, err := store.UpdateCommunity(ctx, community.ID, db.CommunityInput{}); err == nil {
+		t.Fatalf("expected error for missing name")
+	}
+	if _, err := store.UpdateCommunity(ctx, "missing", db.CommunityInput{Name: "x"}); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func testSlugs(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	first := mustCommunity(t, store, "Go Fans!")
+	second := mustCommunity(t, store, "go  fans")
+	accented := mustCommunity(t, store, "Café Crème")
+	if first.Slug != "go-fans" || second.Slug != "go-fans-2" || accented.Slug != "cafe-creme" {
+		t.Fatalf("unexpected generated slugs %q, %q, %q", first.Slug, second.Slug, accented.Slug)
+	}
+	if c := mustCommunity(t, store, "!!!"); c.Slug != "community" {
+		t.Fatalf("expected fallback slug, got %q", c.Slug)
+	}
+
+	explicit, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Gophers", Slug: "gophers-hq"})
+	if err != nil || explicit.Slug != "gophers-hq" {
+		t.Fatalf("create with slug = %+v, %v", explicit, err)
+	}
+	if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Copy", Slug: "go-fans"}); !errors.Is(err, db.ErrSlugTaken) {
+		t.Fatalf("expected ErrSlugTaken, got %v", err)
+	}
+	for _, slug := range []string{"Upper", "two--hyphens", "-leading", "spa ce", explicit.ID} {
+		if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Bad", Slug: slug}); err == nil || errors.Is(err, db.ErrSlugTaken) {
+			t.Fatalf("slug %q: expected a validation error, got %v", slug, err)
+		}
+	}
+
+	got, err := store.GetCommunityBySlug(ctx, "go-fans-2")
+	if err != nil || got != second {
+		t.Fatalf("get by slug = %+v, %v; want %+v", got, err, second)
+	}
+	if _, err := store.GetCommunityBySlug(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+	list, err := store.ListCommunities(ctx)
+	if err != nil {
+		t.Fatalf("list communities: %v", err)
+	}
+	for _, c := range list {
+		if c.Slug == "" {
+			t.Fatalf("listed community without a slug: %+v", c)
+		}
+	}
+
+	// Concurrent creates with the same name still get distinct slugs.
+	const writers = 10
+	var wg sync.WaitGroup
+	slugs := make(chan string, writers)
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Popular"})
+			if err != nil {
+				t.Errorf("concurrent create: %v", err)
+				return
+			}
+			slugs <- c.Slug
+		}()
+	}
+	wg.Wait()
+	close(slugs)
+	seen := make(map[string]bool)
+	for slug := range slugs {
+		seen[slug] = true
+	}
+	if len(seen) != writers {
+		t.Fatalf("expected %d distinct slugs, got %v", writers, seen)
+	}
+}
+
+func testSlugHistory(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Go Fans")
+	other := mustCommunity(t, store, "Rust Fans")
+
+	renamed, err := store.UpdateCommunity(ctx, community.ID, db.CommunityInput{Name: "Gophers", Slug: "gophers"})
+	if err != nil || renamed.Slug != "gophers" {
+		t.Fatalf("change slug = %+v, %v", renamed, err)
+	}
+	// The former slug redirects to the community, which reports its new slug.
+	for _, slug := range []string{"go-fans", "gophers"} {
+		got, err := store.GetCommunityBySlug(ctx, slug)
+		if err != nil || got != renamed {
+			t.Fatalf("get by slug %q = %+v, %v; want %+v", slug, got, err, renamed)
+		}
+	}
+
+	// Former slugs stay reserved for the community that had them.
+	if _, err := store.UpdateCommunity(ctx, other.ID, db.CommunityInput{Name: other.Name, Slug: "go-fans"}); !errors.Is(err, db.ErrSlugTaken) {
+		t.Fatalf("expected ErrSlugTaken for another community's former slug, got %v", err)
+	}
+	if c := mustCommunity(t, store, "Go Fans"); c.Slug != "go-fans-2" {
+		t.Fatalf("expected a generated slug to skip former slugs, got %q", c.Slug)
+	}
+	reclaimed, err := store.UpdateCommunity(ctx, community.ID, db.CommunityInput{Name: "Go Fans", Slug: "go-fans"})
+	if err != nil || reclaimed.Slug != "go-fans" {
+		t.Fatalf("reclaim former slug = %+v, %v", reclaimed, err)
+	}
+	if got, err := store.GetCommunityBySlug(ctx, "gophers"); err != nil || got.ID != community.ID || got.Slug != "go-fans" {
+		t.Fatalf("get by slug %q = %+v, %v", "gophers", got, err)
+	}
+
+	// Deleting the community frees every slug it had.
+	if err := store.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	for _, slug := range []string{"go-fans", "gophers"} {
+		if _, err := store.GetCommunityBySlug(ctx, slug); !errors.Is(err, db.ErrCommunityNotFound) {
+			t.Fatalf("slug %q after delete: expected ErrCommunityNotFound, got %v", slug, err)
+		}
+	}
+	if c, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "New", Slug: "gophers"}); err != nil || c.Slug != "gophers" {
+		t.Fatalf("reuse freed slug = %+v, %v", c, err)
+	}
+}
+
+func testStats(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Tech")
+	for _, author := range []string{"user-1", "user-1", "user-2", ""} {
+		if _, err := store.CreatePost(ctx, community.ID, db.PostInput{AuthorID: author, Title: "t", Content: "c"}); err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	want := db.Stats{Communities: 1, Posts: 4, ActiveMembers: 2}
+	if stats != want {
+		t.Fatalf("expected %+v, got %+v", want, stats)
+	}
+}
+
+func testUsersAndMembers(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	for _, input := range []db.UserInput{{Name: "Ann"}, {Email: "ann@example.com"}} {
+		if _, err := store.CreateUser(ctx, input); err == nil {
+			t.Fatalf("expected error for %+v", input)
+		}
+	}
+	bob := mustUser(t, store, "Bob")
+	ann := mustUser(t, store, "Ann")
+	users, err := store.ListUsers(ctx)
+	if err != nil || len(users) != 2 || users[0] != ann || users[1] != bob {
+		t.Fatalf("expected users sorted by name, got %+v, %v", users, err)
+	}
+
+	community := mustCommunity(t, store, "Tech")
+	for _, id := range []string{bob.ID, ann.ID, bob.ID} {
+		if err := store.AddMember(ctx, community.ID, id); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+	}
+	if err := store.AddMember(ctx, community.ID, "missing"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := store.AddMember(ctx, "missing", ann.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	members, err := store.ListMembers(ctx, community.ID)
+	if err != nil || !reflect.DeepEqual(members, []db.User{ann, bob}) {
+		t.Fatalf("unexpected members %+v, %v", members, err)
+	}
+	if _, err := store.ListMembers(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func testVisibility(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	if c := mustCommunity(t, store, "Open"); c.Visibility != db.VisibilityPublic {
+		t.Fatalf("expected communities to default to public, got %q", c.Visibility)
+	}
+	if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Odd", Visibility: "secret"}); err == nil {
+		t.Fatalf("expected error for unknown visibility")
+	}
+	hidden, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Hidden", Visibility: db.VisibilityHidden})
+	if err != nil || hidden.Visibility != db.VisibilityHidden {
+		t.Fatalf("create hidden community = %+v, %v", hidden, err)
+	}
+	list, err := store.ListCommunities(ctx)
+	if err != nil || len(list) != 2 || list[0] != hidden {
+		t.Fatalf("expected hidden communities to be listed with their visibility, got %+v, %v", list, err)
+	}
+
+	// Updating without a visibility keeps the current one.
+	updated, err := store.UpdateCommunity(ctx, hidden.ID, db.CommunityInput{Name: "Still hidden"})
+	if err != nil || updated.Visibility != db.VisibilityHidden {
+		t.Fatalf("update without visibility = %+v, %v", updated, err)
+	}
+	updated, err = store.UpdateCommunity(ctx, hidden.ID, db.CommunityInput{Name: "Private", Visibility: db.VisibilityPrivate})
+	if err != nil || updated.Visibility != db.VisibilityPrivate {
+		t.Fatalf("update visibility = %+v, %v", updated, err)
+	}
+	if got, err := store.GetCommunity(ctx, hidden.ID); err != nil || got.Community != updated {
+		t.Fatalf("get community = %+v, %v; want %+v", got.Community, err, updated)
+	}
+	if _, err := store.UpdateCommunity(ctx, hidden.ID, db.CommunityInput{Name: "Odd", Visibility: "secret"}); err == nil {
+		t.Fatalf("expected error for unknown visibility on update")
+	}
+}
+
+func testMemberships(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	admin := mustUser(t, store, "Ada")
+	member := mustUser(t, store, "Bob")
+	stranger := mustUser(t, store, "Cy")
+
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Tech", AdminID: admin.ID})
+	if err != nil {
+		t.Fatalf("create community with admin: %v", err)
+	}
+	if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Nobody", AdminID: "missing"}); !errors.Is(err, db.ErrUserNotFound) {
+		t.Fatalf("unknown admin: expected ErrUserNotFound, got %v", err)
+	}
+	if err := store.AddMember(ctx, community.ID, member.ID); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	// Adding an admin again must not demote them.
+	if err := store.AddMember(ctx, community.ID, admin.ID); err != nil {
+		t.Fatalf("add admin as member: %v", err)
+	}
+
+	m, err := store.GetMembership(ctx, community.ID, admin.ID)
+	if want := (db.Membership{CommunityID: community.ID, UserID: admin.ID, Role: db.RoleAdmin}); err != nil || m != want {
+		t.Fatalf("admin membership = %+v, %v; want %+v", m, err, want)
+	}
+	m, err = store.GetMembership(ctx, community.ID, member.ID)
+	if want := (db.Membership{CommunityID: community.ID, UserID: member.ID, Role: db.RoleMember}); err != nil || m != want {
+		t.Fatalf("member membership = %+v, %v; want %+v", m, err, want)
+	}
+	if _, err := store.GetMembership(ctx, community.ID, stranger.ID); !errors.Is(err, db.ErrNotMember) {
+		t.Fatalf("non-member: expected ErrNotMember, got %v", err)
+	}
+	if _, err := store.GetMembership(ctx, community.ID, "missing"); !errors.Is(err, db.ErrNotMember) {
+		t.Fatalf("unknown user: expected ErrNotMember, got %v", err)
+	}
+	if _, err := store.GetMembership(ctx, "missing", admin.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("unknown community: expected ErrCommunityNotFound, got %v", err)
+	}
+
+	detail, err := store.GetCommunity(ctx, community.ID)
+	if err != nil || detail.MemberCount != 2 || detail.AdminCount != 1 {
+		t.Fatalf("expected 2 members and 1 admin, got %+v, %v", detail, err)
+	}
+
+	other := mustCommunity(t, store, "Other")
+	if err := store.AddMember(ctx, other.ID, admin.ID); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	list, err := store.ListMemberships(ctx, admin.ID)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("expected 2 memberships, got %+v, %v", list, err)
+	}
+	roles := map[string]db.Role{}
+	for _, m := range list {
+		roles[m.CommunityID] = m.Role
+	}
+	if roles[community.ID] != db.RoleAdmin || roles[other.ID] != db.RoleMember {
+		t.Fatalf("unexpected memberships %+v", list)
+	}
+	list, err = store.ListMemberships(ctx, stranger.ID)
+	checkEmpty(t, "memberships", list, err)
+	list, err = store.ListMemberships(ctx, "missing")
+	checkEmpty(t, "memberships of unknown user", list, err)
+}
+
+func testInvites(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Secret")
+	ann := mustUser(t, store, "Ann")
+	bob := mustUser(t, store, "Bob")
+
+	for _, input := range []db.InviteInput{{MaxUses: -1}, {ExpiresAt: ptr(time.Now().Add(-time.Minute))}} {
+		if _, err := store.CreateInvite(ctx, community.ID, input); err == nil {
+			t.Fatalf("expected error for %+v", input)
+		}
+	}
+	if _, err := store.CreateInvite(ctx, "missing", db.InviteInput{}); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	invite, err := store.CreateInvite(ctx, community.ID, db.InviteInput{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	if invite.Code == "" || invite.CommunityID != community.ID || invite.MaxUses != 1 || invite.Uses != 0 || invite.CreatedAt.IsZero() {
+		t.Fatalf("unexpected invite %+v", invite)
+	}
+
+	if _, err := store.RedeemInvite(ctx, "missing", ann.ID); !errors.Is(err, db.ErrInviteNotFound) {
+		t.Fatalf("unknown code: expected ErrInviteNotFound, got %v", err)
+	}
+	if _, err := store.RedeemInvite(ctx, invite.Code, "missing"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Fatalf("unknown user: expected ErrUserNotFound, got %v", err)
+	}
+	m, err := store.RedeemInvite(ctx, invite.Code, ann.ID)
+	if want := (db.Membership{CommunityID: community.ID, UserID: ann.ID, Role: db.RoleMember}); err != nil || m != want {
+		t.Fatalf("redeem = %+v, %v; want %+v", m, err, want)
+	}
+	if _, err := store.RedeemInvite(ctx, invite.Code, bob.ID); !errors.Is(err, db.ErrInviteExpired) {
+		t.Fatalf("used up invite: expected ErrInviteExpired, got %v", err)
+	}
+	if _, err := store.GetMembership(ctx, community.ID, bob.ID); !errors.Is(err, db.ErrNotMember) {
+		t.Fatalf("expected a used up invite not to add a member, got %v", err)
+	}
+
+	// An existing member redeeming an invite does not use it up.
+	shared, err := store.CreateInvite(ctx, community.ID, db.InviteInput{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	for _, id := range []string{ann.ID, bob.ID} {
+		if _, err := store.RedeemInvite(ctx, shared.Code, id); err != nil {
+			t.Fatalf("redeem: %v", err)
+		}
+	}
+	if members, err := store.ListMembers(ctx, community.ID); err != nil || len(members) != 2 {
+		t.Fatalf("expected 2 members, got %+v, %v", members, err)
+	}
+
+	expiring, err := store.CreateInvite(ctx, community.ID, db.InviteInput{ExpiresAt: ptr(time.Now().Add(50 * time.Millisecond))})
+	if err != nil {
+		t.Fatalf("create expiring invite: %v", err)
+	}
+	if expiring.ExpiresAt == nil {
+		t.Fatalf("expected expiry to be kept, got %+v", expiring)
+	}
+	time.Sleep(100 * time.Millisecond)
+	carol := mustUser(t, store, "Carol")
+	if _, err := store.RedeemInvite(ctx, expiring.Code, carol.ID); !errors.Is(err, db.ErrInviteExpired) {
+		t.Fatalf("expired invite: expected ErrInviteExpired, got %v", err)
+	}
+
+	if err := store.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if _, err := store.RedeemInvite(ctx, shared.Code, carol.ID); !errors.Is(err, db.ErrInviteNotFound) {
+		t.Fatalf("invite of deleted community: expected ErrInviteNotFound, got %v", err)
+	}
+}
+
+func testJoinRequests(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Private", Visibility: db.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	other := mustCommunity(t, store, "Other")
+	ann := mustUser(t, store, "Ann")
+	bob := mustUser(t, store, "Bob")
+
+	if _, err := store.CreateJoinRequest(ctx, "missing", ann.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+	if _, err := store.CreateJoinRequest(ctx, community.ID, "missing"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	pending, err := store.ListJoinRequests(ctx, community.ID)
+	checkEmpty(t, "join requests", pending, err)
+
+	first, err := store.CreateJoinRequest(ctx, community.ID, ann.ID)
+	if err != nil {
+		t.Fatalf("create join request: %v", err)
+	}
+	if first.ID == "" || first.CommunityID != community.ID || first.UserID != ann.ID || first.Status != db.JoinRequestPending || first.CreatedAt.IsZero() || first.DecidedAt != nil {
+		t.Fatalf("unexpected join request %+v", first)
+	}
+	again, err := store.CreateJoinRequest(ctx, community.ID, ann.ID)
+	if err != nil || !reflect.DeepEqual(again, first) {
+		t.Fatalf("asking twice = %+v, %v; want the pending request %+v", again, err, first)
+	}
+	second, err := store.CreateJoinRequest(ctx, community.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("create join request: %v", err)
+	}
+	pending, err = store.ListJoinRequests(ctx, community.ID)
+	if err != nil || !reflect.DeepEqual(pending, []db.JoinRequest{first, second}) {
+		t.Fatalf("expected pending requests oldest first, got %+v, %v", pending, err)
+	}
+	if _, err := store.ListJoinRequests(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	if _, err := store.DecideJoinRequest(ctx, community.ID, first.ID, db.JoinRequestPending); err == nil {
+		t.Fatalf("expected error deciding pending")
+	}
+	if _, err := store.DecideJoinRequest(ctx, other.ID, first.ID, db.JoinRequestApproved); !errors.Is(err, db.ErrJoinRequestNotFound) {
+		t.Fatalf("wrong community: expected ErrJoinRequestNotFound, got %v", err)
+	}
+	if _, err := store.DecideJoinRequest(ctx, community.ID, "missing", db.JoinRequestApproved); !errors.Is(err, db.ErrJoinRequestNotFound) {
+		t.Fatalf("expected ErrJoinRequestNotFound, got %v", err)
+	}
+	if _, err := store.DecideJoinRequest(ctx, "missing", first.ID, db.JoinRequestApproved); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	approved, err := store.DecideJoinRequest(ctx, community.ID, first.ID, db.JoinRequestApproved)
+	if err != nil || approved.Status != db.JoinRequestApproved || approved.DecidedAt == nil {
+		t.Fatalf("approve = %+v, %v", approved, err)
+	}
+	if m, err := store.GetMembership(ctx, community.ID, ann.ID); err != nil || m.Role != db.RoleMember {
+		t.Fatalf("expected approval to add a member, got %+v, %v", m, err)
+	}
+	if _, err := store.DecideJoinRequest(ctx, community.ID, first.ID, db.JoinRequestRejected); !errors.Is(err, db.ErrJoinRequestDecided) {
+		t.Fatalf("expected ErrJoinRequestDecided, got %v", err)
+	}
+	rejected, err := store.DecideJoinRequest(ctx, community.ID, second.ID, db.JoinRequestRejected)
+	if err != nil || rejected.Status != db.JoinRequestRejected {
+		t.Fatalf("reject = %+v, %v", rejected, err)
+	}
+	if _, err := store.GetMembership(ctx, community.ID, bob.ID); !errors.Is(err, db.ErrNotMember) {
+		t.Fatalf("expected rejection not to add a member, got %v", err)
+	}
+	pending, err = store.ListJoinRequests(ctx, community.ID)
+	checkEmpty(t, "decided join requests", pending, err)
+
+	// A rejected user may ask again.
+	retry, err := store.CreateJoinRequest(ctx, community.ID, bob.ID)
+	if err != nil || retry.ID == second.ID || retry.Status != db.JoinRequestPending {
+		t.Fatalf("ask again = %+v, %v", retry, err)
+	}
+}
+
+func testPlans(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Paid")
+	other := mustCommunity(t, store, "Other")
+
+	for _, input := range []db.PlanInput{
+		{PriceCents: 900, Currency: "usd"},
+		{Name: "Free", Currency: "usd"},
+		{Name: "Odd", PriceCents: 900, Currency: "dollars"},
+		{Name: "Weekly", PriceCents: 900, Currency: "usd", Interval: "week"},
+		{Name: "Long trial", PriceCents: 900, Currency: "usd", TrialDays: 1000},
+	} {
+		if _, err := store.CreatePlan(ctx, community.ID, input); err == nil {
+			t.Fatalf("expected error for %+v", input)
+		}
+	}
+	if _, err := store.CreatePlan(ctx, "missing", db.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd"}); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	yearly, err := store.CreatePlan(ctx, community.ID, db.PlanInput{Name: "Yearly", PriceCents: 9000, Currency: "EUR", Interval: db.PlanYearly})
+	if err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	monthly, err := store.CreatePlan(ctx, community.ID, db.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "eur", TrialDays: 14})
+	if err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	if monthly.ID == "" || monthly.CommunityID != community.ID || monthly.Interval != db.PlanMonthly || monthly.TrialDays != 14 || monthly.CreatedAt.IsZero() {
+		t.Fatalf("unexpected plan %+v", monthly)
+	}
+	if yearly.Currency != "eur" {
+		t.Fatalf("expected the currency to be lower-cased, got %+v", yearly)
+	}
+
+	plans, err := store.ListPlans(ctx, community.ID)
+	if err != nil || !reflect.DeepEqual(plans, []db.Plan{monthly, yearly}) {
+		t.Fatalf("list plans = %+v, %v; want monthly then yearly", plans, err)
+	}
+	plans, err = store.ListPlans(ctx, other.ID)
+	checkEmpty(t, "plans", plans, err)
+	if _, err := store.ListPlans(ctx, "missing"); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+
+	if got, err := store.GetPlan(ctx, community.ID, yearly.ID); err != nil || got != yearly {
+		t.Fatalf("get plan = %+v, %v; want %+v", got, err, yearly)
+	}
+	if _, err := store.GetPlan(ctx, other.ID, yearly.ID); !errors.Is(err, db.ErrPlanNotFound) {
+		t.Fatalf("plan of another community: expected ErrPlanNotFound, got %v", err)
+	}
+	if _, err := store.GetPlan(ctx, community.ID, "missing"); !errors.Is(err, db.ErrPlanNotFound) {
+		t.Fatalf("unknown plan: expected ErrPlanNotFound, got %v", err)
+	}
+	if _, err := store.GetPlan(ctx, "missing", yearly.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("unknown community: expected ErrCommunityNotFound, got %v", err)
+	}
+
+	if detail, err := store.GetCommunity(ctx, community.ID); err != nil || detail.PlanCount != 2 {
+		t.Fatalf("expected 2 plans, got %+v, %v", detail, err)
+	}
+	if err := store.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if _, err := store.GetPlan(ctx, community.ID, yearly.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("plan of deleted community: expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func testSubscriptions(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	admin := mustUser(t, store, "Ada")
+	bob := mustUser(t, store, "Bob")
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Paid", AdminID: admin.ID})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	other := mustCommunity(t, store, "Other")
+	plan, err := store.CreatePlan(ctx, community.ID, db.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+
+	periodEnd := time.Now().Add(30 * 24 * time.Hour)
+	sub := db.Subscription{ID: "sub_bob", PlanID: plan.ID, Status: db.SubscriptionTrialing, CurrentPeriodEnd: &periodEnd}
+	for _, bad := range []db.Subscription{{PlanID: plan.ID, Status: db.SubscriptionActive}, {ID: "sub_x", PlanID: plan.ID, Status: "paused"}} {
+		if _, err := store.SetSubscription(ctx, community.ID, bob.ID, bad); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+	if _, err := store.SetSubscription(ctx, "missing", bob.ID, sub); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("unknown community: expected ErrCommunityNotFound, got %v", err)
+	}
+	if _, err := store.SetSubscription(ctx, other.ID, bob.ID, sub); !errors.Is(err, db.ErrPlanNotFound) {
+		t.Fatalf("plan of another community: expected ErrPlanNotFound, got %v", err)
+	}
+	if _, err := store.SetSubscription(ctx, community.ID, "missing", sub); !errors.Is(err, db.ErrUserNotFound) {
+		t.Fatalf("unknown user: expected ErrUserNotFound, got %v", err)
+	}
+
+	// Subscribing makes a non-member a member.
+	m, err := store.SetSubscription(ctx, community.ID, bob.ID, sub)
+	if err != nil {
+		t.Fatalf("set subscription: %v", err)
+	}
+	want := db.Membership{CommunityID: community.ID, UserID: bob.ID, Role: db.RoleMember, Subscription: &db.Subscription{
+		ID: "sub_bob", PlanID: plan.ID, Status: db.SubscriptionTrialing, CurrentPeriodEnd: ptr(periodEnd.UTC().Truncate(time.Microsecond)),
+	}}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("set subscription = %+v; want %+v", m, want)
+	}
+	if got, err := store.GetMembership(ctx, community.ID, bob.ID); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("get membership = %+v, %v; want %+v", got, err, want)
+	}
+	if !m.Subscription.Paid() {
+		t.Fatalf("expected a trialing subscription to be paid")
+	}
+
+	// Subscribing keeps the role.
+	m, err = store.SetSubscription(ctx, community.ID, admin.ID, db.Subscription{ID: "sub_ada", PlanID: plan.ID, Status: db.SubscriptionActive})
+	if err != nil || m.Role != db.RoleAdmin || m.Subscription == nil || m.Subscription.CurrentPeriodEnd != nil {
+		t.Fatalf("subscribe admin = %+v, %v", m, err)
+	}
+
+	// Status changes keep the period end unless a new one is reported.
+	m, err = store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{Status: db.SubscriptionPastDue})
+	if err != nil || m.UserID != bob.ID || m.Subscription.Status != db.SubscriptionPastDue || !reflect.DeepEqual(m.Subscription.CurrentPeriodEnd, want.Subscription.CurrentPeriodEnd) {
+		t.Fatalf("update subscription = %+v, %v", m, err)
+	}
+	if m.Subscription.Paid() {
+		t.Fatalf("expected a past due subscription not to be paid")
+	}
+	renewed := periodEnd.Add(30 * 24 * time.Hour)
+	m, err = store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{Status: db.SubscriptionActive, CurrentPeriodEnd: &renewed})
+	if err != nil || m.Subscription.Status != db.SubscriptionActive || !m.Subscription.CurrentPeriodEnd.Equal(renewed.Truncate(time.Microsecond)) {
+		t.Fatalf("renew subscription = %+v, %v", m, err)
+	}
+	if got, err := store.GetMembership(ctx, community.ID, bob.ID); err != nil || !reflect.DeepEqual(got, m) {
+		t.Fatalf("get membership = %+v, %v; want %+v", got, err, m)
+	}
+	if _, err := store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{Status: "paused"}); err == nil {
+		t.Fatalf("expected error for an unknown status")
+	}
+	if _, err := store.UpdateSubscription(ctx, "missing", db.SubscriptionUpdate{Status: db.SubscriptionCanceled}); !errors.Is(err, db.ErrSubscriptionNotFound) {
+		t.Fatalf("unknown subscription: expected ErrSubscriptionNotFound, got %v", err)
+	}
+
+	// Each billing event applies once, and canceled is final, so replayed
+	// and late events cannot revive a subscription.
+	m, err = store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{EventID: "evt_cancel", Status: db.SubscriptionCanceled})
+	if err != nil || m.Subscription.Status != db.SubscriptionCanceled {
+		t.Fatalf("cancel subscription = %+v, %v", m, err)
+	}
+	if _, err := store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{EventID: "evt_cancel", Status: db.SubscriptionCanceled}); !errors.Is(err, db.ErrDuplicateEvent) {
+		t.Fatalf("replayed event: expected ErrDuplicateEvent, got %v", err)
+	}
+	if _, err := store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{EventID: "evt_late", Status: db.SubscriptionActive}); !errors.Is(err, db.ErrSubscriptionCanceled) {
+		t.Fatalf("late event: expected ErrSubscriptionCanceled, got %v", err)
+	}
+	if got, err := store.GetMembership(ctx, community.ID, bob.ID); err != nil || got.Subscription.Status != db.SubscriptionCanceled {
+		t.Fatalf("get membership = %+v, %v; want canceled", got, err)
+	}
+	expired := db.Subscription{ID: "sub_old", Status: db.SubscriptionActive, CurrentPeriodEnd: ptr(time.Now().Add(-time.Minute))}
+	if expired.Paid() {
+		t.Fatalf("expected a subscription past its period end not to be paid")
+	}
+
+	// A new subscription replaces the old one, which is no longer found.
+	m, err = store.SetSubscription(ctx, community.ID, bob.ID, db.Subscription{ID: "sub_bob2", PlanID: plan.ID, Status: db.SubscriptionActive})
+	if err != nil || m.Subscription.ID != "sub_bob2" {
+		t.Fatalf("resubscribe = %+v, %v", m, err)
+	}
+	if _, err := store.UpdateSubscription(ctx, "sub_bob", db.SubscriptionUpdate{Status: db.SubscriptionCanceled}); !errors.Is(err, db.ErrSubscriptionNotFound) {
+		t.Fatalf("replaced subscription: expected ErrSubscriptionNotFound, got %v", err)
+	}
+	list, err := store.ListMemberships(ctx, bob.ID)
+	if err != nil || len(list) != 1 || !reflect.DeepEqual(list[0], m) {
+		t.Fatalf("list memberships = %+v, %v; want %+v", list, err, m)
+	}
+
+	if err := store.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if _, err := store.UpdateSubscription(ctx, "sub_bob2", db.SubscriptionUpdate{Status: db.SubscriptionCanceled}); !errors.Is(err, db.ErrSubscriptionNotFound) {
+		t.Fatalf("subscription of deleted community: expected ErrSubscriptionNotFound, got %v", err)
+	}
+}
+
+func testFeatureFlags(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	if _, err := store.GetFeatureFlag(ctx, "beta"); !errors.Is(err, db.ErrFeatureFlagNotFound) {
+		t.Fatalf("expected ErrFeatureFlagNotFound, got %v", err)
+	}
+	if _, err := store.UpsertFeatureFlag(ctx, "beta", db.FeatureFlagInput{Percentage: 101}); err == nil {
+		t.Fatalf("expected error for percentage over 100")
+	}
+
+	if _, err := store.UpsertFeatureFlag(ctx, "beta", db.FeatureFlagInput{Enabled: true, Percentage: 10}); err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	updated, err := store.UpsertFeatureFlag(ctx, "beta", db.FeatureFlagInput{Percentage: 50, Communities: []string{"c1"}})
+	if err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	alpha, err := store.UpsertFeatureFlag(ctx, "alpha", db.FeatureFlagInput{})
+	if err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	if alpha.Communities == nil {
+		t.Fatalf("expected empty, non-nil communities")
+	}
+
+	got, err := store.GetFeatureFlag(ctx, "beta")
+	if err != nil {
+		t.Fatalf("get flag: %v", err)
+	}
+	if got.Enabled || got.Percentage != 50 || !reflect.DeepEqual(got.Communities, []string{"c1"}) || !got.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Fatalf("expected upsert to replace the flag, got %+v", got)
+	}
+
+	flags, err := store.ListFeatureFlags(ctx)
+	if err != nil || len(flags) != 2 || flags[0].Name != "alpha" || flags[1].Name != "beta" {
+		t.Fatalf("expected flags ordered by name, got %+v, %v", flags, err)
+	}
+
+	if err := store.DeleteFeatureFlag(ctx, "beta"); err != nil {
+		t.Fatalf("delete flag: %v", err)
+	}
+	if err := store.DeleteFeatureFlag(ctx, "beta"); !errors.Is(err, db.ErrFeatureFlagNotFound) {
+		t.Fatalf("expected ErrFeatureFlagNotFound, got %v", err)
+	}
+}
+
+func testEmptyLists(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	communities, err := store.ListCommunities(ctx)
+	checkEmpty(t, "communities", communities, err)
+	users, err := store.ListUsers(ctx)
+	checkEmpty(t, "users", users, err)
+	flags, err := store.ListFeatureFlags(ctx)
+	checkEmpty(t, "feature flags", flags, err)
+
+	community := mustCommunity(t, store, "Tech")
+	posts, err := store.ListPostsByCommunity(ctx, community.ID)
+	checkEmpty(t, "posts", posts, err)
+	members, err := store.ListMembers(ctx, community.ID)
+	checkEmpty(t, "members", members, err)
+}
+
+// checkEmpty fails unless list is empty but non-nil, so it encodes as [] in
+// JSON responses.
+func checkEmpty[T any](t *testing.T, what string, list []T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("list %s: %v", what, err)
+	}
+	if list == nil || len(list) != 0 {
+		t.Fatalf("expected empty, non-nil %s, got %#v", what, list)
+	}
+}
+
+func testDeleteCommunityCascades(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	doomed := mustCommunity(t, store, "Doomed")
+	kept := mustCommunity(t, store, "Kept")
+	user := mustUser(t, store, "Ann")
+	for _, c := range []db.Community{doomed, kept} {
+		if err := store.AddMember(ctx, c.ID, user.ID); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+		if _, err := store.CreatePost(ctx, c.ID, db.PostInput{AuthorID: user.ID, Title: "t", Content: "c"}); err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+	}
+
+	if err := store.DeleteCommunity(ctx, doomed.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if err := store.DeleteCommunity(ctx, doomed.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("second delete: expected ErrCommunityNotFound, got %v", err)
+	}
+	if _, err := store.ListPostsByCommunity(ctx, doomed.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound for posts, got %v", err)
+	}
+	if _, err := store.ListMembers(ctx, doomed.ID); !errors.Is(err, db.ErrCommunityNotFound) {
+		t.Fatalf("expected ErrCommunityNotFound for members, got %v", err)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if want := (db.Stats{Communities: 1, Posts: 1, ActiveMembers: 1}); stats != want {
+		t.Fatalf("expected %+v after cascade, got %+v", want, stats)
+	}
+	posts, err := store.ListPostsByCommunity(ctx, kept.ID)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("expected other community's posts to survive, got %+v, %v", posts, err)
+	}
+	members, err := store.ListMembers(ctx, kept.ID)
+	if err != nil || len(members) != 1 {
+		t.Fatalf("expected other community's members to survive, got %+v, %v", members, err)
+	}
+	if users, err := store.ListUsers(ctx); err != nil || len(users) != 1 {
+		t.Fatalf("expected users to survive, got %+v, %v", users, err)
+	}
+}
+
+func testConcurrentWrites(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	community := mustCommunity(t, store, "Busy")
+	user := mustUser(t, store, "Ann")
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.CreatePost(ctx, community.ID, db.PostInput{AuthorID: user.ID, Title: fmt.Sprintf("Post %d", i), Content: "c"}); err != nil {
+				errs <- err
+			}
+			if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: fmt.Sprintf("Community %d", i)}); err != nil {
+				errs <- err
+			}
+			if err := store.AddMember(ctx, community.ID, user.ID); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent write: %v", err)
+	}
+
+	posts, err := store.ListPostsByCommunity(ctx, community.ID)
+	if err != nil {
+		t.Fatalf("list posts: %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, p := range posts {
+		ids[p.ID] = true
+	}
+	if len(posts) != writers || len(ids) != writers {
+		t.Fatalf("expected %d distinct posts, got %d (%d distinct)", writers, len(posts), len(ids))
+	}
+	if communities, err := store.ListCommunities(ctx); err != nil || len(communities) != writers+1 {
+		t.Fatalf("expected %d communities, got %d, %v", writers+1, len(communities), err)
+	}
+	if members, err := store.ListMembers(ctx, community.ID); err != nil || len(members) != 1 {
+		t.Fatalf("expected repeated AddMember to be idempotent, got %+v, %v", members, err)
+	}
+}
+
+func mustCommunity(t *testing.T, store db.Store, name string) db.Community {
+	t.Helper()
+	c, err := store.CreateCommunity(context.Background(), db.CommunityInput{Name: name})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	return c
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func mustUser(t *testing.T, store db.Store, name string) db.User {
+	t.Helper()
+	u, err := store.CreateUser(context.Background(), db.UserInput{Name: name, Email: name + "@example.com"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return u
+}
@@ -0,0 +1,876 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	snapshotFile = "snapshot.db"
+	journalFile  = "journal.log"
+)
+
+// SyncPolicy controls when journal writes are flushed to stable storage.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every write: no acknowledged write is lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs in the background every FileStoreOptions.SyncInterval;
+	// a crash may lose the writes of the last interval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+// FileStoreOptions tunes durability and compaction of a FileStore.
+type FileStoreOptions struct {
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// SnapshotEvery compacts the journal into a snapshot after this many
+	// writes. Zero disables periodic snapshots; Close always takes one.
+	SnapshotEvery int
+}
+
+// DefaultFileStoreOptions returns the options used when none are configured.
+func DefaultFileStoreOptions() FileStoreOptions {
+	return FileStoreOptions{Sync: SyncAlways, SyncInterval: time.Second, SnapshotEvery: 1000}
+}
+
+// FileStore is an InMemoryStore that survives restarts. Every mutation is
+// appended to a checksummed journal; the journal is periodically compacted
+// into a snapshot, and both are replayed on startup.
+//
+// If the journal cannot be written the failed write is rolled back, and the
+// store stops accepting writes and reports the failure through HealthCheck,
+// since the disk can no longer be trusted.
+type FileStore struct {
+	mem    *InMemoryStore
+	dir    string
+	opts   FileStoreOptions
+	logger *zap.Logger
+
+	mu           sync.Mutex
+	journal      journalWriter
+	seq          uint64
+	sinceSnap    int
+	dirty        bool
+	failed       error
+	closed       bool
+	stopSyncLoop chan struct{}
+	syncLoopDone chan struct{}
+}
+
+// journalWriter is the open journal. Tests substitute failing ones.
+type journalWriter interface {
+	io.Writer
+	io.Seeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// snapshotState is the on-disk form of the whole store.
+type snapshotState struct {
+	Seq          uint64            `json:"seq"`
+	Communities  []Community       `json:"communities"`
+	Slugs        map[string]string `json:"slugs"`
+	Posts        map[string][]Post `json:"posts"`
+	Users        []User            `json:"users"`
+	Memberships  []Membership      `json:"memberships"`
+	Invites      []Invite          `json:"invites"`
+	JoinRequests []JoinRequest     `json:"joinRequests"`
+	Plans        []Plan            `json:"plans"`
+	// BillingEvents holds the IDs of applied billing events.
+	BillingEvents []string      `json:"billingEvents,omitempty"`
+	Flags         []FeatureFlag `json:"flags"`
+
+	// Members lists user IDs by community in snapshots written before
+	// members had roles. It is read on restore but no longer written.
+	Members map[string][]string `json:"members,omitempty"`
+}
+
+// NewFileStore opens (or creates) a store in dir and replays its snapshot and
+// journal. A torn final journal write is truncated with a warning; any other
+// damage returns ErrCorruptData rather than starting with partial data.
+func NewFileStore(dir string, opts FileStoreOptions, logger *zap.Logger) (*FileStore, error) {
+	switch opts.Sync {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if opts.SyncInterval <= 0 {
+			return nil, errors.New("file store: sync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("file store: unknown sync policy %q", opts.Sync)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+
+	s := &FileStore{mem: NewInMemoryStore(), dir: dir, opts: opts, logger: logger}
+	if err := s.recover(); err != nil {
+		return nil, fmt.Errorf("file store %s: %w", dir, err)
+	}
+
+	if opts.Sync == SyncInterval {
+		s.stopSyncLoop = make(chan struct{})
+		s.syncLoopDone = make(chan struct{})
+		go s.syncLoop()
+	}
+	return s, nil
+}
+
+// recover loads the snapshot, replays newer journal records and opens the
+// journal for appending.
+func (s *FileStore) recover() error {
+	if err := s.loadSnapshot(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, journalFile)
+	journal, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	records, valid, torn, err := readJournal(journal)
+	if err != nil {
+		journal.Close()
+		return err
+	}
+	if torn {
+		s.logger.Warn("truncating torn write at end of journal", zap.String("file", path), zap.Int64("offset", valid))
+		if err := journal.Truncate(valid); err != nil {
+			journal.Close()
+			return err
+		}
+	}
+	if _, err := journal.Seek(valid, 0); err != nil {
+		journal.Close()
+		return err
+	}
+
+	replayed, err := s.replay(records)
+	if err != nil {
+		journal.Close()
+		return err
+	}
+	s.journal = journal
+	s.sinceSnap = replayed
+	s.logger.Info("file store recovered", zap.String("dir", s.dir), zap.Uint64("seq", s.seq), zap.Int("replayed", replayed))
+	return nil
+}
+
+// loadSnapshot restores the snapshot, if there is one.
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	var state snapshotState
+	if err := decodeFrame(trimNewline(data), &state); err != nil {
+		return fmt.Errorf("%w: snapshot: %v", ErrCorruptData, err)
+	}
+	s.mem.restore(state)
+	s.seq = state.Seq
+	return nil
+}
+
+// replay applies the journal records the snapshot does not cover and
+// returns how many there were.
+func (s *FileStore) replay(records []journalRecord) (int, error) {
+	replayed := 0
+	for _, rec := range records {
+		if rec.Seq <= s.seq {
+			// Already part of the snapshot; the journal was not truncated
+			// before the last shutdown.
+			continue
+		}
+		if err := s.apply(rec); err != nil {
+			return replayed, fmt.Errorf("%w: replay record %d (%s): %v", ErrCorruptData, rec.Seq, rec.Op, err)
+		}
+		s.seq = rec.Seq
+		replayed++
+	}
+	return replayed, nil
+}
+
+// apply replays one journal record onto the in-memory state.
+func (s *FileStore) apply(rec journalRecord) error {
+	ctx := context.Background()
+	mem := s.mem
+	switch rec.Op {
+	case opCreateCommunity:
+		if rec.Community == nil {
+			return errors.New("missing community")
+		}
+		mem.putCommunity(*rec.Community)
+		if rec.Membership != nil {
+			return mem.putMembership(*rec.Membership)
+		}
+		return nil
+	case opUpdateCommunity:
+		if rec.Community == nil {
+			return errors.New("missing community")
+		}
+		if _, err := mem.GetCommunity(ctx, rec.Community.ID); err != nil {
+			return err
+		}
+		mem.putCommunity(*rec.Community)
+		return nil
+	case opDeleteCommunity:
+		return mem.DeleteCommunity(ctx, rec.CommunityID)
+	case opCreatePost:
+		if rec.Post == nil {
+			return errors.New("missing post")
+		}
+		return mem.putPost(*rec.Post)
+	case opDeletePost:
+		return mem.DeletePost(ctx, rec.CommunityID, rec.ID)
+	case opCreateUser:
+		if rec.User == nil {
+			return errors.New("missing user")
+		}
+		mem.putUser(*rec.User)
+		return nil
+	case opAddMember:
+		return mem.AddMember(ctx, rec.CommunityID, rec.ID)
+	case opCreateInvite:
+		if rec.Invite == nil {
+			return errors.New("missing invite")
+		}
+		return mem.putInvite(*rec.Invite)
+	case opRedeemInvite:
+		// The record holds the outcome, since the invite may have expired
+		// by the time it is replayed.
+		if rec.Invite == nil || rec.Membership == nil {
+			return errors.New("missing invite or membership")
+		}
+		if err := mem.putInvite(*rec.Invite); err != nil {
+			return err
+		}
+		return mem.putMembership(*rec.Membership)
+	case opCreateJoinReq, opDecideJoinReq:
+		if rec.JoinRequest == nil {
+			return errors.New("missing join request")
+		}
+		return mem.putJoinRequest(*rec.JoinRequest)
+	case opCreatePlan:
+		if rec.Plan == nil {
+			return errors.New("missing plan")
+		}
+		return mem.putPlan(*rec.Plan)
+	case opSetSubscription:
+		if rec.Membership == nil {
+			return errors.New("missing membership")
+		}
+		return mem.putMembership(*rec.Membership)
+	case opUpdateSubscription:
+		// The record holds the outcome; ID is the billing event's, if any.
+		if rec.Membership == nil {
+			return errors.New("missing membership")
+		}
+		if err := mem.putMembership(*rec.Membership); err != nil {
+			return err
+		}
+		mem.putBillingEvent(rec.ID)
+		return nil
+	case opUpsertFlag:
+		if rec.Flag == nil {
+			return errors.New("missing feature flag")
+		}
+		mem.putFlag(*rec.Flag)
+		return nil
+	case opDeleteFlag:
+		return mem.DeleteFeatureFlag(ctx, rec.ID)
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+}
+
+// mutate runs fn against memory and journals the record it returns. Writes
+// are serialized so the journal order matches the order they were applied.
+func (s *FileStore) mutate(fn func() (journalRecord, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("file store is closed")
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	offset, err := s.journal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	rec, err := fn()
+	if err != nil {
+		return err
+	}
+	rec.Seq = s.seq + 1
+	if err := s.append(rec); err != nil {
+		s.failed = fmt.Errorf("file store journal write failed; restart to recover: %w", err)
+		s.logger.Error("journal write failed; rejecting further writes", zap.Error(err))
+		if err := s.rollbackLocked(offset); err != nil {
+			s.logger.Error("rolling back failed write", zap.Uint64("seq", rec.Seq), zap.Error(err))
+		}
+		return s.failed
+	}
+	s.seq = rec.Seq
+	s.sinceSnap++
+
+	if s.opts.SnapshotEvery > 0 && s.sinceSnap >= s.opts.SnapshotEvery {
+		if err := s.snapshotLocked(); err != nil {
+			// The journal still holds everything; try again next time.
+			s.logger.Warn("snapshot failed", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// rollbackLocked undoes a write whose journal append failed. fn has already
+// applied it to memory, so memory is rebuilt from the snapshot and the
+// journal, cut back to offset where the append started so the write does not
+// reappear after a restart either. s.mu must be held.
+func (s *FileStore) rollbackLocked(offset int64) error {
+	path := filepath.Join(s.dir, journalFile)
+	if err := os.Truncate(path, offset); err != nil {
+		return err
+	}
+	journal, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	records, _, _, err := readJournal(journal)
+	if err != nil {
+		return err
+	}
+
+	acked := &FileStore{mem: NewInMemoryStore(), dir: s.dir, logger: s.logger}
+	if err := acked.loadSnapshot(); err != nil {
+		return err
+	}
+	if _, err := acked.replay(records); err != nil {
+		return err
+	}
+	if acked.seq != s.seq {
+		return fmt.Errorf("journal ends at record %d, want %d", acked.seq, s.seq)
+	}
+	s.mem.restore(acked.mem.snapshot())
+	return nil
+}
+
+func (s *FileStore) append(rec journalRecord) error {
+	line, err := encodeFrame(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.journal.Write(line); err != nil {
+		return err
+	}
+	if s.opts.Sync == SyncAlways {
+		return s.journal.Sync()
+	}
+	s.dirty = true
+	return nil
+}
+
+// Snapshot writes the current state and empties the journal.
+func (s *FileStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("file store is closed")
+	}
+	return s.snapshotLocked()
+}
+
+func (s *FileStore) snapshotLocked() error {
+	state := s.mem.snapshot()
+	state.Seq = s.seq
+	frame, err := encodeFrame(state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.dir, filepath.Join(s.dir, snapshotFile), frame); err != nil {
+		return err
+	}
+	// A crash before the truncate is harmless: replay skips records the
+	// snapshot already covers.
+	if err := s.journal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.journal.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := s.journal.Sync(); err != nil {
+		return err
+	}
+	s.sinceSnap = 0
+	s.dirty = false
+	return nil
+}
+
+func (s *FileStore) syncLoop() {
+	defer close(s.syncLoopDone)
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopSyncLoop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && !s.closed {
+				if err := s.journal.Sync(); err != nil {
+					s.logger.Error("journal fsync failed", zap.Error(err))
+				} else {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// HealthCheck reports a failed journal so readiness probes take the instance
+// out of rotation.
+func (s *FileStore) HealthCheck(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// Close takes a final snapshot and closes the journal.
+func (s *FileStore) Close() error {
+	if s.stopSyncLoop != nil {
+		close(s.stopSyncLoop)
+		<-s.syncLoopDone
+		s.stopSyncLoop = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var snapErr error
+	if s.failed == nil {
+		snapErr = s.snapshotLocked()
+	}
+	return errors.Join(snapErr, s.journal.Close())
+}
+
+func (s *FileStore) ListCommunities(ctx context.Context) ([]Community, error) {
+	return s.mem.ListCommunities(ctx)
+}
+
+func (s *FileStore) GetCommunity(ctx context.Context, communityID string) (CommunityDetail, error) {
+	return s.mem.GetCommunity(ctx, communityID)
+}
+
+func (s *FileStore) GetCommunityBySlug(ctx context.Context, slug string) (Community, error) {
+	return s.mem.GetCommunityBySlug(ctx, slug)
+}
+
+func (s *FileStore) GetPost(ctx context.Context, communityID, postID string) (Post, error) {
+	return s.mem.GetPost(ctx, communityID, postID)
+}
+
+func (s *FileStore) ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error) {
+	return s.mem.ListPostsByCommunity(ctx, communityID)
+}
+
+func (s *FileStore) Stats(ctx context.Context) (Stats, error) {
+	return s.mem.Stats(ctx)
+}
+
+func (s *FileStore) ListUsers(ctx context.Context) ([]User, error) {
+	return s.mem.ListUsers(ctx)
+}
+
+func (s *FileStore) ListMembers(ctx context.Context, communityID string) ([]User, error) {
+	return s.mem.ListMembers(ctx, communityID)
+}
+
+func (s *FileStore) GetMembership(ctx context.Context, communityID, userID string) (Membership, error) {
+	return s.mem.GetMembership(ctx, communityID, userID)
+}
+
+func (s *FileStore) ListMemberships(ctx context.Context, userID string) ([]Membership, error) {
+	return s.mem.ListMemberships(ctx, userID)
+}
+
+func (s *FileStore) ListJoinRequests(ctx context.Context, communityID string) ([]JoinRequest, error) {
+	return s.mem.ListJoinRequests(ctx, communityID)
+}
+
+func (s *FileStore) ListPlans(ctx context.Context, communityID string) ([]Plan, error) {
+	return s.mem.ListPlans(ctx, communityID)
+}
+
+func (s *FileStore) GetPlan(ctx context.Context, communityID, planID string) (Plan, error) {
+	return s.mem.GetPlan(ctx, communityID, planID)
+}
+
+func (s *FileStore) ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	return s.mem.ListFeatureFlags(ctx)
+}
+
+func (s *FileStore) GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error) {
+	return s.mem.GetFeatureFlag(ctx, name)
+}
+
+func (s *FileStore) CreateCommunity(ctx context.Context, input CommunityInput) (community Community, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		community, err = s.mem.CreateCommunity(ctx, input)
+		rec := journalRecord{Op: opCreateCommunity, Community: &community}
+		if input.AdminID != "" {
+			rec.Membership = &Membership{CommunityID: community.ID, UserID: input.AdminID, Role: RoleAdmin}
+		}
+		return rec, err
+	})
+	return community, err
+}
+
+func (s *FileStore) UpdateCommunity(ctx context.Context, communityID string, input CommunityInput) (community Community, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		community, err = s.mem.UpdateCommunity(ctx, communityID, input)
+		return journalRecord{Op: opUpdateCommunity, Community: &community}, err
+	})
+	return community, err
+}
+
+func (s *FileStore) DeleteCommunity(ctx context.Context, communityID string) error {
+	return s.mutate(func() (journalRecord, error) {
+		err := s.mem.DeleteCommunity(ctx, communityID)
+		return journalRecord{Op: opDeleteCommunity, CommunityID: communityID}, err
+	})
+}
+
+func (s *FileStore) CreatePost(ctx context.Context, communityID string, input PostInput) (post Post, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		post, err = s.mem.CreatePost(ctx, communityID, input)
+		return journalRecord{Op: opCreatePost, Post: &post}, err
+	})
+	return post, err
+}
+
+func (s *FileStore) DeletePost(ctx context.Context, communityID, postID string) error {
+	return s.mutate(func() (journalRecord, error) {
+		err := s.mem.DeletePost(ctx, communityID, postID)
+		return journalRecord{Op: opDeletePost, CommunityID: communityID, ID: postID}, err
+	})
+}
+
+func (s *FileStore) CreateUser(ctx context.Context, input UserInput) (user User, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		user, err = s.mem.CreateUser(ctx, input)
+		return journalRecord{Op: opCreateUser, User: &user}, err
+	})
+	return user, err
+}
+
+func (s *FileStore) AddMember(ctx context.Context, communityID, userID string) error {
+	return s.mutate(func() (journalRecord, error) {
+		err := s.mem.AddMember(ctx, communityID, userID)
+		return journalRecord{Op: opAddMember, CommunityID: communityID, ID: userID}, err
+	})
+}
+
+func (s *FileStore) CreateInvite(ctx context.Context, communityID string, input InviteInput) (invite Invite, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		invite, err = s.mem.CreateInvite(ctx, communityID, input)
+		return journalRecord{Op: opCreateInvite, Invite: &invite}, err
+	})
+	return invite, err
+}
+
+func (s *FileStore) RedeemInvite(_ context.Context, code, userID string) (membership Membership, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		var invite Invite
+		membership, invite, err = s.mem.redeemInvite(code, userID)
+		return journalRecord{Op: opRedeemInvite, Invite: &invite, Membership: &membership}, err
+	})
+	return membership, err
+}
+
+func (s *FileStore) CreateJoinRequest(ctx context.Context, communityID, userID string) (req JoinRequest, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		req, err = s.mem.CreateJoinRequest(ctx, communityID, userID)
+		return journalRecord{Op: opCreateJoinReq, JoinRequest: &req}, err
+	})
+	return req, err
+}
+
+func (s *FileStore) DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (req JoinRequest, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		req, err = s.mem.DecideJoinRequest(ctx, communityID, requestID, status)
+		return journalRecord{Op: opDecideJoinReq, JoinRequest: &req}, err
+	})
+	return req, err
+}
+
+func (s *FileStore) CreatePlan(ctx context.Context, communityID string, input PlanInput) (plan Plan, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		plan, err = s.mem.CreatePlan(ctx, communityID, input)
+		return journalRecord{Op: opCreatePlan, Plan: &plan}, err
+	})
+	return plan, err
+}
+
+func (s *FileStore) SetSubscription(ctx context.Context, communityID, userID string, sub Subscription) (membership Membership, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		membership, err = s.mem.SetSubscription(ctx, communityID, userID, sub)
+		return journalRecord{Op: opSetSubscription, Membership: &membership}, err
+	})
+	return membership, err
+}
+
+func (s *FileStore) UpdateSubscription(ctx context.Context, subscriptionID string, update SubscriptionUpdate) (membership Membership, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		membership, err = s.mem.UpdateSubscription(ctx, subscriptionID, update)
+		return journalRecord{Op: opUpdateSubscription, Membership: &membership, ID: update.EventID}, err
+	})
+	return membership, err
+}
+
+func (s *FileStore) UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (flag FeatureFlag, err error) {
+	err = s.mutate(func() (journalRecord, error) {
+		flag, err = s.mem.UpsertFeatureFlag(ctx, name, input)
+		return journalRecord{Op: opUpsertFlag, Flag: &flag}, err
+	})
+	return flag, err
+}
+
+func (s *FileStore) DeleteFeatureFlag(ctx context.Context, name string) error {
+	return s.mutate(func() (journalRecord, error) {
+		err := s.mem.DeleteFeatureFlag(ctx, name)
+		return journalRecord{Op: opDeleteFlag, ID: name}, err
+	})
+}
+
+// snapshot copies the store's state for persistence.
+func (s *InMemoryStore) snapshot() snapshotState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := snapshotState{
+		Communities:  make([]Community, 0, len(s.communityOrder)),
+		Slugs:        make(map[string]string, len(s.slugs)),
+		Posts:        make(map[string][]Post, len(s.posts)),
+		Users:        make([]User, 0, len(s.users)),
+		Memberships:  []Membership{},
+		Invites:      make([]Invite, 0, len(s.invites)),
+		JoinRequests: make([]JoinRequest, 0, len(s.joinRequests)),
+		Flags:        make([]FeatureFlag, 0, len(s.flags)),
+	}
+	for _, id := range s.communityOrder {
+		state.Communities = append(state.Communities, s.communities[id])
+	}
+	for slug, id := range s.slugs {
+		state.Slugs[slug] = id
+	}
+	for id, posts := range s.posts {
+		state.Posts[id] = append([]Post(nil), posts...)
+	}
+	for _, u := range s.users {
+		state.Users = append(state.Users, u)
+	}
+	for _, members := range s.members {
+		for _, m := range members {
+			state.Memberships = append(state.Memberships, m)
+		}
+	}
+	for _, invite := range s.invites {
+		state.Invites = append(state.Invites, invite)
+	}
+	for _, req := range s.joinRequests {
+		state.JoinRequests = append(state.JoinRequests, req)
+	}
+	for _, plan := range s.plans {
+		state.Plans = append(state.Plans, plan)
+	}
+	for id := range s.billingEvents {
+		state.BillingEvents = append(state.BillingEvents, id)
+	}
+	for _, f := range s.flags {
+		state.Flags = append(state.Flags, f)
+	}
+	// Stable output makes snapshots diffable.
+	sortUsers(state.Users)
+	sort.Slice(state.Memberships, func(i, j int) bool {
+		a, b := state.Memberships[i], state.Memberships[j]
+		if a.CommunityID != b.CommunityID {
+			return a.CommunityID < b.CommunityID
+		}
+		return a.UserID < b.UserID
+	})
+	sort.Slice(state.Invites, func(i, j int) bool { return state.Invites[i].Code < state.Invites[j].Code })
+	sortJoinRequests(state.JoinRequests)
+	sortPlans(state.Plans)
+	sort.Strings(state.BillingEvents)
+	sort.Slice(state.Flags, func(i, j int) bool { return state.Flags[i].Name < state.Flags[j].Name })
+	return state
+}
+
+// restore replaces the store's contents with state.
+func (s *InMemoryStore) restore(state snapshotState) {
+	fresh := NewInMemoryStore()
+	for slug, id := range state.Slugs {
+		fresh.slugs[slug] = id
+	}
+	for _, c := range state.Communities {
+		fresh.putCommunity(c)
+	}
+	for id, posts := range state.Posts {
+		fresh.posts[id] = append([]Post(nil), posts...)
+	}
+	for _, u := range state.Users {
+		fresh.putUser(u)
+	}
+	for id, userIDs := range state.Members {
+		for _, userID := range userIDs {
+			fresh.addMemberLocked(id, userID, RoleMember)
+		}
+	}
+	for _, m := range state.Memberships {
+		fresh.putMembershipLocked(m)
+	}
+	for _, invite := range state.Invites {
+		fresh.invites[invite.Code] = invite
+	}
+	for _, req := range state.JoinRequests {
+		fresh.joinRequests[req.ID] = req
+	}
+	for _, plan := range state.Plans {
+		fresh.plans[plan.ID] = plan
+	}
+	for _, id := range state.BillingEvents {
+		fresh.billingEvents[id] = struct{}{}
+	}
+	for _, f := range state.Flags {
+		fresh.putFlag(f)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.communities, s.communityOrder, s.slugs = fresh.communities, fresh.communityOrder, fresh.slugs
+	s.posts, s.users, s.members, s.flags = fresh.posts, fresh.users, fresh.members, fresh.flags
+	s.invites, s.joinRequests = fresh.invites, fresh.joinRequests
+	s.plans, s.subscriptions, s.billingEvents = fresh.plans, fresh.subscriptions, fresh.billingEvents
+}
+
+// putCommunity stores c, keeping any slug it had before in the redirect
+// history. Communities written before slugs existed get one generated here,
+// deterministically since records are replayed in order, and those written
+// before visibility existed are public.
+func (s *InMemoryStore) putCommunity(c Community) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.communities[c.ID]; !ok {
+		s.communityOrder = append(s.communityOrder, c.ID)
+	}
+	if c.Slug == "" {
+		c.Slug = s.freeSlugLocked(slugify(c.Name))
+	}
+	c.Visibility = visibilityOrDefault(c.Visibility)
+	s.communities[c.ID] = c
+	s.slugs[c.Slug] = c.ID
+}
+
+func (s *InMemoryStore) putPost(p Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.communities[p.CommunityID]; !ok {
+		return ErrCommunityNotFound
+	}
+	s.posts[p.CommunityID] = append(s.posts[p.CommunityID], p)
+	return nil
+}
+
+// putMembership stores m as is, replacing the role and subscription of an
+// existing member.
+func (s *InMemoryStore) putMembership(m Membership) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.communities[m.CommunityID]; !ok {
+		return ErrCommunityNotFound
+	}
+	s.putMembershipLocked(m)
+	return nil
+}
+
+// putBillingEvent marks a billing event applied. Empty IDs are ignored.
+func (s *InMemoryStore) putBillingEvent(id string) {
+	if id == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.billingEvents[id] = struct{}{}
+}
+
+func (s *InMemoryStore) putPlan(plan Plan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.communities[plan.CommunityID]; !ok {
+		return ErrCommunityNotFound
+	}
+	s.plans[plan.ID] = plan
+	return nil
+}
+
+func (s *InMemoryStore) putInvite(invite Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.communities[invite.CommunityID]; !ok {
+		return ErrCommunityNotFound
+	}
+	s.invites[invite.Code] = invite
+	return nil
+}
+
+func (s *InMemoryStore) putJoinRequest(req JoinRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.communities[req.CommunityID]; !ok {
+		return ErrCommunityNotFound
+	}
+	s.putJoinRequestLocked(req)
+	return nil
+}
+
+func (s *InMemoryStore) putUser(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[u.ID] = u
+}
+
+func (s *InMemoryStore) putFlag(f FeatureFlag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[f.Name] = f
+}
+
+func trimNewline(b []byte) []byte {
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		return b[:n-1]
+	}
+	return b
+}
@@ -0,0 +1,358 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func openFileStore(t *testing.T, dir string, opts FileStoreOptions) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(dir, opts, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("open file store: %v", err)
+	}
+	return store
+}
+
+// populate writes one of every kind of record and returns the final state.
+func populate(t *testing.T, store Store) snapshotState {
+	t.Helper()
+	ctx := context.Background()
+	go1, err := store.CreateCommunity(ctx, CommunityInput{Name: "Go"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	gone, err := store.CreateCommunity(ctx, CommunityInput{Name: "Gone"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	user, err := store.CreateUser(ctx, UserInput{Name: "Ann", Email: "ann@example.com"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.AddMember(ctx, go1.ID, user.ID); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	if _, err := store.UpdateCommunity(ctx, go1.ID, CommunityInput{Name: "Go", Slug: "golang"}); err != nil {
+		t.Fatalf("update community: %v", err)
+	}
+	if _, err := store.CreatePost(ctx, go1.ID, PostInput{AuthorID: user.ID, Title: "Kept", Content: "x"}); err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	dropped, err := store.CreatePost(ctx, go1.ID, PostInput{Title: "Dropped", Content: "x"})
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	if err := store.DeletePost(ctx, go1.ID, dropped.ID); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	if err := store.DeleteCommunity(ctx, gone.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	bob, err := store.CreateUser(ctx, UserInput{Name: "Bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	club, err := store.CreateCommunity(ctx, CommunityInput{Name: "Club", Visibility: VisibilityPrivate, AdminID: user.ID})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	invite, err := store.CreateInvite(ctx, club.ID, InviteInput{MaxUses: 2})
+	if err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	if _, err := store.RedeemInvite(ctx, invite.Code, bob.ID); err != nil {
+		t.Fatalf("redeem invite: %v", err)
+	}
+	expires := time.Now().Add(time.Hour)
+	if _, err := store.CreateInvite(ctx, club.ID, InviteInput{ExpiresAt: &expires}); err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	req, err := store.CreateJoinRequest(ctx, go1.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("create join request: %v", err)
+	}
+	if _, err := store.DecideJoinRequest(ctx, go1.ID, req.ID, JoinRequestApproved); err != nil {
+		t.Fatalf("decide join request: %v", err)
+	}
+	carol, err := store.CreateUser(ctx, UserInput{Name: "Carol", Email: "carol@example.com"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := store.CreateJoinRequest(ctx, club.ID, carol.ID); err != nil {
+		t.Fatalf("create join request: %v", err)
+	}
+	plan, err := store.CreatePlan(ctx, club.ID, PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd", TrialDays: 7})
+	if err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	if _, err := store.SetSubscription(ctx, club.ID, carol.ID, Subscription{ID: "sub_carol", PlanID: plan.ID, Status: SubscriptionTrialing, CurrentPeriodEnd: &expires}); err != nil {
+		t.Fatalf("set subscription: %v", err)
+	}
+	if _, err := store.UpdateSubscription(ctx, "sub_carol", SubscriptionUpdate{EventID: "evt_carol", Status: SubscriptionActive}); err != nil {
+		t.Fatalf("update subscription: %v", err)
+	}
+	if _, err := store.UpsertFeatureFlag(ctx, "beta", FeatureFlagInput{Enabled: true, Percentage: 5}); err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	if _, err := store.UpsertFeatureFlag(ctx, "old", FeatureFlagInput{}); err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	if err := store.DeleteFeatureFlag(ctx, "old"); err != nil {
+		t.Fatalf("delete flag: %v", err)
+	}
+	return store.(*FileStore).mem.snapshot()
+}
+
+func TestFileStoreReplaysJournalAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultFileStoreOptions()
+	opts.SnapshotEvery = 0
+
+	first := openFileStore(t, dir, opts)
+	want := populate(t, first)
+	// No Close: simulate a crash with everything only in the journal.
+
+	second := openFileStore(t, dir, opts)
+	defer second.Close()
+	if got := second.mem.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed state differs:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFileStoreGeneratesMissingSlugs(t *testing.T) {
+	dir := t.TempDir()
+	// A snapshot written before communities had slugs.
+	legacy := snapshotState{Seq: 2, Communities: []Community{{ID: newID(), Name: "Go"}, {ID: newID(), Name: "Go"}}}
+	frame, err := encodeFrame(legacy)
+	if err != nil {
+		t.Fatalf("encode snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotFile), frame, 0o644); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+
+	store := openFileStore(t, dir, DefaultFileStoreOptions())
+	defer store.Close()
+	ctx := context.Background()
+	for i, want := range []string{"go", "go-2"} {
+		got, err := store.GetCommunityBySlug(ctx, want)
+		if err != nil || got.ID != legacy.Communities[i].ID {
+			t.Fatalf("get by slug %q = %+v, %v; want community %s", want, got, err, legacy.Communities[i].ID)
+		}
+	}
+}
+
+func TestFileStoreRestoresLegacyMembers(t *testing.T) {
+	dir := t.TempDir()
+	// A snapshot written before members had roles.
+	community := Community{ID: newID(), Slug: "go", Name: "Go"}
+	user := User{ID: newID(), Name: "Ann", Email: "ann@example.com"}
+	legacy := snapshotState{
+		Seq:         3,
+		Communities: []Community{community},
+		Users:       []User{user},
+		Members:     map[string][]string{community.ID: {user.ID}},
+	}
+	frame, err := encodeFrame(legacy)
+	if err != nil {
+		t.Fatalf("encode snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotFile), frame, 0o644); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+
+	store := openFileStore(t, dir, DefaultFileStoreOptions())
+	defer store.Close()
+	ctx := context.Background()
+	m, err := store.GetMembership(ctx, community.ID, user.ID)
+	if want := (Membership{CommunityID: community.ID, UserID: user.ID, Role: RoleMember}); err != nil || m != want {
+		t.Fatalf("membership = %+v, %v; want %+v", m, err, want)
+	}
+	if got, err := store.GetCommunity(ctx, community.ID); err != nil || got.Visibility != VisibilityPublic {
+		t.Fatalf("expected legacy communities to be public, got %+v, %v", got, err)
+	}
+}
+
+func TestFileStoreSnapshotCompactsJournal(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultFileStoreOptions()
+	opts.SnapshotEvery = 5
+
+	first := openFileStore(t, dir, opts)
+	want := populate(t, first)
+	info, err := os.Stat(filepath.Join(dir, journalFile))
+	if err != nil {
+		t.Fatalf("stat journal: %v", err)
+	}
+	// 24 writes with a snapshot every 5 leaves 4 records in the journal.
+	if first.sinceSnap != 4 || info.Size() == 0 {
+		t.Fatalf("expected 4 journal records after compaction, got %d (%d bytes)", first.sinceSnap, info.Size())
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, journalFile)); info.Size() != 0 {
+		t.Fatalf("expected Close to empty the journal, got %d bytes", info.Size())
+	}
+
+	second := openFileStore(t, dir, opts)
+	defer second.Close()
+	if got := second.mem.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("restored state differs:\n got %+v\nwant %+v", got, want)
+	}
+	if _, err := second.CreateCommunity(context.Background(), CommunityInput{Name: "After"}); err != nil {
+		t.Fatalf("write after restore: %v", err)
+	}
+}
+
+// failingJournal writes the first n bytes of each write and then fails, or
+// when n is negative writes everything and fails to sync.
+type failingJournal struct {
+	journalWriter
+	n int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (j failingJournal) Write(p []byte) (int, error) {
+	if j.n < 0 {
+		return j.journalWriter.Write(p)
+	}
+	n, _ := j.journalWriter.Write(p[:min(j.n, len(p))])
+	return n, errDiskFull
+}
+
+func (j failingJournal) Sync() error {
+	if j.n < 0 {
+		return errDiskFull
+	}
+	return j.journalWriter.Sync()
+}
+
+func TestFileStoreRollsBackFailedWrite(t *testing.T) {
+	for name, n := range map[string]int{"torn write": 20, "failed sync": -1} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			opts := DefaultFileStoreOptions()
+			opts.SnapshotEvery = 5
+
+			first := openFileStore(t, dir, opts)
+			want := populate(t, first)
+			first.journal = failingJournal{journalWriter: first.journal, n: n}
+
+			if _, err := first.CreateCommunity(ctx, CommunityInput{Name: "Lost"}); !errors.Is(err, errDiskFull) {
+				t.Fatalf("expected the journal error, got %v", err)
+			}
+			if got := first.mem.snapshot(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("failed write is still visible:\n got %+v\nwant %+v", got, want)
+			}
+			if err := first.HealthCheck(ctx); err == nil {
+				t.Fatalf("expected the store to report the failed journal")
+			}
+			if _, err := first.CreateUser(ctx, UserInput{Name: "Late", Email: "late@example.com"}); err == nil {
+				t.Fatalf("expected writes to be rejected after a journal failure")
+			}
+			first.Close()
+
+			second := openFileStore(t, dir, opts)
+			defer second.Close()
+			if got := second.mem.snapshot(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("failed write reappeared after restart:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileStoreTruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	first := openFileStore(t, dir, FileStoreOptions{Sync: SyncNever})
+	if _, err := first.CreateCommunity(context.Background(), CommunityInput{Name: "Go"}); err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+
+	path := filepath.Join(dir, journalFile)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	if _, err := f.WriteString(`0badc0de {"seq":2,"op":"create_comm`); err != nil {
+		t.Fatalf("write torn record: %v", err)
+	}
+	f.Close()
+
+	second := openFileStore(t, dir, FileStoreOptions{Sync: SyncNever})
+	defer second.Close()
+	communities, _ := second.ListCommunities(context.Background())
+	if len(communities) != 1 {
+		t.Fatalf("expected the intact record to survive, got %+v", communities)
+	}
+	if _, err := second.CreateCommunity(context.Background(), CommunityInput{Name: "Next"}); err != nil {
+		t.Fatalf("write after truncation: %v", err)
+	}
+}
+
+func TestFileStoreDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	first := openFileStore(t, dir, FileStoreOptions{Sync: SyncNever})
+	for _, name := range []string{"a", "b"} {
+		if _, err := first.CreateCommunity(context.Background(), CommunityInput{Name: name}); err != nil {
+			t.Fatalf("create community: %v", err)
+		}
+	}
+
+	path := filepath.Join(dir, journalFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	// Flip a byte inside the first record's payload.
+	data[20] ^= 0x01
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	if _, err := NewFileStore(dir, FileStoreOptions{Sync: SyncNever}, zaptest.NewLogger(t)); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData, got %v", err)
+	}
+}
+
+func TestFileStoreIntervalSync(t *testing.T) {
+	store := openFileStore(t, t.TempDir(), FileStoreOptions{Sync: SyncInterval, SyncInterval: time.Millisecond})
+	if _, err := store.CreateCommunity(context.Background(), CommunityInput{Name: "Go"}); err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		dirty := store.dirty
+		store.mu.Unlock()
+		if !dirty {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background sync never flushed the journal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := store.CreateCommunity(context.Background(), CommunityInput{Name: "Late"}); err == nil {
+		t.Fatal("expected writes after Close to fail")
+	}
+}
+
+func TestNewFileStoreRejectsUnknownSyncPolicy(t *testing.T) {
+	if _, err := NewFileStore(t.TempDir(), FileStoreOptions{Sync: "sometimes"}, zaptest.NewLogger(t)); err == nil {
+		t.Fatal("expected error for unknown sync policy")
+	}
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/metrics"
+)
+
+// InstrumentedStore wraps a Store and records per-operation latency and error metrics.
+type InstrumentedStore struct {
+	next Store
+}
+
+// NewInstrumentedStore decorates next with Prometheus instrumentation.
+func NewInstrumentedStore(next Store) *InstrumentedStore {
+	return &InstrumentedStore{next: next}
+}
+
+// Unwrap returns the decorated store.
+func (s *InstrumentedStore) Unwrap() Store {
+	return s.next
+}
+
+func (s *InstrumentedStore) ListCommunities(ctx context.Context) (_ []Community, err error) {
+	defer observe("list_communities", time.Now(), &err)
+	return s.next.ListCommunities(ctx)
+}
+
+func (s *InstrumentedStore) GetCommunity(ctx context.Context, communityID string) (_ CommunityDetail, err error) {
+	defer observe("get_community", time.Now(), &err)
+	return s.next.GetCommunity(ctx, communityID)
+}
+
+func (s *InstrumentedStore) CreateCommunity(ctx context.Context, input CommunityInput) (_ Community, err error) {
+	defer observe("create_community", time.Now(), &err)
+	return s.next.CreateCommunity(ctx, input)
+}
+
+func (s *InstrumentedStore) GetCommunityBySlug(ctx context.Context, slug string) (_ Community, err error) {
+	defer observe("get_community_by_slug", time.Now(), &err)
+	return s.next.GetCommunityBySlug(ctx, slug)
+}
+
+func (s *InstrumentedStore) UpdateCommunity(ctx context.Context, communityID string, input CommunityInput) (_ Community, err error) {
+	defer observe("update_community", time.Now(), &err)
+	return s.next.UpdateCommunity(ctx, communityID, input)
+}
+
+func (s *InstrumentedStore) DeleteCommunity(ctx context.Context, communityID string) (err error) {
+	defer observe("delete_community", time.Now(), &err)
+	return s.next.DeleteCommunity(ctx, communityID)
+}
+
+func (s *InstrumentedStore) ListPostsByCommunity(ctx context.Context, communityID string) (_ []Post, err error) {
+	defer observe("list_posts_by_community", time.Now(), &err)
+	return s.next.ListPostsByCommunity(ctx, communityID)
+}
+
+func (s *InstrumentedStore) GetPost(ctx context.Context, communityID, postID string) (_ Post, err error) {
+	defer observe("get_post", time.Now(), &err)
+	return s.next.GetPost(ctx, communityID, postID)
+}
+
+func (s *InstrumentedStore) CreatePost(ctx context.Context, communityID string, input PostInput) (_ Post, err error) {
+	defer observe("create_post", time.Now(), &err)
+	return s.next.CreatePost(ctx, communityID, input)
+}
+
+func (s *InstrumentedStore) DeletePost(ctx context.Context, communityID, postID string) (err error) {
+	defer observe("delete_post", time.Now(), &err)
+	return s.next.DeletePost(ctx, communityID, postID)
+}
+
+func (s *InstrumentedStore) Stats(ctx context.Context) (_ Stats, err error) {
+	defer observe("stats", time.Now(), &err)
+	return s.next.Stats(ctx)
+}
+
+func (s *InstrumentedStore) ListUsers(ctx context.Context) (_ []User, err error) {
+	defer observe("list_users", time.Now(), &err)
+	return s.next.ListUsers(ctx)
+}
+
+func (s *InstrumentedStore) CreateUser(ctx context.Context, input UserInput) (_ User, err error) {
+	defer observe("create_user", time.Now(), &err)
+	return s.next.CreateUser(ctx, input)
+}
+
+func (s *InstrumentedStore) AddMember(ctx context.Context, communityID, userID string) (err error) {
+	defer observe("add_member", time.Now(), &err)
+	return s.next.AddMember(ctx, communityID, userID)
+}
+
+func (s *InstrumentedStore) ListMembers(ctx context.Context, communityID string) (_ []User, err error) {
+	defer observe("list_members", time.Now(), &err)
+	return s.next.ListMembers(ctx, communityID)
+}
+
+func (s *InstrumentedStore) GetMembership(ctx context.Context, communityID, userID string) (_ Membership, err error) {
+	defer observe("get_membership", time.Now(), &err)
+	return s.next.GetMembership(ctx, communityID, userID)
+}
+
+func (s *InstrumentedStore) ListMemberships(ctx context.Context, userID string) (_ []Membership, err error) {
+	defer observe("list_memberships", time.Now(), &err)
+	return s.next.ListMemberships(ctx, userID)
+}
+
+func (s *InstrumentedStore) CreateInvite(ctx context.Context, communityID string, input InviteInput) (_ Invite, err error) {
+	defer observe("create_invite", time.Now(), &err)
+	return s.next.CreateInvite(ctx, communityID, input)
+}
+
+func (s *InstrumentedStore) RedeemInvite(ctx context.Context, code, userID string) (_ Membership, err error) {
+	defer observe("redeem_invite", time.Now(), &err)
+	return s.next.RedeemInvite(ctx, code, userID)
+}
+
+func (s *InstrumentedStore) CreateJoinRequest(ctx context.Context, communityID, userID string) (_ JoinRequest, err error) {
+	defer observe("create_join_request", time.Now(), &err)
+	return s.next.CreateJoinRequest(ctx, communityID, userID)
+}
+
+func (s *InstrumentedStore) ListJoinRequests(ctx context.Context, communityID string) (_ []JoinRequest, err error) {
+	defer observe("list_join_requests", time.Now(), &err)
+	return s.next.ListJoinRequests(ctx, communityID)
+}
+
+func (s *InstrumentedStore) DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (_ JoinRequest, err error) {
+	defer observe("decide_join_request", time.Now(), &err)
+	return s.next.DecideJoinRequest(ctx, communityID, requestID, status)
+}
+
+func (s *InstrumentedStore) CreatePlan(ctx context.Context, communityID string, input PlanInput) (_ Plan, err error) {
+	defer observe("create_plan", time.Now(), &err)
+	return s.next.CreatePlan(ctx, communityID, input)
+}
+
+func (s *InstrumentedStore) ListPlans(ctx context.Context, communityID string) (_ []Plan, err error) {
+	defer observe("list_plans", time.Now(), &err)
+	return s.next.ListPlans(ctx, communityID)
+}
+
+func (s *InstrumentedStore) GetPlan(ctx context.Context, communityID, planID string) (_ Plan, err error) {
+	defer observe("get_plan", time.Now(), &err)
+	return s.next.GetPlan(ctx, communityID, planID)
+}
+
+func (s *InstrumentedStore) SetSubscription(ctx context.Context, communityID, userID string, sub Subscription) (_ Membership, err error) {
+	defer observe("set_subscription", time.Now(), &err)
+	return s.next.SetSubscription(ctx, communityID, userID, sub)
+}
+
+func (s *InstrumentedStore) UpdateSubscription(ctx context.Context, subscriptionID string, update SubscriptionUpdate) (_ Membership, err error) {
+	defer observe("update_subscription", time.Now(), &err)
+	return s.next.UpdateSubscription(ctx, subscriptionID, update)
+}
+
+func (s *InstrumentedStore) ListFeatureFlags(ctx context.Context) (_ []FeatureFlag, err error) {
+	defer observe("list_feature_flags", time.Now(), &err)
+	return s.next.ListFeatureFlags(ctx)
+}
+
+func (s *InstrumentedStore) GetFeatureFlag(ctx context.Context, name string) (_ FeatureFlag, err error) {
+	defer observe("get_feature_flag", time.Now(), &err)
+	return s.next.GetFeatureFlag(ctx, name)
+}
+
+func (s *InstrumentedStore) UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (_ FeatureFlag, err error) {
+	defer observe("upsert_feature_flag", time.Now(), &err)
+	return s.next.UpsertFeatureFlag(ctx, name, input)
+}
+
+func (s *InstrumentedStore) DeleteFeatureFlag(ctx context.Context, name string) (err error) {
+	defer observe("delete_feature_flag", time.Now(), &err)
+	return s.next.DeleteFeatureFlag(ctx, name)
+}
+
+// observe records the operation duration. Not-found sentinels are expected
+// outcomes rather than failures, so they are not counted as errors.
+func observe(operation string, start time.Time, errp *error) {
+	err := *errp
+	failed := err != nil &&
+		!errors.Is(err, ErrCommunityNotFound) &&
+		!errors.Is(err, ErrPostNotFound) &&
+		!errors.Is(err, ErrFeatureFlagNotFound) &&
+		!errors.Is(err, ErrUserNotFound)
+	metrics.ObserveDBQuery(operation, time.Since(start), failed)
+}
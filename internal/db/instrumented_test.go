@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func dbErrorCount(t *testing.T, operation string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "db_query_errors_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "operation" && l.GetValue() == operation {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestInstrumentedStoreCountsOnlyUnexpectedErrors(t *testing.T) {
+	store := NewInstrumentedStore(NewInMemoryStore())
+	ctx := context.Background()
+
+	before := dbErrorCount(t, "create_community")
+
+	// Validation failures count as errors.
+	if _, err := store.CreateCommunity(ctx, CommunityInput{}); err == nil {
+		t.Fatalf("expected error for missing name")
+	}
+	// Not-found sentinels do not.
+	if err := store.DeleteCommunity(ctx, "missing"); err == nil {
+		t.Fatalf("expected not found error")
+	}
+
+	if got := dbErrorCount(t, "create_community") - before; got != 1 {
+		t.Fatalf("expected 1 create_community error, got %v", got)
+	}
+	if got := dbErrorCount(t, "delete_community"); got != 0 {
+		t.Fatalf("expected no delete_community errors, got %v", got)
+	}
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ErrCorruptData indicates a journal or snapshot failed its checksum somewhere
+// other than a torn final write, so it cannot be replayed safely.
+var ErrCorruptData = errors.New("corrupt data file")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Journal operations.
+const (
+	opCreateCommunity    = "create_community"
+	opUpdateCommunity    = "update_community"
+	opDeleteCommunity    = "delete_community"
+	opCreatePost         = "create_post"
+	opDeletePost         = "delete_post"
+	opCreateUser         = "create_user"
+	opAddMember          = "add_member"
+	opCreateInvite       = "create_invite"
+	opRedeemInvite       = "redeem_invite"
+	opCreateJoinReq      = "create_join_request"
+	opDecideJoinReq      = "decide_join_request"
+	opCreatePlan         = "create_plan"
+	opSetSubscription    = "set_subscription"
+	opUpdateSubscription = "update_subscription"
+	opUpsertFlag         = "upsert_feature_flag"
+	opDeleteFlag         = "delete_feature_flag"
+)
+
+// journalRecord is one mutation. Created entities are recorded in full, with
+// the IDs and timestamps the store assigned, so replay is deterministic.
+type journalRecord struct {
+	Seq         uint64       `json:"seq"`
+	Op          string       `json:"op"`
+	Community   *Community   `json:"community,omitempty"`
+	Post        *Post        `json:"post,omitempty"`
+	User        *User        `json:"user,omitempty"`
+	Membership  *Membership  `json:"membership,omitempty"`
+	Invite      *Invite      `json:"invite,omitempty"`
+	JoinRequest *JoinRequest `json:"joinRequest,omitempty"`
+	Plan        *Plan        `json:"plan,omitempty"`
+	Flag        *FeatureFlag `json:"flag,omitempty"`
+	CommunityID string       `json:"communityId,omitempty"`
+	ID          string       `json:"id,omitempty"`
+}
+
+// encodeFrame renders v as one line: the CRC-32C of the JSON payload in hex,
+// a space, the payload and a newline.
+func encodeFrame(v any) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	line := fmt.Appendf(make([]byte, 0, len(payload)+10), "%08x ", crc32.Checksum(payload, crcTable))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+// decodeFrame verifies and unmarshals one line produced by encodeFrame,
+// without its trailing newline.
+func decodeFrame(line []byte, v any) error {
+	sum, payload, ok := bytes.Cut(line, []byte{' '})
+	if !ok || len(sum) != 8 {
+		return errors.New("malformed frame")
+	}
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil {
+		return errors.New("malformed checksum")
+	}
+	if uint32(want) != crc32.Checksum(payload, crcTable) {
+		return errors.New("checksum mismatch")
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// readJournal returns the records in r in order. A damaged final line is
+// treated as a torn write: the returned offset excludes it so the caller can
+// truncate it away. Damage anywhere else returns ErrCorruptData.
+func readJournal(r io.Reader) (records []journalRecord, validBytes int64, tornTail bool, err error) {
+	br := bufio.NewReader(r)
+	var (
+		offset  int64
+		lineNo  int
+		lastSeq uint64
+	)
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if len(line) == 0 && readErr == io.EOF {
+			return records, offset, false, nil
+		}
+		if readErr != nil && readErr != io.EOF {
+			return nil, 0, false, readErr
+		}
+		lineNo++
+
+		var rec journalRecord
+		complete := readErr == nil
+		frameErr := errors.New("incomplete final write")
+		if complete {
+			frameErr = decodeFrame(line[:len(line)-1], &rec)
+			if frameErr == nil && rec.Seq <= lastSeq {
+				frameErr = fmt.Errorf("sequence %d does not follow %d", rec.Seq, lastSeq)
+			}
+		}
+		if frameErr != nil {
+			if _, peekErr := br.Peek(1); peekErr == io.EOF {
+				return records, offset, true, nil
+			}
+			return nil, 0, false, fmt.Errorf("%w: journal line %d: %v", ErrCorruptData, lineNo, frameErr)
+		}
+
+		records = append(records, rec)
+		lastSeq = rec.Seq
+		offset += int64(len(line))
+	}
+}
+
+// writeFileAtomic writes data to path via a synced temporary file and rename,
+// then syncs the directory so the rename survives a crash.
+func writeFileAtomic(dir, path string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
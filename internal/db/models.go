@@ -9,11 +9,150 @@ type User struct {
 	Name  string `json:"name"`
 }
 
-// Community represents a community that users can post to.
+// Visibility controls who can find a community and read its posts.
+type Visibility string
+
+const (
+	// VisibilityPublic communities are listed and open to everyone.
+	VisibilityPublic Visibility = "public"
+	// VisibilityPrivate communities are listed, but only members can read
+	// and write posts. Others join with an invite or a join request.
+	VisibilityPrivate Visibility = "private"
+	// VisibilityHidden communities are invisible to non-members, who can
+	// only join with an invite.
+	VisibilityHidden Visibility = "hidden"
+)
+
+// Community represents a community that users can post to. Slug is a unique,
+// URL-safe alternative to ID; former slugs keep resolving to the community.
 type Community struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          string     `json:"id"`
+	Slug        string     `json:"slug"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Visibility  Visibility `json:"visibility"`
+}
+
+// CommunityDetail is a community with its member, admin, post and plan
+// counts. Communities with plans are paid.
+type CommunityDetail struct {
+	Community
+	MemberCount int `json:"memberCount"`
+	AdminCount  int `json:"adminCount"`
+	PostCount   int `json:"postCount"`
+	PlanCount   int `json:"planCount"`
+}
+
+// Role is a member's standing within a community.
+type Role string
+
+const (
+	RoleMember Role = "member"
+	// RoleAdmin members manage the community's settings, invites and join
+	// requests.
+	RoleAdmin Role = "admin"
+)
+
+// Membership records that a user belongs to a community, and the user's
+// subscription when the community is paid.
+type Membership struct {
+	CommunityID  string        `json:"communityId"`
+	UserID       string        `json:"userId"`
+	Role         Role          `json:"role"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+// PlanInterval is how often a plan bills.
+type PlanInterval string
+
+const (
+	PlanMonthly PlanInterval = "month"
+	PlanYearly  PlanInterval = "year"
+)
+
+// Plan is a price a community charges for membership.
+type Plan struct {
+	ID          string       `json:"id"`
+	CommunityID string       `json:"communityId"`
+	Name        string       `json:"name"`
+	PriceCents  int64        `json:"priceCents"`
+	Currency    string       `json:"currency"`
+	Interval    PlanInterval `json:"interval"`
+	TrialDays   int          `json:"trialDays"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+// SubscriptionStatus is where a subscription stands with the billing
+// provider.
+type SubscriptionStatus string
+
+const (
+	SubscriptionTrialing SubscriptionStatus = "trialing"
+	SubscriptionActive   SubscriptionStatus = "active"
+	// SubscriptionPastDue subscriptions have a failed payment the provider
+	// is still retrying.
+	SubscriptionPastDue  SubscriptionStatus = "past_due"
+	SubscriptionCanceled SubscriptionStatus = "canceled"
+)
+
+// Subscription is a member's subscription to one of a community's plans, as
+// last reported by the billing provider. ID is the provider's.
+type Subscription struct {
+	ID               string             `json:"id"`
+	PlanID           string             `json:"planId"`
+	Status           SubscriptionStatus `json:"status"`
+	CurrentPeriodEnd *time.Time         `json:"currentPeriodEnd,omitempty"`
+}
+
+// Paid reports whether s grants access to a paid community: it is trialing
+// or active, and its current period has not ended. A nil subscription is not
+// paid. The period end guards against lost webhooks, since renewals move it.
+func (s *Subscription) Paid() bool {
+	if s == nil || (s.Status != SubscriptionTrialing && s.Status != SubscriptionActive) {
+		return false
+	}
+	return s.CurrentPeriodEnd == nil || time.Now().Before(*s.CurrentPeriodEnd)
+}
+
+// SubscriptionUpdate is a change to a subscription, usually reported by the
+// billing provider.
+type SubscriptionUpdate struct {
+	// EventID identifies the provider's event, which is applied at most
+	// once. Changes made through the API leave it empty.
+	EventID string
+	Status  SubscriptionStatus
+	// CurrentPeriodEnd replaces the period end unless nil.
+	CurrentPeriodEnd *time.Time
+}
+
+// Invite is a link that lets whoever holds its code join a community. A nil
+// ExpiresAt never expires and a zero MaxUses allows unlimited joins.
+type Invite struct {
+	Code        string     `json:"code"`
+	CommunityID string     `json:"communityId"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	MaxUses     int        `json:"maxUses"`
+	Uses        int        `json:"uses"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// JoinRequestStatus is where a join request stands.
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+// JoinRequest asks a community's admins to let a user join.
+type JoinRequest struct {
+	ID          string            `json:"id"`
+	CommunityID string            `json:"communityId"`
+	UserID      string            `json:"userId"`
+	Status      JoinRequestStatus `json:"status"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	DecidedAt   *time.Time        `json:"decidedAt,omitempty"`
 }
 
 // Post represents a message authored by a user within a community.
@@ -26,10 +165,44 @@ type Post struct {
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
-// CommunityInput captures the fields needed to create a community.
+// CommunityInput captures the fields needed to create or update a community.
+// An empty Slug is generated from Name on create and left unchanged on update;
+// likewise an empty Visibility is public on create and unchanged on update.
+// AdminID, set from the caller's identity rather than the request body, makes
+// that user the new community's first admin.
 type CommunityInput struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Slug        string     `json:"slug,omitempty"`
+	Visibility  Visibility `json:"visibility,omitempty"`
+	AdminID     string     `json:"-"`
+}
+
+// InviteInput captures the limits of a new invite.
+type InviteInput struct {
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   int        `json:"maxUses"`
+}
+
+// PlanInput captures the fields needed to create a plan. An empty Interval
+// bills monthly.
+type PlanInput struct {
+	Name       string       `json:"name"`
+	PriceCents int64        `json:"priceCents"`
+	Currency   string       `json:"currency"`
+	Interval   PlanInterval `json:"interval,omitempty"`
+	TrialDays  int          `json:"trialDays"`
+}
+
+// JoinRequestDecision approves or rejects a pending join request.
+type JoinRequestDecision struct {
+	Status JoinRequestStatus `json:"status"`
+}
+
+// UserInput captures the fields needed to create a user.
+type UserInput struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
 }
 
 // PostInput captures the fields needed to create a post.
@@ -38,3 +211,28 @@ type PostInput struct {
 	Title    string `json:"title"`
 	Content  string `json:"content"`
 }
+
+// Stats summarizes store contents for product metrics.
+type Stats struct {
+	Communities   int `json:"communities"`
+	Posts         int `json:"posts"`
+	ActiveMembers int `json:"activeMembers"`
+}
+
+// FeatureFlag controls the gradual rollout of a feature. A disabled flag is off
+// everywhere; an enabled flag is on for the listed communities and for the
+// given percentage of users (or communities, for anonymous callers).
+type FeatureFlag struct {
+	Name        string    `json:"name"`
+	Enabled     bool      `json:"enabled"`
+	Percentage  int       `json:"percentage"`
+	Communities []string  `json:"communities"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// FeatureFlagInput captures the fields needed to create or update a feature flag.
+type FeatureFlagInput struct {
+	Enabled     bool     `json:"enabled"`
+	Percentage  int      `json:"percentage"`
+	Communities []string `json:"communities"`
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTrialDays bounds the free trial a plan may offer.
+const maxTrialDays = 365
+
+func validatePlan(input PlanInput) error {
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.PriceCents <= 0 {
+		return fmt.Errorf("priceCents must be positive")
+	}
+	if len(input.Currency) != 3 || strings.Trim(strings.ToLower(input.Currency), "abcdefghijklmnopqrstuvwxyz") != "" {
+		return fmt.Errorf("currency must be a three-letter ISO 4217 code")
+	}
+	switch input.Interval {
+	case "", PlanMonthly, PlanYearly:
+	default:
+		return fmt.Errorf("interval must be month or year")
+	}
+	if input.TrialDays < 0 || input.TrialDays > maxTrialDays {
+		return fmt.Errorf("trialDays must be between 0 and %d", maxTrialDays)
+	}
+	return nil
+}
+
+// newPlan builds a plan for communityID with a fresh ID. The currency is
+// lower-cased and CreatedAt truncated to microseconds, the resolution of
+// Postgres.
+func newPlan(communityID string, input PlanInput) Plan {
+	interval := input.Interval
+	if interval == "" {
+		interval = PlanMonthly
+	}
+	return Plan{
+		ID:          newID(),
+		CommunityID: communityID,
+		Name:        input.Name,
+		PriceCents:  input.PriceCents,
+		Currency:    strings.ToLower(input.Currency),
+		Interval:    interval,
+		TrialDays:   input.TrialDays,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func validateSubscriptionStatus(status SubscriptionStatus) error {
+	switch status {
+	case SubscriptionTrialing, SubscriptionActive, SubscriptionPastDue, SubscriptionCanceled:
+		return nil
+	}
+	return fmt.Errorf("status must be trialing, active, past_due or canceled")
+}
+
+// applySubscriptionUpdate returns sub with u applied. Canceled is final.
+func applySubscriptionUpdate(sub Subscription, u SubscriptionUpdate) (Subscription, error) {
+	if sub.Status == SubscriptionCanceled && u.Status != SubscriptionCanceled {
+		return Subscription{}, ErrSubscriptionCanceled
+	}
+	sub.Status = u.Status
+	if u.CurrentPeriodEnd != nil {
+		sub.CurrentPeriodEnd = truncatePeriodEnd(u.CurrentPeriodEnd)
+	}
+	return sub, nil
+}
+
+func validateSubscription(sub Subscription) error {
+	if sub.ID == "" {
+		return fmt.Errorf("subscription id is required")
+	}
+	return validateSubscriptionStatus(sub.Status)
+}
+
+// truncatePeriodEnd returns end in UTC truncated to microseconds, so every
+// store returns the value later reads see.
+func truncatePeriodEnd(end *time.Time) *time.Time {
+	if end == nil {
+		return nil
+	}
+	t := end.UTC().Truncate(time.Microsecond)
+	return &t
+}
+
+// sortPlans orders plans by price, then ID, matching the SQL stores.
+func sortPlans(plans []Plan) {
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].PriceCents != plans[j].PriceCents {
+			return plans[i].PriceCents < plans[j].PriceCents
+		}
+		return plans[i].ID < plans[j].ID
+	})
+}
@@ -2,162 +2,864 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/metrics"
+)
+
+// healthCheckTimeout bounds how long a readiness ping may take.
+const healthCheckTimeout = 2 * time.Second
+
+// PoolConfig tunes the Postgres connection pool. Zero values keep the pgxpool
+// defaults.
+type PoolConfig struct {
+	MaxConns        int
+	MinConns        int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolConfig returns the pool settings used when none are configured.
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxConns:        10,
+		ConnMaxLifetime: 30 * time.Minute,
+	}
+}
+
+// Postgres error codes and constraint names inspected by PostgresStore.
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+
+	fkMembershipCommunity  = "community_memberships_community_id_fkey"
+	fkMembershipUser       = "community_memberships_user_id_fkey"
+	fkPostCommunity        = "posts_community_id_fkey"
+	fkSlugCommunity        = "community_slugs_community_id_fkey"
+	fkInviteCommunity      = "community_invites_community_id_fkey"
+	fkJoinRequestCommunity = "join_requests_community_id_fkey"
+	fkJoinRequestUser      = "join_requests_user_id_fkey"
+	fkPlanCommunity        = "community_plans_community_id_fkey"
+)
+
+// maxSlugAttempts bounds how often CreateCommunity picks a new generated slug
+// after losing a race for one.
+const maxSlugAttempts = 3
+
+// schemaLockID serializes schema changes across replicas starting together.
+const schemaLockID = 0x736b6f6f6c // "skool"
+
+// Prepared statement names; every pooled connection prepares them on connect.
+const (
+	stmtListCommunities    = "list_communities"
+	stmtGetCommunity       = "get_community"
+	stmtCommunityBySlug    = "community_by_slug"
+	stmtCreateCommunity    = "create_community"
+	stmtLockCommunity      = "lock_community"
+	stmtUpdateCommunity    = "update_community"
+	stmtSlugTaken          = "slug_taken"
+	stmtSlugOwner          = "slug_owner"
+	stmtAddSlug            = "add_slug"
+	stmtDeleteCommunity    = "delete_community"
+	stmtCommunityExists    = "community_exists"
+	stmtListPosts          = "list_posts"
+	stmtGetPost            = "get_post"
+	stmtCreatePost         = "create_post"
+	stmtDeletePost         = "delete_post"
+	stmtStats              = "stats"
+	stmtListUsers          = "list_users"
+	stmtCreateUser         = "create_user"
+	stmtAddMember          = "add_member"
+	stmtInsertMember       = "insert_member"
+	stmtListMembers        = "list_members"
+	stmtGetMembership      = "get_membership"
+	stmtListMemberships    = "list_memberships"
+	stmtCreateInvite       = "create_invite"
+	stmtLockInvite         = "lock_invite"
+	stmtUseInvite          = "use_invite"
+	stmtPendingJoinReq     = "pending_join_request"
+	stmtCreateJoinReq      = "create_join_request"
+	stmtListJoinReqs       = "list_join_requests"
+	stmtLockJoinReq        = "lock_join_request"
+	stmtDecideJoinReq      = "decide_join_request"
+	stmtCreatePlan         = "create_plan"
+	stmtListPlans          = "list_plans"
+	stmtGetPlan            = "get_plan"
+	stmtSetSubscription    = "set_subscription"
+	stmtLockSubscription   = "lock_subscription"
+	stmtRecordBillingEvent = "record_billing_event"
+	stmtUpdateSubscription = "update_subscription"
+	stmtListFeatureFlags   = "list_feature_flags"
+	stmtGetFeatureFlag     = "get_feature_flag"
+	stmtUpsertFeatureFlag  = "upsert_feature_flag"
+	stmtDeleteFeatureFlag  = "delete_feature_flag"
 )
 
-// PostgresStore implements Store backed by PostgreSQL.
+// replicaStatements are the statements prepared on read replicas.
+var replicaStatements = []string{stmtListCommunities, stmtCommunityExists, stmtListPosts}
+
+var statements = map[string]string{
+	stmtListCommunities: `SELECT id, slug, name, description, visibility FROM communities ORDER BY name, id`,
+	stmtGetCommunity: `SELECT c.id, c.slug, c.name, c.description, c.visibility,
+			(SELECT count(*) FROM community_memberships m WHERE m.community_id = c.id),
+			(SELECT count(*) FROM community_memberships m WHERE m.community_id = c.id AND m.role = 'admin'),
+			(SELECT count(*) FROM posts p WHERE p.community_id = c.id),
+			(SELECT count(*) FROM community_plans p WHERE p.community_id = c.id)
+		FROM communities c WHERE c.id = $1`,
+	stmtCommunityBySlug: `SELECT c.id, c.slug, c.name, c.description, c.visibility
+		FROM community_slugs s JOIN communities c ON c.id = s.community_id WHERE s.slug = $1`,
+	stmtCreateCommunity: `INSERT INTO communities (id, slug, name, description, visibility) VALUES ($1, $2, $3, $4, $5)`,
+	stmtLockCommunity:   `SELECT slug, visibility FROM communities WHERE id = $1 FOR UPDATE`,
+	stmtUpdateCommunity: `UPDATE communities SET slug = $2, name = $3, description = $4, visibility = $5 WHERE id = $1`,
+	stmtSlugTaken:       `SELECT EXISTS (SELECT 1 FROM community_slugs WHERE slug = $1)`,
+	stmtSlugOwner:       `SELECT community_id FROM community_slugs WHERE slug = $1`,
+	stmtAddSlug:         `INSERT INTO community_slugs (slug, community_id) VALUES ($1, $2)`,
+	stmtDeleteCommunity: `DELETE FROM communities WHERE id = $1`,
+	stmtCommunityExists: `SELECT EXISTS (SELECT 1 FROM communities WHERE id = $1)`,
+	stmtListPosts: `SELECT id, community_id, author_id, title, content, created_at FROM posts
+		WHERE community_id = $1 ORDER BY created_at DESC, id DESC`,
+	stmtGetPost: `SELECT id, community_id, author_id, title, content, created_at FROM posts
+		WHERE id = $1 AND community_id = $2`,
+	stmtCreatePost: `INSERT INTO posts (id, community_id, author_id, title, content, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+	stmtDeletePost: `DELETE FROM posts WHERE id = $1 AND community_id = $2`,
+	stmtStats: `SELECT
+		(SELECT count(*) FROM communities),
+		(SELECT count(*) FROM posts),
+		(SELECT count(DISTINCT author_id) FROM posts WHERE author_id <> '' AND created_at >= $1)`,
+	stmtListUsers:    `SELECT id, email, name FROM users ORDER BY name, id`,
+	stmtCreateUser:   `INSERT INTO users (id, email, name) VALUES ($1, $2, $3)`,
+	stmtAddMember:    `INSERT INTO community_memberships (community_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+	stmtInsertMember: `INSERT INTO community_memberships (community_id, user_id, role) VALUES ($1, $2, $3)`,
+	stmtListMembers: `SELECT u.id, u.email, u.name FROM users u
+		JOIN community_memberships m ON m.user_id = u.id
+		WHERE m.community_id = $1 ORDER BY u.name, u.id`,
+	stmtGetMembership: `SELECT ` + pgMembershipColumns + ` FROM community_memberships WHERE community_id = $1 AND user_id = $2`,
+	stmtListMemberships: `SELECT ` + pgMembershipColumns + ` FROM community_memberships
+		WHERE user_id = $1 ORDER BY community_id`,
+	stmtCreateInvite: `INSERT INTO community_invites (code, community_id, expires_at, max_uses, uses, created_at)
+		VALUES ($1, $2, $3, $4, 0, $5)`,
+	stmtLockInvite: `SELECT code, community_id, expires_at, max_uses, uses, created_at FROM community_invites
+		WHERE code = $1 FOR UPDATE`,
+	stmtUseInvite: `UPDATE community_invites SET uses = uses + 1 WHERE code = $1`,
+	stmtPendingJoinReq: `SELECT id, community_id, user_id, status, created_at, decided_at FROM join_requests
+		WHERE community_id = $1 AND user_id = $2 AND status = 'pending'`,
+	stmtCreateJoinReq: `INSERT INTO join_requests (id, community_id, user_id, status, created_at) VALUES ($1, $2, $3, 'pending', $4)`,
+	stmtListJoinReqs: `SELECT id, community_id, user_id, status, created_at, decided_at FROM join_requests
+		WHERE community_id = $1 AND status = 'pending' ORDER BY created_at, id`,
+	stmtLockJoinReq: `SELECT id, community_id, user_id, status, created_at, decided_at FROM join_requests
+		WHERE id = $1 AND community_id = $2 FOR UPDATE`,
+	stmtDecideJoinReq: `UPDATE join_requests SET status = $2, decided_at = $3 WHERE id = $1`,
+	stmtCreatePlan: `INSERT INTO community_plans (id, community_id, name, price_cents, currency, billing_interval, trial_days, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+	stmtListPlans: `SELECT ` + pgPlanColumns + ` FROM community_plans WHERE community_id = $1 ORDER BY price_cents, id`,
+	stmtGetPlan:   `SELECT ` + pgPlanColumns + ` FROM community_plans WHERE id = $1 AND community_id = $2`,
+	stmtSetSubscription: `INSERT INTO community_memberships
+			(community_id, user_id, subscription_id, plan_id, subscription_status, current_period_end)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (community_id, user_id) DO UPDATE SET subscription_id = excluded.subscription_id,
+			plan_id = excluded.plan_id, subscription_status = excluded.subscription_status,
+			current_period_end = excluded.current_period_end
+		RETURNING ` + pgMembershipColumns,
+	stmtLockSubscription: `SELECT ` + pgMembershipColumns + ` FROM community_memberships WHERE subscription_id = $1 FOR UPDATE`,
+	stmtRecordBillingEvent: `INSERT INTO billing_events (id, subscription_id, applied_at) VALUES ($1, $2, now())
+		ON CONFLICT (id) DO NOTHING`,
+	stmtUpdateSubscription: `UPDATE community_memberships SET subscription_status = $2, current_period_end = $3
+		WHERE subscription_id = $1`,
+	stmtListFeatureFlags: `SELECT name, enabled, percentage, communities, updated_at FROM feature_flags ORDER BY name`,
+	stmtGetFeatureFlag:   `SELECT name, enabled, percentage, communities, updated_at FROM feature_flags WHERE name = $1`,
+	stmtUpsertFeatureFlag: `INSERT INTO feature_flags (name, enabled, percentage, communities, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled, percentage = EXCLUDED.percentage,
+			communities = EXCLUDED.communities, updated_at = EXCLUDED.updated_at`,
+	stmtDeleteFeatureFlag: `DELETE FROM feature_flags WHERE name = $1`,
+}
+
+// PostgresStore implements Store backed by PostgreSQL through a pgx
+// connection pool. IDs are UUID columns; an ID that is not a valid UUID cannot
+// exist, so it is reported as not found without a round trip. Timestamps are
+// truncated to microseconds, the resolution of TIMESTAMPTZ, so the values it
+// returns match what later reads see.
+//
+// With read replicas configured, ListCommunities and ListPostsByCommunity are
+// served by a healthy replica unless the caller's session (see WithSession)
+// wrote recently; everything else goes to the primary.
 type PostgresStore struct {
-	db     *sql.DB
+	pool   *pgxpool.Pool
+	reads  *readRouter
 	logger *zap.Logger
+
+	stopChecks context.CancelFunc
+	checksDone chan struct{}
+}
+
+// PostgresOption configures optional PostgresStore behavior.
+type PostgresOption func(*postgresOptions)
+
+type postgresOptions struct {
+	replicaDSNs []string
+	replicas    ReplicaOptions
+}
+
+// WithReadReplicas routes list reads to the replicas at dsns, failing over to
+// the primary when they are unhealthy.
+func WithReadReplicas(dsns []string, opts ReplicaOptions) PostgresOption {
+	return func(o *postgresOptions) {
+		o.replicaDSNs = dsns
+		o.replicas = opts
+	}
+}
+
+// Open connects to the store named by dsn: an SQLiteStore for sqlite:// URLs
+// and a PostgresStore otherwise. pool and opts only apply to Postgres.
+func Open(ctx context.Context, dsn string, pool PoolConfig, logger *zap.Logger, opts ...PostgresOption) (Store, error) {
+	if strings.HasPrefix(dsn, SQLiteScheme) {
+		return NewSQLiteStore(ctx, dsn, logger)
+	}
+	return NewPostgresStore(ctx, dsn, pool, logger, opts...)
 }
 
 // NewPostgresStore initializes a Postgres-backed store and ensures schema exists.
-func NewPostgresStore(ctx context.Context, dsn string, logger *zap.Logger) (Store, error) {
-	db, err := sql.Open("pgx", dsn)
+func NewPostgresStore(ctx context.Context, dsn string, pool PoolConfig, logger *zap.Logger, opts ...PostgresOption) (Store, error) {
+	o := postgresOptions{replicas: DefaultReplicaOptions()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg, err := poolConfig(dsn, pool)
 	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
+		return nil, fmt.Errorf("parse dsn: %w", err)
 	}
+	cfg.AfterConnect = prepareStatements
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(30 * time.Minute)
+	// The schema must exist before pooled connections prepare statements
+	// against it, so apply it over a dedicated connection first.
+	if err := initSchema(ctx, cfg.ConnConfig); err != nil {
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
 
-	if err := db.PingContext(ctx); err != nil {
+	p, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open pool: %w", err)
+	}
+	if err := p.Ping(ctx); err != nil {
+		p.Close()
 		return nil, fmt.Errorf("ping db: %w", err)
 	}
 
-	store := &PostgresStore{db: db, logger: logger}
-	if err := store.initSchema(ctx); err != nil {
-		return nil, fmt.Errorf("init schema: %w", err)
+	if err := metrics.RegisterPgxPoolStats(p, "postgres"); err != nil {
+		logger.Warn("register db pool metrics failed", zap.Error(err))
 	}
 
+	replicas, err := openReplicas(ctx, o.replicaDSNs, pool, logger)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	store := &PostgresStore{
+		pool:       p,
+		reads:      newReadRouter(replicas, o.replicas, logger),
+		logger:     logger,
+		checksDone: make(chan struct{}),
+	}
+	if len(replicas) > 0 {
+		var checkCtx context.Context
+		checkCtx, store.stopChecks = context.WithCancel(context.Background())
+		store.reads.check(ctx)
+		go func() {
+			defer close(store.checksDone)
+			store.reads.run(checkCtx)
+		}()
+	} else {
+		close(store.checksDone)
+	}
 	return store, nil
 }
 
-func (s *PostgresStore) initSchema(ctx context.Context) error {
+func poolConfig(dsn string, pool PoolConfig) (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if pool.MaxConns > 0 {
+		cfg.MaxConns = int32(pool.MaxConns)
+	}
+	cfg.MinConns = int32(pool.MinConns)
+	if pool.ConnMaxLifetime > 0 {
+		cfg.MaxConnLifetime = pool.ConnMaxLifetime
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		cfg.MaxConnIdleTime = pool.ConnMaxIdleTime
+	}
+	return cfg, nil
+}
+
+// openReplicas creates a pool per replica DSN. Pools connect lazily, so an
+// unreachable replica does not fail startup; the first health check takes it
+// out of rotation instead.
+func openReplicas(ctx context.Context, dsns []string, pool PoolConfig, logger *zap.Logger) ([]*replica, error) {
+	var replicas []*replica
+	for i, dsn := range dsns {
+		cfg, err := poolConfig(dsn, pool)
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, fmt.Errorf("parse read replica %d dsn: %w", i+1, err)
+		}
+		cfg.MinConns = 0
+		cfg.AfterConnect = prepareReplicaStatements
+		p, err := pgxpool.NewWithConfig(ctx, cfg)
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, fmt.Errorf("open read replica %d: %w", i+1, err)
+		}
+		name := fmt.Sprintf("replica-%d", i+1)
+		if err := metrics.RegisterPgxPoolStats(p, "postgres-"+name); err != nil {
+			logger.Warn("register db pool metrics failed", zap.String("replica", name), zap.Error(err))
+		}
+		replicas = append(replicas, &replica{name: name, pool: p, ping: p.Ping})
+	}
+	return replicas, nil
+}
+
+func closeReplicas(replicas []*replica) {
+	for _, r := range replicas {
+		r.pool.Close()
+	}
+}
+
+// Close stops replica health checks and closes every pooled connection.
+func (s *PostgresStore) Close() error {
+	if s.stopChecks != nil {
+		s.stopChecks()
+	}
+	<-s.checksDone
+	closeReplicas(s.reads.replicas)
+	s.pool.Close()
+	return nil
+}
+
+// HealthCheck pings the database, failing if it does not answer within healthCheckTimeout.
+func (s *PostgresStore) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return s.pool.Ping(ctx)
+}
+
+func prepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func prepareReplicaStatements(ctx context.Context, conn *pgx.Conn) error {
+	for _, name := range replicaStatements {
+		if _, err := conn.Prepare(ctx, name, statements[name]); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// routeRead runs read against the replica chosen for ctx, retrying on the
+// primary if the replica fails in a way that suggests it is unavailable.
+func routeRead[T any](ctx context.Context, s *PostgresStore, read func(*pgxpool.Pool) (T, error)) (T, error) {
+	rep, target := s.reads.pick(ctx)
+	if rep != nil {
+		v, err := read(rep.pool)
+		if err == nil || !replicaUnavailable(ctx, err) {
+			metrics.ObserveDBRead(target)
+			return v, err
+		}
+		s.reads.markDown(rep, err)
+		target = readPrimaryFallback
+	}
+	metrics.ObserveDBRead(target)
+	return read(s.pool)
+}
+
+// replicaUnavailable reports whether err from a replica read should send the
+// read to the primary: connection failures, a replica shutting down or still
+// starting (class 57), and queries canceled by recovery conflicts (40001).
+// Errors caused by the caller's context or the query itself are returned as is.
+func replicaUnavailable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCommunityNotFound) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "57") || pgErr.Code == "40001"
+	}
+	return true
+}
+
+func initSchema(ctx context.Context, connConfig *pgx.ConnConfig) error {
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, schemaLockID); err != nil {
+			return err
+		}
+		stmts := []string{
+			`CREATE TABLE IF NOT EXISTS communities (
+				id UUID PRIMARY KEY,
+				slug TEXT NOT NULL,
+				name TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				visibility TEXT NOT NULL DEFAULT 'public'
+			);`,
+			`CREATE TABLE IF NOT EXISTS posts (
+				id UUID PRIMARY KEY,
+				community_id UUID NOT NULL CONSTRAINT ` + fkPostCommunity + ` REFERENCES communities(id) ON DELETE CASCADE,
+				author_id TEXT,
+				title TEXT NOT NULL,
+				content TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL
+			);`,
+			`CREATE TABLE IF NOT EXISTS users (
+				id UUID PRIMARY KEY,
+				email TEXT NOT NULL,
+				name TEXT NOT NULL
+			);`,
+			`CREATE TABLE IF NOT EXISTS community_memberships (
+				community_id UUID NOT NULL CONSTRAINT ` + fkMembershipCommunity + ` REFERENCES communities(id) ON DELETE CASCADE,
+				user_id UUID NOT NULL CONSTRAINT ` + fkMembershipUser + ` REFERENCES users(id) ON DELETE CASCADE,
+				PRIMARY KEY (community_id, user_id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS feature_flags (
+				name TEXT PRIMARY KEY,
+				enabled BOOLEAN NOT NULL DEFAULT FALSE,
+				percentage INTEGER NOT NULL DEFAULT 0,
+				communities JSONB NOT NULL DEFAULT '[]',
+				updated_at TIMESTAMPTZ NOT NULL
+			);`,
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		if err := migrateTextIDs(ctx, tx); err != nil {
+			return fmt.Errorf("migrate ids to uuid: %w", err)
+		}
+		if err := migrateFlagCommunities(ctx, tx); err != nil {
+			return fmt.Errorf("migrate feature flag communities to jsonb: %w", err)
+		}
+		if err := migrateSlugs(ctx, tx); err != nil {
+			return fmt.Errorf("migrate community slugs: %w", err)
+		}
+		if err := migrateVisibility(ctx, tx); err != nil {
+			return fmt.Errorf("migrate community visibility: %w", err)
+		}
+		if err := migrateSubscriptions(ctx, tx); err != nil {
+			return fmt.Errorf("migrate member subscriptions: %w", err)
+		}
+		_, err := tx.Exec(ctx, `CREATE INDEX IF NOT EXISTS posts_community_created_idx ON posts (community_id, created_at DESC, id DESC)`)
+		return err
+	})
+}
+
+// migrateTextIDs converts ID columns created as TEXT by earlier versions to
+// UUID. The foreign keys are dropped and recreated around the change because
+// both ends of a key must change type together.
+func migrateTextIDs(ctx context.Context, tx pgx.Tx) error {
+	typ, err := columnType(ctx, tx, "communities", "id")
+	if err != nil || typ != "text" {
+		return err
+	}
 	stmts := []string{
-		`CREATE TABLE IF NOT EXISTS communities (
-			id TEXT PRIMARY KEY,
+		`ALTER TABLE posts DROP CONSTRAINT IF EXISTS ` + fkPostCommunity,
+		`ALTER TABLE community_memberships DROP CONSTRAINT IF EXISTS ` + fkMembershipCommunity + `, DROP CONSTRAINT IF EXISTS ` + fkMembershipUser,
+		`ALTER TABLE communities ALTER COLUMN id TYPE UUID USING id::uuid`,
+		`ALTER TABLE users ALTER COLUMN id TYPE UUID USING id::uuid`,
+		`ALTER TABLE posts ALTER COLUMN id TYPE UUID USING id::uuid, ALTER COLUMN community_id TYPE UUID USING community_id::uuid`,
+		`ALTER TABLE community_memberships ALTER COLUMN community_id TYPE UUID USING community_id::uuid, ALTER COLUMN user_id TYPE UUID USING user_id::uuid`,
+		`ALTER TABLE posts ADD CONSTRAINT ` + fkPostCommunity + ` FOREIGN KEY (community_id) REFERENCES communities(id) ON DELETE CASCADE`,
+		`ALTER TABLE community_memberships
+			ADD CONSTRAINT ` + fkMembershipCommunity + ` FOREIGN KEY (community_id) REFERENCES communities(id) ON DELETE CASCADE,
+			ADD CONSTRAINT ` + fkMembershipUser + ` FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateFlagCommunities converts the JSON-encoded TEXT column created by
+// earlier versions to JSONB.
+func migrateFlagCommunities(ctx context.Context, tx pgx.Tx) error {
+	typ, err := columnType(ctx, tx, "feature_flags", "communities")
+	if err != nil || typ != "text" {
+		return err
+	}
+	_, err = tx.Exec(ctx, `ALTER TABLE feature_flags
+		ALTER COLUMN communities DROP DEFAULT,
+		ALTER COLUMN communities TYPE JSONB USING communities::jsonb,
+		ALTER COLUMN communities SET DEFAULT '[]'`)
+	return err
+}
+
+// migrateSlugs creates the slug history table, which must follow the UUID
+// migration, and generates a slug for every community created before slugs
+// existed, in list order.
+func migrateSlugs(ctx context.Context, tx pgx.Tx) error {
+	stmts := []string{
+		`ALTER TABLE communities ADD COLUMN IF NOT EXISTS slug TEXT`,
+		`CREATE TABLE IF NOT EXISTS community_slugs (
+			slug TEXT PRIMARY KEY,
+			community_id UUID NOT NULL CONSTRAINT ` + fkSlugCommunity + ` REFERENCES communities(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS community_slugs_community_idx ON community_slugs (community_id)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	rows, _ := tx.Query(ctx, `SELECT id, name FROM communities WHERE slug IS NULL ORDER BY name, id`)
+	pending, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Community, error) {
+		var c Community
+		err := row.Scan(&c.ID, &c.Name)
+		return c, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range pending {
+		slug, err := freeSlug(slugify(c.Name), pgSlugTaken(ctx, tx, statements[stmtSlugTaken]))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE communities SET slug = $1 WHERE id = $2`, slug, c.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, statements[stmtAddSlug], slug, c.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE communities ALTER COLUMN slug SET NOT NULL`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS communities_slug_idx ON communities (slug)`)
+	return err
+}
+
+// migrateVisibility makes communities and members from before private
+// communities existed public and plain members, and creates the invite and
+// join request tables, which must follow the UUID migration.
+func migrateVisibility(ctx context.Context, tx pgx.Tx) error {
+	stmts := []string{
+		`ALTER TABLE communities ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'`,
+		`ALTER TABLE community_memberships ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member'`,
+		`CREATE INDEX IF NOT EXISTS community_memberships_user_idx ON community_memberships (user_id)`,
+		`CREATE TABLE IF NOT EXISTS community_invites (
+			code TEXT PRIMARY KEY,
+			community_id UUID NOT NULL CONSTRAINT ` + fkInviteCommunity + ` REFERENCES communities(id) ON DELETE CASCADE,
+			expires_at TIMESTAMPTZ,
+			max_uses INTEGER NOT NULL DEFAULT 0,
+			uses INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS join_requests (
+			id UUID PRIMARY KEY,
+			community_id UUID NOT NULL CONSTRAINT ` + fkJoinRequestCommunity + ` REFERENCES communities(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL CONSTRAINT ` + fkJoinRequestUser + ` REFERENCES users(id) ON DELETE CASCADE,
+			status TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			decided_at TIMESTAMPTZ
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS join_requests_pending_idx ON join_requests (community_id, user_id) WHERE status = 'pending'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSubscriptions creates the plan table and adds the subscription
+// columns to memberships, which must follow the UUID migration.
+func migrateSubscriptions(ctx context.Context, tx pgx.Tx) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS community_plans (
+			id UUID PRIMARY KEY,
+			community_id UUID NOT NULL CONSTRAINT ` + fkPlanCommunity + ` REFERENCES communities(id) ON DELETE CASCADE,
 			name TEXT NOT NULL,
-			description TEXT DEFAULT ''
-		);`,
-		`CREATE TABLE IF NOT EXISTS posts (
-			id TEXT PRIMARY KEY,
-			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
-			author_id TEXT,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
+			price_cents BIGINT NOT NULL,
+			currency TEXT NOT NULL,
+			billing_interval TEXT NOT NULL,
+			trial_days INTEGER NOT NULL DEFAULT 0,
 			created_at TIMESTAMPTZ NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS users (
+		)`,
+		`CREATE INDEX IF NOT EXISTS community_plans_community_idx ON community_plans (community_id)`,
+		`ALTER TABLE community_memberships
+			ADD COLUMN IF NOT EXISTS subscription_id TEXT,
+			ADD COLUMN IF NOT EXISTS plan_id UUID,
+			ADD COLUMN IF NOT EXISTS subscription_status TEXT,
+			ADD COLUMN IF NOT EXISTS current_period_end TIMESTAMPTZ`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS community_memberships_subscription_idx ON community_memberships (subscription_id)`,
+		`CREATE TABLE IF NOT EXISTS billing_events (
 			id TEXT PRIMARY KEY,
-			email TEXT NOT NULL,
-			name TEXT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS community_memberships (
-			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
-			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			PRIMARY KEY (community_id, user_id)
-		);`,
+			subscription_id TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`,
 	}
-
 	for _, stmt := range stmts {
-		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// pgSlugTaken reports whether a slug is in use, currently or formerly. query
+// is stmtSlugTaken, or its SQL on connections without prepared statements.
+func pgSlugTaken(ctx context.Context, tx pgx.Tx, query string) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		var taken bool
+		err := tx.QueryRow(ctx, query, slug).Scan(&taken)
+		return taken, err
+	}
+}
+
+func columnType(ctx context.Context, tx pgx.Tx, table, column string) (string, error) {
+	var typ string
+	err := tx.QueryRow(ctx, `SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`, table, column).Scan(&typ)
+	return typ, err
+}
+
 func (s *PostgresStore) ListCommunities(ctx context.Context) ([]Community, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, name, description FROM communities ORDER BY name`)
+	return routeRead(ctx, s, func(pool *pgxpool.Pool) ([]Community, error) {
+		rows, _ := pool.Query(ctx, stmtListCommunities)
+		return collectNonNil(rows, scanCommunity)
+	})
+}
+
+func (s *PostgresStore) GetCommunity(ctx context.Context, communityID string) (CommunityDetail, error) {
+	id, ok := parseID(communityID)
+	if !ok {
+		return CommunityDetail{}, ErrCommunityNotFound
+	}
+	var c CommunityDetail
+	err := s.pool.QueryRow(ctx, stmtGetCommunity, id).
+		Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Visibility, &c.MemberCount, &c.AdminCount, &c.PostCount, &c.PlanCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CommunityDetail{}, ErrCommunityNotFound
+	}
 	if err != nil {
-		return nil, err
+		return CommunityDetail{}, err
 	}
-	defer rows.Close()
+	return c, nil
+}
 
-	var communities []Community
-	for rows.Next() {
-		var c Community
-		if err := rows.Scan(&c.ID, &c.Name, &c.Description); err != nil {
-			return nil, err
-		}
-		communities = append(communities, c)
+func (s *PostgresStore) GetCommunityBySlug(ctx context.Context, slug string) (Community, error) {
+	rows, _ := s.pool.Query(ctx, stmtCommunityBySlug, slug)
+	c, err := pgx.CollectExactlyOneRow(rows, scanCommunity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Community{}, ErrCommunityNotFound
 	}
-	return communities, rows.Err()
+	return c, err
 }
 
 func (s *PostgresStore) CreateCommunity(ctx context.Context, input CommunityInput) (Community, error) {
-	if input.Name == "" {
-		return Community{}, fmt.Errorf("name is required")
+	if err := validateCommunity(input); err != nil {
+		return Community{}, err
 	}
+	var adminID uuid.UUID
+	if input.AdminID != "" {
+		var ok bool
+		if adminID, ok = parseID(input.AdminID); !ok {
+			return Community{}, ErrUserNotFound
+		}
+	}
+	id := uuid.New()
 	community := Community{
-		ID:          newID(),
+		ID:          id.String(),
 		Name:        input.Name,
 		Description: input.Description,
+		Visibility:  visibilityOrDefault(input.Visibility),
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			community.Slug = input.Slug
+			if community.Slug == "" {
+				slug, err := freeSlug(slugify(community.Name), pgSlugTaken(ctx, tx, stmtSlugTaken))
+				if err != nil {
+					return err
+				}
+				community.Slug = slug
+			}
+			if _, err := tx.Exec(ctx, stmtCreateCommunity, id, community.Slug, community.Name, community.Description, community.Visibility); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, stmtAddSlug, community.Slug, id); err != nil {
+				return err
+			}
+			if input.AdminID == "" {
+				return nil
+			}
+			_, err := tx.Exec(ctx, stmtInsertMember, id, adminID, RoleAdmin)
+			if constraint, ok := foreignKeyViolation(err); ok && constraint == fkMembershipUser {
+				return ErrUserNotFound
+			}
+			return err
+		})
+		if isUniqueViolation(err) {
+			// Another writer claimed the slug since it was checked.
+			if input.Slug != "" || attempt == maxSlugAttempts {
+				return Community{}, ErrSlugTaken
+			}
+			continue
+		}
+		if err != nil {
+			return Community{}, err
+		}
+		s.reads.wrote(ctx)
+		return community, nil
+	}
+}
+
+func (s *PostgresStore) UpdateCommunity(ctx context.Context, communityID string, input CommunityInput) (Community, error) {
+	if err := validateCommunity(input); err != nil {
+		return Community{}, err
+	}
+	id, ok := parseID(communityID)
+	if !ok {
+		return Community{}, ErrCommunityNotFound
 	}
+	community := Community{ID: id.String(), Name: input.Name, Description: input.Description}
 
-	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO communities (id, name, description) VALUES ($1, $2, $3)`,
-		community.ID, community.Name, community.Description)
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, stmtLockCommunity, id).Scan(&community.Slug, &community.Visibility)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCommunityNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if input.Slug != "" && input.Slug != community.Slug {
+			// The old slug stays in community_slugs as a redirect. A community
+			// may take back one of its own former slugs.
+			var owner string
+			err := tx.QueryRow(ctx, stmtSlugOwner, input.Slug).Scan(&owner)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				if _, err := tx.Exec(ctx, stmtAddSlug, input.Slug, id); err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			case owner != community.ID:
+				return ErrSlugTaken
+			}
+			community.Slug = input.Slug
+		}
+		if input.Visibility != "" {
+			community.Visibility = input.Visibility
+		}
+		_, err = tx.Exec(ctx, stmtUpdateCommunity, id, community.Slug, community.Name, community.Description, community.Visibility)
+		return err
+	})
+	if isUniqueViolation(err) {
+		return Community{}, ErrSlugTaken
+	}
 	if err != nil {
 		return Community{}, err
 	}
+	s.reads.wrote(ctx)
 	return community, nil
 }
 
 func (s *PostgresStore) DeleteCommunity(ctx context.Context, communityID string) error {
-	res, err := s.db.ExecContext(ctx, `DELETE FROM communities WHERE id = $1`, communityID)
-	if err != nil {
-		return err
+	id, ok := parseID(communityID)
+	if !ok {
+		return ErrCommunityNotFound
 	}
-	rows, err := res.RowsAffected()
+	tag, err := s.pool.Exec(ctx, stmtDeleteCommunity, id)
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
+	if tag.RowsAffected() == 0 {
 		return ErrCommunityNotFound
 	}
+	s.reads.wrote(ctx)
 	return nil
 }
 
 func (s *PostgresStore) ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, community_id, author_id, title, content, created_at FROM posts WHERE community_id = $1 ORDER BY created_at DESC`, communityID)
-	if err != nil {
-		return nil, err
+	id, ok := parseID(communityID)
+	if !ok {
+		return nil, ErrCommunityNotFound
 	}
-	defer rows.Close()
 
-	var posts []Post
-	for rows.Next() {
-		var p Post
-		if err := rows.Scan(&p.ID, &p.CommunityID, &p.AuthorID, &p.Title, &p.Content, &p.CreatedAt); err != nil {
-			return nil, err
-		}
-		posts = append(posts, p)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+	return routeRead(ctx, s, func(pool *pgxpool.Pool) ([]Post, error) {
+		// Check existence and read the posts in one round trip, so an empty
+		// community is told apart from a missing one.
+		batch := &pgx.Batch{}
+		batch.Queue(stmtCommunityExists, id)
+		batch.Queue(stmtListPosts, id)
+		results := pool.SendBatch(ctx, batch)
+		defer results.Close()
 
-	if len(posts) == 0 {
-		// Check community existence to mirror in-memory behavior.
 		var exists bool
-		if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM communities WHERE id = $1)`, communityID).Scan(&exists); err != nil {
+		if err := results.QueryRow().Scan(&exists); err != nil {
+			return nil, err
+		}
+		rows, _ := results.Query()
+		posts, err := collectNonNil(rows, scanPost)
+		if err != nil {
 			return nil, err
 		}
 		if !exists {
 			return nil, ErrCommunityNotFound
 		}
+		return posts, nil
+	})
+}
+
+func (s *PostgresStore) GetPost(ctx context.Context, communityID, postID string) (Post, error) {
+	cid, ok := parseID(communityID)
+	if !ok {
+		return Post{}, ErrCommunityNotFound
+	}
+	id, ok := parseID(postID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return Post{}, err
+		}
+		return Post{}, ErrPostNotFound
 	}
 
-	return posts, nil
+	rows, _ := s.pool.Query(ctx, stmtGetPost, id, cid)
+	post, err := pgx.CollectExactlyOneRow(rows, scanPost)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return Post{}, err
+		}
+		return Post{}, ErrPostNotFound
+	}
+	return post, err
 }
 
 func (s *PostgresStore) CreatePost(ctx context.Context, communityID string, input PostInput) (Post, error) {
@@ -167,51 +869,696 @@ func (s *PostgresStore) CreatePost(ctx context.Context, communityID string, inpu
 	if input.Content == "" {
 		return Post{}, fmt.Errorf("content is required")
 	}
+	cid, ok := parseID(communityID)
+	if !ok {
+		return Post{}, ErrCommunityNotFound
+	}
 
+	id := uuid.New()
 	post := Post{
-		ID:          newID(),
-		CommunityID: communityID,
+		ID:          id.String(),
+		CommunityID: cid.String(),
 		AuthorID:    input.AuthorID,
 		Title:       input.Title,
 		Content:     input.Content,
-		CreatedAt:   time.Now().UTC(),
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
 	}
 
-	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO posts (id, community_id, author_id, title, content, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
-		post.ID, post.CommunityID, post.AuthorID, post.Title, post.Content, post.CreatedAt)
+	_, err := s.pool.Exec(ctx, stmtCreatePost, id, cid, post.AuthorID, post.Title, post.Content, post.CreatedAt)
 	if err != nil {
-		if isForeignKeyViolation(err) {
+		if constraint, ok := foreignKeyViolation(err); ok && constraint == fkPostCommunity {
 			return Post{}, ErrCommunityNotFound
 		}
 		return Post{}, err
 	}
-
+	s.reads.wrote(ctx)
 	return post, nil
 }
 
 func (s *PostgresStore) DeletePost(ctx context.Context, communityID, postID string) error {
-	res, err := s.db.ExecContext(ctx,
-		`DELETE FROM posts WHERE id = $1 AND community_id = $2`,
-		postID, communityID)
+	cid, ok := parseID(communityID)
+	if !ok {
+		return ErrCommunityNotFound
+	}
+	id, ok := parseID(postID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return err
+		}
+		return ErrPostNotFound
+	}
+
+	tag, err := s.pool.Exec(ctx, stmtDeletePost, id, cid)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return err
+		}
+		return ErrPostNotFound
+	}
+	s.reads.wrote(ctx)
+	return nil
+}
+
+func (s *PostgresStore) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := s.pool.QueryRow(ctx, stmtStats, time.Now().UTC().Add(-ActiveMemberWindow)).
+		Scan(&stats.Communities, &stats.Posts, &stats.ActiveMembers)
+	if err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
+
+func (s *PostgresStore) ListUsers(ctx context.Context) ([]User, error) {
+	rows, _ := s.pool.Query(ctx, stmtListUsers)
+	return collectNonNil(rows, scanUser)
+}
+
+func (s *PostgresStore) CreateUser(ctx context.Context, input UserInput) (User, error) {
+	if err := validateUser(input); err != nil {
+		return User{}, err
+	}
+	id := uuid.New()
+	user := User{ID: id.String(), Email: input.Email, Name: input.Name}
+
+	if _, err := s.pool.Exec(ctx, stmtCreateUser, id, user.Email, user.Name); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *PostgresStore) AddMember(ctx context.Context, communityID, userID string) error {
+	cid, ok := parseID(communityID)
+	if !ok {
+		return ErrCommunityNotFound
+	}
+	uid, ok := parseID(userID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return err
+		}
+		return ErrUserNotFound
+	}
+
+	_, err := s.pool.Exec(ctx, stmtAddMember, cid, uid)
+	if constraint, ok := foreignKeyViolation(err); ok {
+		switch constraint {
+		case fkMembershipCommunity:
+			return ErrCommunityNotFound
+		case fkMembershipUser:
+			return ErrUserNotFound
+		}
+	}
+	return err
+}
+
+func (s *PostgresStore) ListMembers(ctx context.Context, communityID string) ([]User, error) {
+	id, ok := parseID(communityID)
+	if !ok {
+		return nil, ErrCommunityNotFound
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(stmtCommunityExists, id)
+	batch.Queue(stmtListMembers, id)
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	var exists bool
+	if err := results.QueryRow().Scan(&exists); err != nil {
+		return nil, err
+	}
+	rows, _ := results.Query()
+	users, err := collectNonNil(rows, scanUser)
 	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCommunityNotFound
+	}
+	return users, nil
+}
+
+func (s *PostgresStore) GetMembership(ctx context.Context, communityID, userID string) (Membership, error) {
+	cid, ok := parseID(communityID)
+	if !ok {
+		return Membership{}, ErrCommunityNotFound
+	}
+	uid, ok := parseID(userID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return Membership{}, err
+		}
+		return Membership{}, ErrNotMember
+	}
+
+	rows, _ := s.pool.Query(ctx, stmtGetMembership, cid, uid)
+	m, err := pgx.CollectExactlyOneRow(rows, scanMembership)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return Membership{}, err
+		}
+		return Membership{}, ErrNotMember
+	}
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+func (s *PostgresStore) ListMemberships(ctx context.Context, userID string) ([]Membership, error) {
+	uid, ok := parseID(userID)
+	if !ok {
+		return []Membership{}, nil
+	}
+	rows, _ := s.pool.Query(ctx, stmtListMemberships, uid)
+	return collectNonNil(rows, scanMembership)
+}
+
+func (s *PostgresStore) CreateInvite(ctx context.Context, communityID string, input InviteInput) (Invite, error) {
+	if err := validateInvite(input); err != nil {
+		return Invite{}, err
+	}
+	cid, ok := parseID(communityID)
+	if !ok {
+		return Invite{}, ErrCommunityNotFound
+	}
+	invite := newInvite(cid.String(), input)
+
+	_, err := s.pool.Exec(ctx, stmtCreateInvite, invite.Code, cid, invite.ExpiresAt, invite.MaxUses, invite.CreatedAt)
+	if err != nil {
+		if constraint, ok := foreignKeyViolation(err); ok && constraint == fkInviteCommunity {
+			return Invite{}, ErrCommunityNotFound
+		}
+		return Invite{}, err
+	}
+	return invite, nil
+}
+
+func (s *PostgresStore) RedeemInvite(ctx context.Context, code, userID string) (Membership, error) {
+	var m Membership
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		rows, _ := tx.Query(ctx, stmtLockInvite, code)
+		invite, err := pgx.CollectExactlyOneRow(rows, scanInvite)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInviteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := invite.usable(time.Now()); err != nil {
+			return err
+		}
+		uid, ok := parseID(userID)
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		rows, _ = tx.Query(ctx, stmtGetMembership, invite.CommunityID, uid)
+		existing, err := pgx.CollectExactlyOneRow(rows, scanMembership)
+		if err == nil {
+			// Already a member: the invite is not used up.
+			m = existing
+			return nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		m = Membership{CommunityID: invite.CommunityID, UserID: uid.String(), Role: RoleMember}
+		_, err = tx.Exec(ctx, stmtInsertMember, invite.CommunityID, uid, m.Role)
+		if constraint, ok := foreignKeyViolation(err); ok && constraint == fkMembershipUser {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, stmtUseInvite, code)
 		return err
+	})
+	if err != nil {
+		return Membership{}, err
+	}
+	s.reads.wrote(ctx)
+	return m, nil
+}
+
+func (s *PostgresStore) CreateJoinRequest(ctx context.Context, communityID, userID string) (JoinRequest, error) {
+	cid, ok := parseID(communityID)
+	if !ok {
+		return JoinRequest{}, ErrCommunityNotFound
+	}
+	uid, ok := parseID(userID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return JoinRequest{}, err
+		}
+		return JoinRequest{}, ErrUserNotFound
+	}
+
+	// A user has at most one pending request per community; asking again
+	// returns it, including when a concurrent request wins the insert.
+	rows, _ := s.pool.Query(ctx, stmtPendingJoinReq, cid, uid)
+	req, err := pgx.CollectExactlyOneRow(rows, scanJoinRequest)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return req, err
+	}
+	id := uuid.New()
+	req = JoinRequest{
+		ID:          id.String(),
+		CommunityID: cid.String(),
+		UserID:      uid.String(),
+		Status:      JoinRequestPending,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+	_, err = s.pool.Exec(ctx, stmtCreateJoinReq, id, cid, uid, req.CreatedAt)
+	if isUniqueViolation(err) {
+		rows, _ := s.pool.Query(ctx, stmtPendingJoinReq, cid, uid)
+		return pgx.CollectExactlyOneRow(rows, scanJoinRequest)
+	}
+	if constraint, ok := foreignKeyViolation(err); ok {
+		switch constraint {
+		case fkJoinRequestCommunity:
+			return JoinRequest{}, ErrCommunityNotFound
+		case fkJoinRequestUser:
+			return JoinRequest{}, ErrUserNotFound
+		}
 	}
-	rows, err := res.RowsAffected()
 	if err != nil {
+		return JoinRequest{}, err
+	}
+	return req, nil
+}
+
+func (s *PostgresStore) ListJoinRequests(ctx context.Context, communityID string) ([]JoinRequest, error) {
+	id, ok := parseID(communityID)
+	if !ok {
+		return nil, ErrCommunityNotFound
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(stmtCommunityExists, id)
+	batch.Queue(stmtListJoinReqs, id)
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	var exists bool
+	if err := results.QueryRow().Scan(&exists); err != nil {
+		return nil, err
+	}
+	rows, _ := results.Query()
+	requests, err := collectNonNil(rows, scanJoinRequest)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCommunityNotFound
+	}
+	return requests, nil
+}
+
+func (s *PostgresStore) DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (JoinRequest, error) {
+	if err := validateDecision(status); err != nil {
+		return JoinRequest{}, err
+	}
+	cid, ok := parseID(communityID)
+	if !ok {
+		return JoinRequest{}, ErrCommunityNotFound
+	}
+	id, ok := parseID(requestID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return JoinRequest{}, err
+		}
+		return JoinRequest{}, ErrJoinRequestNotFound
+	}
+
+	var req JoinRequest
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		rows, _ := tx.Query(ctx, stmtLockJoinReq, id, cid)
+		var err error
+		if req, err = pgx.CollectExactlyOneRow(rows, scanJoinRequest); err != nil {
+			return err
+		}
+		if req.Status != JoinRequestPending {
+			return ErrJoinRequestDecided
+		}
+		decided := time.Now().UTC().Truncate(time.Microsecond)
+		req.Status, req.DecidedAt = status, &decided
+		if _, err := tx.Exec(ctx, stmtDecideJoinReq, id, req.Status, decided); err != nil {
+			return err
+		}
+		if status != JoinRequestApproved {
+			return nil
+		}
+		_, err = tx.Exec(ctx, stmtAddMember, cid, req.UserID)
 		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return JoinRequest{}, err
+		}
+		return JoinRequest{}, ErrJoinRequestNotFound
 	}
-	if rows == 0 {
-		return ErrPostNotFound
+	if err != nil {
+		return JoinRequest{}, err
+	}
+	s.reads.wrote(ctx)
+	return req, nil
+}
+
+func (s *PostgresStore) CreatePlan(ctx context.Context, communityID string, input PlanInput) (Plan, error) {
+	if err := validatePlan(input); err != nil {
+		return Plan{}, err
+	}
+	cid, ok := parseID(communityID)
+	if !ok {
+		return Plan{}, ErrCommunityNotFound
+	}
+	id := uuid.New()
+	plan := newPlan(cid.String(), input)
+	plan.ID = id.String()
+
+	_, err := s.pool.Exec(ctx, stmtCreatePlan, id, cid, plan.Name, plan.PriceCents, plan.Currency, plan.Interval, plan.TrialDays, plan.CreatedAt)
+	if err != nil {
+		if constraint, ok := foreignKeyViolation(err); ok && constraint == fkPlanCommunity {
+			return Plan{}, ErrCommunityNotFound
+		}
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+func (s *PostgresStore) ListPlans(ctx context.Context, communityID string) ([]Plan, error) {
+	id, ok := parseID(communityID)
+	if !ok {
+		return nil, ErrCommunityNotFound
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(stmtCommunityExists, id)
+	batch.Queue(stmtListPlans, id)
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	var exists bool
+	if err := results.QueryRow().Scan(&exists); err != nil {
+		return nil, err
+	}
+	rows, _ := results.Query()
+	plans, err := collectNonNil(rows, scanPlan)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCommunityNotFound
+	}
+	return plans, nil
+}
+
+func (s *PostgresStore) GetPlan(ctx context.Context, communityID, planID string) (Plan, error) {
+	cid, ok := parseID(communityID)
+	if !ok {
+		return Plan{}, ErrCommunityNotFound
+	}
+	id, ok := parseID(planID)
+	if !ok {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return Plan{}, err
+		}
+		return Plan{}, ErrPlanNotFound
+	}
+
+	rows, _ := s.pool.Query(ctx, stmtGetPlan, id, cid)
+	plan, err := pgx.CollectExactlyOneRow(rows, scanPlan)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := s.requireCommunity(ctx, cid); err != nil {
+			return Plan{}, err
+		}
+		return Plan{}, ErrPlanNotFound
+	}
+	return plan, err
+}
+
+func (s *PostgresStore) SetSubscription(ctx context.Context, communityID, userID string, sub Subscription) (Membership, error) {
+	if err := validateSubscription(sub); err != nil {
+		return Membership{}, err
+	}
+	sub.CurrentPeriodEnd = truncatePeriodEnd(sub.CurrentPeriodEnd)
+	if _, err := s.GetPlan(ctx, communityID, sub.PlanID); err != nil {
+		return Membership{}, err
+	}
+	cid, _ := parseID(communityID)
+	pid, _ := parseID(sub.PlanID)
+	uid, ok := parseID(userID)
+	if !ok {
+		return Membership{}, ErrUserNotFound
+	}
+
+	rows, _ := s.pool.Query(ctx, stmtSetSubscription, cid, uid, sub.ID, pid, sub.Status, sub.CurrentPeriodEnd)
+	m, err := pgx.CollectExactlyOneRow(rows, scanMembership)
+	if constraint, ok := foreignKeyViolation(err); ok {
+		switch constraint {
+		case fkMembershipCommunity:
+			return Membership{}, ErrCommunityNotFound
+		case fkMembershipUser:
+			return Membership{}, ErrUserNotFound
+		}
+	}
+	if err != nil {
+		return Membership{}, err
+	}
+	s.reads.wrote(ctx)
+	return m, nil
+}
+
+func (s *PostgresStore) UpdateSubscription(ctx context.Context, subscriptionID string, update SubscriptionUpdate) (Membership, error) {
+	if err := validateSubscriptionStatus(update.Status); err != nil {
+		return Membership{}, err
+	}
+
+	var m Membership
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		rows, _ := tx.Query(ctx, stmtLockSubscription, subscriptionID)
+		var err error
+		if m, err = pgx.CollectExactlyOneRow(rows, scanMembership); err != nil {
+			return err
+		}
+		if update.EventID != "" {
+			tag, err := tx.Exec(ctx, stmtRecordBillingEvent, update.EventID, subscriptionID)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return ErrDuplicateEvent
+			}
+		}
+		sub, err := applySubscriptionUpdate(*m.Subscription, update)
+		if err != nil {
+			return err
+		}
+		m.Subscription = &sub
+		_, err = tx.Exec(ctx, stmtUpdateSubscription, subscriptionID, sub.Status, sub.CurrentPeriodEnd)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Membership{}, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return Membership{}, err
+	}
+	s.reads.wrote(ctx)
+	return m, nil
+}
+
+// requireCommunity returns ErrCommunityNotFound when id does not exist.
+func (s *PostgresStore) requireCommunity(ctx context.Context, id uuid.UUID) error {
+	var exists bool
+	if err := s.pool.QueryRow(ctx, stmtCommunityExists, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrCommunityNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	rows, _ := s.pool.Query(ctx, stmtListFeatureFlags)
+	return collectNonNil(rows, scanFeatureFlag)
+}
+
+func (s *PostgresStore) GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error) {
+	rows, _ := s.pool.Query(ctx, stmtGetFeatureFlag, name)
+	f, err := pgx.CollectExactlyOneRow(rows, scanFeatureFlag)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return FeatureFlag{}, ErrFeatureFlagNotFound
+	}
+	return f, err
+}
+
+func (s *PostgresStore) UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (FeatureFlag, error) {
+	if err := validateFeatureFlag(name, input); err != nil {
+		return FeatureFlag{}, err
+	}
+	flag := FeatureFlag{
+		Name:        name,
+		Enabled:     input.Enabled,
+		Percentage:  input.Percentage,
+		Communities: append([]string{}, input.Communities...),
+		UpdatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	_, err := s.pool.Exec(ctx, stmtUpsertFeatureFlag, flag.Name, flag.Enabled, flag.Percentage, flag.Communities, flag.UpdatedAt)
+	if err != nil {
+		return FeatureFlag{}, err
+	}
+	return flag, nil
+}
+
+func (s *PostgresStore) DeleteFeatureFlag(ctx context.Context, name string) error {
+	tag, err := s.pool.Exec(ctx, stmtDeleteFeatureFlag, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFeatureFlagNotFound
 	}
 	return nil
 }
 
-func isForeignKeyViolation(err error) bool {
-	var pqErr interface{ SQLState() string }
-	if errors.As(err, &pqErr) {
-		// Postgres foreign key violation code.
-		return pqErr.SQLState() == "23503"
+// collectNonNil collects rows like pgx.CollectRows but returns an empty slice,
+// not nil, when there are none.
+func collectNonNil[T any](rows pgx.Rows, scan pgx.RowToFunc[T]) ([]T, error) {
+	items, err := pgx.CollectRows(rows, scan)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []T{}
+	}
+	return items, nil
+}
+
+func scanCommunity(row pgx.CollectableRow) (Community, error) {
+	var c Community
+	err := row.Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Visibility)
+	return c, err
+}
+
+// pgMembershipColumns are the columns scanMembership reads.
+const pgMembershipColumns = `community_id, user_id, role, subscription_id, plan_id, subscription_status, current_period_end`
+
+func scanMembership(row pgx.CollectableRow) (Membership, error) {
+	var (
+		m             Membership
+		subID, planID *string
+		status        *SubscriptionStatus
+		periodEnd     *time.Time
+	)
+	if err := row.Scan(&m.CommunityID, &m.UserID, &m.Role, &subID, &planID, &status, &periodEnd); err != nil {
+		return Membership{}, err
+	}
+	if subID != nil {
+		m.Subscription = &Subscription{ID: *subID, CurrentPeriodEnd: utcPtr(periodEnd)}
+		if planID != nil {
+			m.Subscription.PlanID = *planID
+		}
+		if status != nil {
+			m.Subscription.Status = *status
+		}
 	}
-	return false
+	return m, nil
+}
+
+// pgPlanColumns are the columns scanPlan reads.
+const pgPlanColumns = `id, community_id, name, price_cents, currency, billing_interval, trial_days, created_at`
+
+func scanPlan(row pgx.CollectableRow) (Plan, error) {
+	var plan Plan
+	if err := row.Scan(&plan.ID, &plan.CommunityID, &plan.Name, &plan.PriceCents, &plan.Currency, &plan.Interval, &plan.TrialDays, &plan.CreatedAt); err != nil {
+		return Plan{}, err
+	}
+	plan.CreatedAt = plan.CreatedAt.UTC()
+	return plan, nil
+}
+
+func scanInvite(row pgx.CollectableRow) (Invite, error) {
+	var invite Invite
+	if err := row.Scan(&invite.Code, &invite.CommunityID, &invite.ExpiresAt, &invite.MaxUses, &invite.Uses, &invite.CreatedAt); err != nil {
+		return Invite{}, err
+	}
+	invite.ExpiresAt = utcPtr(invite.ExpiresAt)
+	invite.CreatedAt = invite.CreatedAt.UTC()
+	return invite, nil
+}
+
+func scanJoinRequest(row pgx.CollectableRow) (JoinRequest, error) {
+	var req JoinRequest
+	if err := row.Scan(&req.ID, &req.CommunityID, &req.UserID, &req.Status, &req.CreatedAt, &req.DecidedAt); err != nil {
+		return JoinRequest{}, err
+	}
+	req.CreatedAt = req.CreatedAt.UTC()
+	req.DecidedAt = utcPtr(req.DecidedAt)
+	return req, nil
+}
+
+// utcPtr converts an optional timestamp read from TIMESTAMPTZ to UTC.
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func scanPost(row pgx.CollectableRow) (Post, error) {
+	var p Post
+	if err := row.Scan(&p.ID, &p.CommunityID, &p.AuthorID, &p.Title, &p.Content, &p.CreatedAt); err != nil {
+		return Post{}, err
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+	return p, nil
+}
+
+func scanUser(row pgx.CollectableRow) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Email, &u.Name)
+	return u, err
+}
+
+func scanFeatureFlag(row pgx.CollectableRow) (FeatureFlag, error) {
+	var f FeatureFlag
+	if err := row.Scan(&f.Name, &f.Enabled, &f.Percentage, &f.Communities, &f.UpdatedAt); err != nil {
+		return FeatureFlag{}, err
+	}
+	if f.Communities == nil {
+		f.Communities = []string{}
+	}
+	f.UpdatedAt = f.UpdatedAt.UTC()
+	return f, nil
+}
+
+// parseID reports whether id is a UUID, the only form of ID the store issues.
+func parseID(id string) (uuid.UUID, bool) {
+	parsed, err := uuid.Parse(id)
+	return parsed, err == nil
+}
+
+// foreignKeyViolation returns the violated constraint's name when err is a
+// Postgres foreign key violation.
+func foreignKeyViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
+
+// isUniqueViolation reports whether err is a Postgres unique violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
 }
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Read routing targets, as reported by metrics.ObserveDBRead.
+const (
+	readReplica         = "replica"
+	readPrimary         = "primary"
+	readPrimaryPinned   = "primary_pinned"
+	readPrimaryFallback = "primary_fallback"
+)
+
+type sessionKey struct{}
+
+// WithSession tags ctx with the caller's session so a store with read
+// replicas can route that session's reads to the primary shortly after it
+// writes (read-your-writes). Contexts without a session are never pinned.
+func WithSession(ctx context.Context, session string) context.Context {
+	if session == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFrom(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// ReplicaOptions tunes read replica routing.
+type ReplicaOptions struct {
+	// PinWindow is how long a session's reads go to the primary after it
+	// writes, covering replication lag.
+	PinWindow time.Duration
+	// CheckInterval is how often replicas are pinged; a replica that fails a
+	// ping or a query is skipped until it answers a ping again.
+	CheckInterval time.Duration
+}
+
+// DefaultReplicaOptions returns the routing settings used when none are configured.
+func DefaultReplicaOptions() ReplicaOptions {
+	return ReplicaOptions{PinWindow: 2 * time.Second, CheckInterval: 5 * time.Second}
+}
+
+// replica is one read-only target.
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	ping    func(context.Context) error
+	healthy atomic.Bool
+}
+
+// readRouter picks where reads go: healthy replicas in turn, or the primary
+// when none are healthy or the session wrote within the pin window.
+type readRouter struct {
+	replicas []*replica
+	opts     ReplicaOptions
+	logger   *zap.Logger
+	now      func() time.Time
+	next     atomic.Uint64
+
+	mu        sync.Mutex
+	lastWrite map[string]time.Time
+}
+
+func newReadRouter(replicas []*replica, opts ReplicaOptions, logger *zap.Logger) *readRouter {
+	for _, r := range replicas {
+		r.healthy.Store(true)
+	}
+	return &readRouter{
+		replicas:  replicas,
+		opts:      opts,
+		logger:    logger,
+		now:       time.Now,
+		lastWrite: make(map[string]time.Time),
+	}
+}
+
+// pick returns the replica to read from, or nil and the reason the primary
+// should serve the read instead.
+func (r *readRouter) pick(ctx context.Context) (*replica, string) {
+	if len(r.replicas) == 0 {
+		return nil, readPrimary
+	}
+	if session := sessionFrom(ctx); session != "" {
+		r.mu.Lock()
+		wrote, ok := r.lastWrite[session]
+		r.mu.Unlock()
+		if ok && r.now().Sub(wrote) < r.opts.PinWindow {
+			return nil, readPrimaryPinned
+		}
+	}
+	start := r.next.Add(1)
+	for i := range len(r.replicas) {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep, readReplica
+		}
+	}
+	return nil, readPrimaryFallback
+}
+
+// wrote records a mutation by ctx's session and forgets sessions whose pin
+// window has passed.
+func (r *readRouter) wrote(ctx context.Context) {
+	session := sessionFrom(ctx)
+	if session == "" || len(r.replicas) == 0 {
+		return
+	}
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastWrite[session] = now
+	for s, at := range r.lastWrite {
+		if now.Sub(at) >= r.opts.PinWindow {
+			delete(r.lastWrite, s)
+		}
+	}
+}
+
+// markDown takes rep out of rotation until the next successful ping.
+func (r *readRouter) markDown(rep *replica, err error) {
+	if rep.healthy.CompareAndSwap(true, false) {
+		r.logger.Warn("read replica unavailable, reading from primary", zap.String("replica", rep.name), zap.Error(err))
+	}
+}
+
+// check pings every replica once and updates its health.
+func (r *readRouter) check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		err := rep.ping(pingCtx)
+		cancel()
+		if err != nil {
+			r.markDown(rep, err)
+			continue
+		}
+		if rep.healthy.CompareAndSwap(false, true) {
+			r.logger.Info("read replica recovered", zap.String("replica", rep.name))
+		}
+	}
+}
+
+// run checks replica health every CheckInterval until ctx is done.
+func (r *readRouter) run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestRouter(t *testing.T, pingErrs ...*error) *readRouter {
+	t.Helper()
+	var replicas []*replica
+	for i, errp := range pingErrs {
+		replicas = append(replicas, &replica{
+			name: fmt.Sprintf("replica-%d", i+1),
+			ping: func(context.Context) error { return *errp },
+		})
+	}
+	return newReadRouter(replicas, ReplicaOptions{PinWindow: time.Second, CheckInterval: time.Hour}, zaptest.NewLogger(t))
+}
+
+func TestReadRouterBalancesAndFailsOver(t *testing.T) {
+	var err1, err2 error
+	r := newTestRouter(t, &err1, &err2)
+	ctx := context.Background()
+
+	seen := make(map[string]int)
+	for range 4 {
+		rep, target := r.pick(ctx)
+		if target != readReplica {
+			t.Fatalf("expected replica, got %s", target)
+		}
+		seen[rep.name]++
+	}
+	if seen["replica-1"] != 2 || seen["replica-2"] != 2 {
+		t.Fatalf("expected reads spread evenly, got %v", seen)
+	}
+
+	err1 = errors.New("connection refused")
+	r.check(ctx)
+	for range 3 {
+		if rep, _ := r.pick(ctx); rep == nil || rep.name != "replica-2" {
+			t.Fatalf("expected only the healthy replica, got %+v", rep)
+		}
+	}
+
+	r.markDown(r.replicas[1], errors.New("query failed"))
+	if rep, target := r.pick(ctx); rep != nil || target != readPrimaryFallback {
+		t.Fatalf("expected primary fallback, got %+v %s", rep, target)
+	}
+
+	err1 = nil
+	r.check(ctx)
+	if rep, target := r.pick(ctx); rep == nil || target != readReplica {
+		t.Fatalf("expected recovered replicas back in rotation, got %s", target)
+	}
+}
+
+func TestReadRouterPinsSessionAfterWrite(t *testing.T) {
+	var ok error
+	r := newTestRouter(t, &ok)
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+
+	alice := WithSession(context.Background(), "alice")
+	bob := WithSession(context.Background(), "bob")
+	r.wrote(alice)
+	r.wrote(context.Background()) // no session: nothing to pin
+
+	if _, target := r.pick(alice); target != readPrimaryPinned {
+		t.Fatalf("expected writer to read from primary, got %s", target)
+	}
+	if _, target := r.pick(bob); target != readReplica {
+		t.Fatalf("expected other sessions to read from the replica, got %s", target)
+	}
+
+	now = now.Add(time.Second)
+	if _, target := r.pick(alice); target != readReplica {
+		t.Fatalf("expected pin to expire, got %s", target)
+	}
+	r.wrote(bob)
+	if _, pinned := r.lastWrite["alice"]; pinned || len(r.lastWrite) != 1 {
+		t.Fatalf("expected expired sessions to be forgotten, got %v", r.lastWrite)
+	}
+}
+
+func TestReadRouterWithoutReplicas(t *testing.T) {
+	r := newTestRouter(t)
+	ctx := WithSession(context.Background(), "alice")
+	r.wrote(ctx)
+	if rep, target := r.pick(ctx); rep != nil || target != readPrimary {
+		t.Fatalf("expected primary, got %+v %s", rep, target)
+	}
+	if len(r.lastWrite) != 0 {
+		t.Fatalf("expected no session tracking without replicas")
+	}
+}
+
+func TestReplicaUnavailable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"connection error", context.Background(), errors.New("dial tcp: connection refused"), true},
+		{"shutting down", context.Background(), &pgconn.PgError{Code: "57P01"}, true},
+		{"recovery conflict", context.Background(), &pgconn.PgError{Code: "40001"}, true},
+		{"query error", context.Background(), &pgconn.PgError{Code: "42P01"}, false},
+		{"not found", context.Background(), ErrCommunityNotFound, false},
+		{"caller canceled", canceled, context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := replicaUnavailable(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// maxSlugLength bounds slugs so vanity URLs stay readable.
+	maxSlugLength = 64
+	// fallbackSlug is used for names with no letters or digits to keep.
+	fallbackSlug = "community"
+)
+
+// slugify derives a URL-safe slug from name: accents are stripped, letters
+// are lowercased, and every other run of characters becomes one hyphen.
+// Characters outside ASCII that have no decomposition are dropped.
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.Is(unicode.Mn, r):
+			// Combining mark left over from decomposing an accented letter.
+		default:
+			hyphen = true
+		}
+	}
+	slug := trimSlug(b.String(), maxSlugLength)
+	if slug == "" || isID(slug) {
+		// A slug that parses as an ID would shadow lookups by ID.
+		return fallbackSlug
+	}
+	return slug
+}
+
+// trimSlug cuts slug to at most n bytes without leaving a trailing hyphen.
+func trimSlug(slug string, n int) string {
+	if len(slug) > n {
+		slug = slug[:n]
+	}
+	return strings.TrimRight(slug, "-")
+}
+
+// slugCandidate returns the n-th slug to try for base: base itself, then
+// base-2, base-3 and so on, shortening base so the result fits maxSlugLength.
+func slugCandidate(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+	suffix := "-" + strconv.Itoa(n)
+	return trimSlug(base, maxSlugLength-len(suffix)) + suffix
+}
+
+// freeSlug returns the first candidate for base that taken reports as free.
+func freeSlug(base string, taken func(string) (bool, error)) (string, error) {
+	for n := 1; ; n++ {
+		slug := slugCandidate(base, n)
+		ok, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return slug, nil
+		}
+	}
+}
+
+// validateSlug checks a slug chosen by the caller rather than generated.
+func validateSlug(slug string) error {
+	if len(slug) > maxSlugLength {
+		return fmt.Errorf("slug must be at most %d characters", maxSlugLength)
+	}
+	if slug != slugify(slug) || isID(slug) {
+		return fmt.Errorf("slug must be lowercase letters and digits separated by single hyphens")
+	}
+	return nil
+}
+
+// isID reports whether ref has the form of a community ID rather than a slug.
+func isID(ref string) bool {
+	_, err := uuid.Parse(ref)
+	return err == nil
+}
+
+// IsSlug reports whether ref, taken from a URL, should be looked up as a slug
+// rather than as an ID.
+func IsSlug(ref string) bool {
+	return ref != "" && !isID(ref)
+}
@@ -0,0 +1,1174 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/hcuri/skool-mvp-app/internal/metrics"
+)
+
+// SQLiteScheme prefixes DATABASE_URLs that select the SQLite store, e.g.
+// sqlite:///var/lib/skool/skool.db or sqlite://skool.db for a relative path.
+const SQLiteScheme = "sqlite://"
+
+// sqlitePragmas are applied to every connection. Foreign keys are off by
+// default in SQLite and the schema relies on them for cascades and
+// ErrCommunityNotFound.
+var sqlitePragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)", "synchronous(NORMAL)"}
+
+// SQLiteStore implements Store backed by an embedded SQLite database file. It
+// uses the same schema and error semantics as PostgresStore; timestamps are
+// stored as Unix nanoseconds so they sort correctly.
+type SQLiteStore struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewSQLiteStore opens (creating if needed) the database named by dsn, which
+// must start with SQLiteScheme, and ensures the schema exists. Query parameters
+// on dsn are passed through to the driver.
+func NewSQLiteStore(ctx context.Context, dsn string, logger *zap.Logger) (*SQLiteStore, error) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, SQLiteScheme), "?")
+	if path == "" {
+		return nil, fmt.Errorf("sqlite: %q has no database path", dsn)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: parse %q: %w", dsn, err)
+	}
+	for _, pragma := range sqlitePragmas {
+		params.Add("_pragma", pragma)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY under
+	// concurrent requests and keeps :memory: databases on one connection.
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+
+	if err := metrics.RegisterDBStats(db, "sqlite"); err != nil {
+		logger.Warn("register db pool metrics failed", zap.Error(err))
+	}
+
+	store := &SQLiteStore{db: db, logger: logger}
+	if err := store.initSchema(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	return store, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// HealthCheck pings the database, failing if it does not answer within healthCheckTimeout.
+func (s *SQLiteStore) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStore) initSchema(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS communities (
+			id TEXT PRIMARY KEY,
+			slug TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			visibility TEXT NOT NULL DEFAULT 'public'
+		);`,
+		`CREATE TABLE IF NOT EXISTS community_slugs (
+			slug TEXT PRIMARY KEY,
+			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS community_slugs_community ON community_slugs (community_id);`,
+		`CREATE TABLE IF NOT EXISTS posts (
+			id TEXT PRIMARY KEY,
+			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+			author_id TEXT,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS posts_community_created ON posts (community_id, created_at);`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			email TEXT NOT NULL,
+			name TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS community_memberships (
+			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role TEXT NOT NULL DEFAULT 'member',
+			PRIMARY KEY (community_id, user_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS community_memberships_user ON community_memberships (user_id);`,
+		`CREATE TABLE IF NOT EXISTS community_invites (
+			code TEXT PRIMARY KEY,
+			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+			expires_at INTEGER,
+			max_uses INTEGER NOT NULL DEFAULT 0,
+			uses INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS join_requests (
+			id TEXT PRIMARY KEY,
+			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			status TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			decided_at INTEGER
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS join_requests_pending ON join_requests (community_id, user_id) WHERE status = 'pending';`,
+		`CREATE TABLE IF NOT EXISTS community_plans (
+			id TEXT PRIMARY KEY,
+			community_id TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			price_cents INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			billing_interval TEXT NOT NULL,
+			trial_days INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS community_plans_community ON community_plans (community_id);`,
+		`CREATE TABLE IF NOT EXISTS billing_events (
+			id TEXT PRIMARY KEY,
+			subscription_id TEXT NOT NULL,
+			applied_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS feature_flags (
+			name TEXT PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			percentage INTEGER NOT NULL DEFAULT 0,
+			communities TEXT NOT NULL DEFAULT '[]',
+			updated_at INTEGER NOT NULL
+		);`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if err := s.migrateSlugs(ctx); err != nil {
+		return fmt.Errorf("migrate community slugs: %w", err)
+	}
+	// Communities and members from before visibility and roles existed are
+	// public and plain members.
+	if err := s.addColumn(ctx, "communities", "visibility", "TEXT NOT NULL DEFAULT 'public'"); err != nil {
+		return fmt.Errorf("migrate community visibility: %w", err)
+	}
+	if err := s.addColumn(ctx, "community_memberships", "role", "TEXT NOT NULL DEFAULT 'member'"); err != nil {
+		return fmt.Errorf("migrate member roles: %w", err)
+	}
+	for _, col := range []struct{ name, definition string }{
+		{"subscription_id", "TEXT"},
+		{"plan_id", "TEXT"},
+		{"subscription_status", "TEXT"},
+		{"current_period_end", "INTEGER"},
+	} {
+		if err := s.addColumn(ctx, "community_memberships", col.name, col.definition); err != nil {
+			return fmt.Errorf("migrate member subscriptions: %w", err)
+		}
+	}
+	for _, stmt := range []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS communities_slug ON communities (slug);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS community_memberships_subscription ON community_memberships (subscription_id);`,
+	} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSlugs adds the slug column to databases created before slugs
+// existed and generates one for every community that lacks it, in list order.
+func (s *SQLiteStore) migrateSlugs(ctx context.Context) error {
+	if err := s.addColumn(ctx, "communities", "slug", "TEXT"); err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id, name FROM communities WHERE slug IS NULL ORDER BY name, id`)
+		if err != nil {
+			return err
+		}
+		var pending []Community
+		for rows.Next() {
+			var c Community
+			if err := rows.Scan(&c.ID, &c.Name); err != nil {
+				rows.Close()
+				return err
+			}
+			pending = append(pending, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, c := range pending {
+			slug, err := freeSlug(slugify(c.Name), sqliteSlugTaken(ctx, tx))
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE communities SET slug = ? WHERE id = ?`, slug, c.ID); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO community_slugs (slug, community_id) VALUES (?, ?)`, slug, c.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addColumn adds column to table unless the table already has it, for
+// databases created before the column existed.
+func (s *SQLiteStore) addColumn(ctx context.Context, table, column, definition string) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, table, column).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+definition)
+	return err
+}
+
+// inTx runs fn in a transaction, committing if it returns nil. With a single
+// connection, fn must use tx rather than s.db.
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteSlugTaken reports whether a slug is in use, currently or formerly.
+func sqliteSlugTaken(ctx context.Context, tx *sql.Tx) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		var taken bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM community_slugs WHERE slug = ?)`, slug).Scan(&taken)
+		return taken, err
+	}
+}
+
+func (s *SQLiteStore) ListCommunities(ctx context.Context) ([]Community, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, slug, name, description, visibility FROM communities ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	communities := []Community{}
+	for rows.Next() {
+		var c Community
+		if err := rows.Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Visibility); err != nil {
+			return nil, err
+		}
+		communities = append(communities, c)
+	}
+	return communities, rows.Err()
+}
+
+func (s *SQLiteStore) GetCommunity(ctx context.Context, communityID string) (CommunityDetail, error) {
+	var c CommunityDetail
+	err := s.db.QueryRowContext(ctx, `SELECT c.id, c.slug, c.name, c.description, c.visibility,
+			(SELECT count(*) FROM community_memberships m WHERE m.community_id = c.id),
+			(SELECT count(*) FROM community_memberships m WHERE m.community_id = c.id AND m.role = 'admin'),
+			(SELECT count(*) FROM posts p WHERE p.community_id = c.id),
+			(SELECT count(*) FROM community_plans p WHERE p.community_id = c.id)
+		FROM communities c WHERE c.id = ?`, communityID).
+		Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Visibility, &c.MemberCount, &c.AdminCount, &c.PostCount, &c.PlanCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CommunityDetail{}, ErrCommunityNotFound
+	}
+	if err != nil {
+		return CommunityDetail{}, err
+	}
+	return c, nil
+}
+
+func (s *SQLiteStore) GetCommunityBySlug(ctx context.Context, slug string) (Community, error) {
+	var c Community
+	err := s.db.QueryRowContext(ctx, `SELECT c.id, c.slug, c.name, c.description, c.visibility
+		FROM community_slugs s JOIN communities c ON c.id = s.community_id
+		WHERE s.slug = ?`, slug).
+		Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Visibility)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Community{}, ErrCommunityNotFound
+	}
+	if err != nil {
+		return Community{}, err
+	}
+	return c, nil
+}
+
+func (s *SQLiteStore) CreateCommunity(ctx context.Context, input CommunityInput) (Community, error) {
+	if err := validateCommunity(input); err != nil {
+		return Community{}, err
+	}
+	community := Community{
+		ID:          newID(),
+		Slug:        input.Slug,
+		Name:        input.Name,
+		Description: input.Description,
+		Visibility:  visibilityOrDefault(input.Visibility),
+	}
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		taken := sqliteSlugTaken(ctx, tx)
+		if community.Slug == "" {
+			slug, err := freeSlug(slugify(community.Name), taken)
+			if err != nil {
+				return err
+			}
+			community.Slug = slug
+		} else {
+			ok, err := taken(community.Slug)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return ErrSlugTaken
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO communities (id, slug, name, description, visibility) VALUES (?, ?, ?, ?, ?)`,
+			community.ID, community.Slug, community.Name, community.Description, community.Visibility); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO community_slugs (slug, community_id) VALUES (?, ?)`, community.Slug, community.ID); err != nil {
+			return err
+		}
+		if input.AdminID == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO community_memberships (community_id, user_id, role) VALUES (?, ?, ?)`,
+			community.ID, input.AdminID, RoleAdmin)
+		if isSQLiteForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return Community{}, err
+	}
+	return community, nil
+}
+
+func (s *SQLiteStore) UpdateCommunity(ctx context.Context, communityID string, input CommunityInput) (Community, error) {
+	if err := validateCommunity(input); err != nil {
+		return Community{}, err
+	}
+	community := Community{ID: communityID, Name: input.Name, Description: input.Description}
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `SELECT slug, visibility FROM communities WHERE id = ?`, communityID).
+			Scan(&community.Slug, &community.Visibility)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCommunityNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if input.Slug != "" && input.Slug != community.Slug {
+			// The old slug stays in community_slugs as a redirect. A community
+			// may take back one of its own former slugs.
+			var owner string
+			err := tx.QueryRowContext(ctx, `SELECT community_id FROM community_slugs WHERE slug = ?`, input.Slug).Scan(&owner)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				if _, err := tx.ExecContext(ctx, `INSERT INTO community_slugs (slug, community_id) VALUES (?, ?)`, input.Slug, communityID); err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			case owner != communityID:
+				return ErrSlugTaken
+			}
+			community.Slug = input.Slug
+		}
+		if input.Visibility != "" {
+			community.Visibility = input.Visibility
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE communities SET slug = ?, name = ?, description = ?, visibility = ? WHERE id = ?`,
+			community.Slug, community.Name, community.Description, community.Visibility, communityID)
+		return err
+	})
+	if err != nil {
+		return Community{}, err
+	}
+	return community, nil
+}
+
+func (s *SQLiteStore) DeleteCommunity(ctx context.Context, communityID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM communities WHERE id = ?`, communityID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCommunityNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, community_id, author_id, title, content, created_at FROM posts WHERE community_id = ? ORDER BY created_at DESC, id DESC`, communityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var (
+			p         Post
+			createdAt int64
+		)
+		if err := rows.Scan(&p.ID, &p.CommunityID, &p.AuthorID, &p.Title, &p.Content, &createdAt); err != nil {
+			return nil, err
+		}
+		p.CreatedAt = time.Unix(0, createdAt).UTC()
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return nil, err
+		}
+	}
+	return posts, nil
+}
+
+func (s *SQLiteStore) GetPost(ctx context.Context, communityID, postID string) (Post, error) {
+	var (
+		p         Post
+		createdAt int64
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, community_id, author_id, title, content, created_at FROM posts WHERE id = ? AND community_id = ?`,
+		postID, communityID).
+		Scan(&p.ID, &p.CommunityID, &p.AuthorID, &p.Title, &p.Content, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return Post{}, err
+		}
+		return Post{}, ErrPostNotFound
+	}
+	if err != nil {
+		return Post{}, err
+	}
+	p.CreatedAt = time.Unix(0, createdAt).UTC()
+	return p, nil
+}
+
+func (s *SQLiteStore) CreatePost(ctx context.Context, communityID string, input PostInput) (Post, error) {
+	if input.Title == "" {
+		return Post{}, fmt.Errorf("title is required")
+	}
+	if input.Content == "" {
+		return Post{}, fmt.Errorf("content is required")
+	}
+
+	post := Post{
+		ID:          newID(),
+		CommunityID: communityID,
+		AuthorID:    input.AuthorID,
+		Title:       input.Title,
+		Content:     input.Content,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO posts (id, community_id, author_id, title, content, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		post.ID, post.CommunityID, post.AuthorID, post.Title, post.Content, post.CreatedAt.UnixNano())
+	if err != nil {
+		if isSQLiteForeignKeyViolation(err) {
+			return Post{}, ErrCommunityNotFound
+		}
+		return Post{}, err
+	}
+	return post, nil
+}
+
+func (s *SQLiteStore) DeletePost(ctx context.Context, communityID, postID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM posts WHERE id = ? AND community_id = ?`,
+		postID, communityID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return err
+		}
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := s.db.QueryRowContext(ctx, `SELECT
+			(SELECT count(*) FROM communities),
+			(SELECT count(*) FROM posts),
+			(SELECT count(DISTINCT author_id) FROM posts WHERE author_id <> '' AND created_at >= ?)`,
+		time.Now().Add(-ActiveMemberWindow).UnixNano()).
+		Scan(&stats.Communities, &stats.Posts, &stats.ActiveMembers)
+	if err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
+
+func (s *SQLiteStore) ListUsers(ctx context.Context) ([]User, error) {
+	return s.queryUsers(ctx, `SELECT id, email, name FROM users ORDER BY name, id`)
+}
+
+func (s *SQLiteStore) CreateUser(ctx context.Context, input UserInput) (User, error) {
+	if err := validateUser(input); err != nil {
+		return User{}, err
+	}
+	user := User{ID: newID(), Email: input.Email, Name: input.Name}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO users (id, email, name) VALUES (?, ?, ?)`,
+		user.ID, user.Email, user.Name)
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *SQLiteStore) AddMember(ctx context.Context, communityID, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO community_memberships (community_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		communityID, userID)
+	if err != nil && isSQLiteForeignKeyViolation(err) {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return err
+		}
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (s *SQLiteStore) ListMembers(ctx context.Context, communityID string) ([]User, error) {
+	users, err := s.queryUsers(ctx, `SELECT u.id, u.email, u.name FROM users u
+		JOIN community_memberships m ON m.user_id = u.id
+		WHERE m.community_id = ? ORDER BY u.name, u.id`, communityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func (s *SQLiteStore) GetMembership(ctx context.Context, communityID, userID string) (Membership, error) {
+	m, err := scanSQLiteMembership(s.db.QueryRowContext(ctx,
+		`SELECT `+sqliteMembershipColumns+` FROM community_memberships WHERE community_id = ? AND user_id = ?`, communityID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return Membership{}, err
+		}
+		return Membership{}, ErrNotMember
+	}
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) ListMemberships(ctx context.Context, userID string) ([]Membership, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sqliteMembershipColumns+` FROM community_memberships WHERE user_id = ? ORDER BY community_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []Membership{}
+	for rows.Next() {
+		m, err := scanSQLiteMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+func (s *SQLiteStore) CreateInvite(ctx context.Context, communityID string, input InviteInput) (Invite, error) {
+	if err := validateInvite(input); err != nil {
+		return Invite{}, err
+	}
+	invite := newInvite(communityID, input)
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO community_invites (code, community_id, expires_at, max_uses, uses, created_at) VALUES (?, ?, ?, ?, 0, ?)`,
+		invite.Code, invite.CommunityID, sqliteNullTime(invite.ExpiresAt), invite.MaxUses, invite.CreatedAt.UnixNano())
+	if err != nil {
+		if isSQLiteForeignKeyViolation(err) {
+			return Invite{}, ErrCommunityNotFound
+		}
+		return Invite{}, err
+	}
+	return invite, nil
+}
+
+func (s *SQLiteStore) RedeemInvite(ctx context.Context, code, userID string) (Membership, error) {
+	var m Membership
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx,
+			`SELECT code, community_id, expires_at, max_uses, uses, created_at FROM community_invites WHERE code = ?`, code)
+		invite, err := scanSQLiteInvite(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInviteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := invite.usable(time.Now()); err != nil {
+			return err
+		}
+
+		existing, err := scanSQLiteMembership(tx.QueryRowContext(ctx,
+			`SELECT `+sqliteMembershipColumns+` FROM community_memberships WHERE community_id = ? AND user_id = ?`, invite.CommunityID, userID))
+		if err == nil {
+			// Already a member: the invite is not used up.
+			m = existing
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		m = Membership{CommunityID: invite.CommunityID, UserID: userID, Role: RoleMember}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO community_memberships (community_id, user_id, role) VALUES (?, ?, ?)`, m.CommunityID, userID, m.Role)
+		if isSQLiteForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE community_invites SET uses = uses + 1 WHERE code = ?`, code)
+		return err
+	})
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) CreateJoinRequest(ctx context.Context, communityID, userID string) (JoinRequest, error) {
+	var req JoinRequest
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM communities WHERE id = ?)`, communityID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrCommunityNotFound
+		}
+
+		row := tx.QueryRowContext(ctx, `SELECT id, community_id, user_id, status, created_at, decided_at FROM join_requests
+			WHERE community_id = ? AND user_id = ? AND status = 'pending'`, communityID, userID)
+		pending, err := scanSQLiteJoinRequest(row)
+		if err == nil {
+			req = pending
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		req = JoinRequest{
+			ID:          newID(),
+			CommunityID: communityID,
+			UserID:      userID,
+			Status:      JoinRequestPending,
+			CreatedAt:   time.Now().UTC(),
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO join_requests (id, community_id, user_id, status, created_at) VALUES (?, ?, ?, ?, ?)`,
+			req.ID, req.CommunityID, req.UserID, req.Status, req.CreatedAt.UnixNano())
+		if isSQLiteForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return JoinRequest{}, err
+	}
+	return req, nil
+}
+
+func (s *SQLiteStore) ListJoinRequests(ctx context.Context, communityID string) ([]JoinRequest, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, community_id, user_id, status, created_at, decided_at FROM join_requests
+		WHERE community_id = ? AND status = 'pending' ORDER BY created_at, id`, communityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []JoinRequest{}
+	for rows.Next() {
+		req, err := scanSQLiteJoinRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return nil, err
+		}
+	}
+	return requests, nil
+}
+
+func (s *SQLiteStore) DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (JoinRequest, error) {
+	if err := validateDecision(status); err != nil {
+		return JoinRequest{}, err
+	}
+
+	var req JoinRequest
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, `SELECT id, community_id, user_id, status, created_at, decided_at FROM join_requests
+			WHERE id = ? AND community_id = ?`, requestID, communityID)
+		var err error
+		req, err = scanSQLiteJoinRequest(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			var exists bool
+			if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM communities WHERE id = ?)`, communityID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return ErrCommunityNotFound
+			}
+			return ErrJoinRequestNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if req.Status != JoinRequestPending {
+			return ErrJoinRequestDecided
+		}
+
+		decided := time.Now().UTC()
+		req.Status, req.DecidedAt = status, &decided
+		if _, err := tx.ExecContext(ctx, `UPDATE join_requests SET status = ?, decided_at = ? WHERE id = ?`,
+			req.Status, decided.UnixNano(), req.ID); err != nil {
+			return err
+		}
+		if status != JoinRequestApproved {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO community_memberships (community_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+			req.CommunityID, req.UserID)
+		return err
+	})
+	if err != nil {
+		return JoinRequest{}, err
+	}
+	return req, nil
+}
+
+func (s *SQLiteStore) CreatePlan(ctx context.Context, communityID string, input PlanInput) (Plan, error) {
+	if err := validatePlan(input); err != nil {
+		return Plan{}, err
+	}
+	plan := newPlan(communityID, input)
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO community_plans (id, community_id, name, price_cents, currency, billing_interval, trial_days, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		plan.ID, plan.CommunityID, plan.Name, plan.PriceCents, plan.Currency, plan.Interval, plan.TrialDays, plan.CreatedAt.UnixNano())
+	if err != nil {
+		if isSQLiteForeignKeyViolation(err) {
+			return Plan{}, ErrCommunityNotFound
+		}
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+func (s *SQLiteStore) ListPlans(ctx context.Context, communityID string) ([]Plan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqlitePlanColumns+` FROM community_plans
+		WHERE community_id = ? ORDER BY price_cents, id`, communityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []Plan{}
+	for rows.Next() {
+		plan, err := scanSQLitePlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(plans) == 0 {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+func (s *SQLiteStore) GetPlan(ctx context.Context, communityID, planID string) (Plan, error) {
+	plan, err := scanSQLitePlan(s.db.QueryRowContext(ctx,
+		`SELECT `+sqlitePlanColumns+` FROM community_plans WHERE id = ? AND community_id = ?`, planID, communityID))
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := s.requireCommunity(ctx, communityID); err != nil {
+			return Plan{}, err
+		}
+		return Plan{}, ErrPlanNotFound
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+func (s *SQLiteStore) SetSubscription(ctx context.Context, communityID, userID string, sub Subscription) (Membership, error) {
+	if err := validateSubscription(sub); err != nil {
+		return Membership{}, err
+	}
+	sub.CurrentPeriodEnd = truncatePeriodEnd(sub.CurrentPeriodEnd)
+
+	var m Membership
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM communities WHERE id = ?)`, communityID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrCommunityNotFound
+		}
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM community_plans WHERE id = ? AND community_id = ?)`,
+			sub.PlanID, communityID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrPlanNotFound
+		}
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO community_memberships
+				(community_id, user_id, subscription_id, plan_id, subscription_status, current_period_end)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (community_id, user_id) DO UPDATE SET subscription_id = excluded.subscription_id,
+				plan_id = excluded.plan_id, subscription_status = excluded.subscription_status,
+				current_period_end = excluded.current_period_end`,
+			communityID, userID, sub.ID, sub.PlanID, sub.Status, sqliteNullTime(sub.CurrentPeriodEnd))
+		if isSQLiteForeignKeyViolation(err) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		m, err = scanSQLiteMembership(tx.QueryRowContext(ctx,
+			`SELECT `+sqliteMembershipColumns+` FROM community_memberships WHERE community_id = ? AND user_id = ?`, communityID, userID))
+		return err
+	})
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) UpdateSubscription(ctx context.Context, subscriptionID string, update SubscriptionUpdate) (Membership, error) {
+	if err := validateSubscriptionStatus(update.Status); err != nil {
+		return Membership{}, err
+	}
+
+	var m Membership
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		m, err = scanSQLiteMembership(tx.QueryRowContext(ctx,
+			`SELECT `+sqliteMembershipColumns+` FROM community_memberships WHERE subscription_id = ?`, subscriptionID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSubscriptionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if update.EventID != "" {
+			res, err := tx.ExecContext(ctx, `INSERT INTO billing_events (id, subscription_id, applied_at) VALUES (?, ?, ?)
+				ON CONFLICT (id) DO NOTHING`, update.EventID, subscriptionID, time.Now().UTC().UnixNano())
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				return ErrDuplicateEvent
+			}
+		}
+		sub, err := applySubscriptionUpdate(*m.Subscription, update)
+		if err != nil {
+			return err
+		}
+		m.Subscription = &sub
+		_, err = tx.ExecContext(ctx, `UPDATE community_memberships SET subscription_status = ?, current_period_end = ?
+			WHERE subscription_id = ?`, sub.Status, sqliteNullTime(sub.CurrentPeriodEnd), subscriptionID)
+		return err
+	})
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) queryUsers(ctx context.Context, query string, args ...any) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Name); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// requireCommunity returns ErrCommunityNotFound when communityID does not exist.
+func (s *SQLiteStore) requireCommunity(ctx context.Context, communityID string) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM communities WHERE id = ?)`, communityID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrCommunityNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, enabled, percentage, communities, updated_at FROM feature_flags ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []FeatureFlag{}
+	for rows.Next() {
+		f, err := scanSQLiteFeatureFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+func (s *SQLiteStore) GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT name, enabled, percentage, communities, updated_at FROM feature_flags WHERE name = ?`, name)
+	f, err := scanSQLiteFeatureFlag(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return FeatureFlag{}, ErrFeatureFlagNotFound
+	}
+	return f, err
+}
+
+func (s *SQLiteStore) UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (FeatureFlag, error) {
+	if err := validateFeatureFlag(name, input); err != nil {
+		return FeatureFlag{}, err
+	}
+	flag := FeatureFlag{
+		Name:        name,
+		Enabled:     input.Enabled,
+		Percentage:  input.Percentage,
+		Communities: append([]string{}, input.Communities...),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	communities, err := json.Marshal(flag.Communities)
+	if err != nil {
+		return FeatureFlag{}, err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO feature_flags (name, enabled, percentage, communities, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET enabled = excluded.enabled, percentage = excluded.percentage,
+			communities = excluded.communities, updated_at = excluded.updated_at`,
+		flag.Name, flag.Enabled, flag.Percentage, string(communities), flag.UpdatedAt.UnixNano())
+	if err != nil {
+		return FeatureFlag{}, err
+	}
+	return flag, nil
+}
+
+func (s *SQLiteStore) DeleteFeatureFlag(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFeatureFlagNotFound
+	}
+	return nil
+}
+
+func scanSQLiteFeatureFlag(row interface{ Scan(...any) error }) (FeatureFlag, error) {
+	var (
+		f           FeatureFlag
+		communities string
+		updatedAt   int64
+	)
+	if err := row.Scan(&f.Name, &f.Enabled, &f.Percentage, &communities, &updatedAt); err != nil {
+		return FeatureFlag{}, err
+	}
+	f.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	if err := json.Unmarshal([]byte(communities), &f.Communities); err != nil {
+		return FeatureFlag{}, fmt.Errorf("decode feature flag communities: %w", err)
+	}
+	return f, nil
+}
+
+const sqliteMembershipColumns = `community_id, user_id, role, subscription_id, plan_id, subscription_status, current_period_end`
+
+func scanSQLiteMembership(row interface{ Scan(...any) error }) (Membership, error) {
+	var (
+		m                     Membership
+		subID, planID, status sql.NullString
+		periodEnd             sql.NullInt64
+	)
+	if err := row.Scan(&m.CommunityID, &m.UserID, &m.Role, &subID, &planID, &status, &periodEnd); err != nil {
+		return Membership{}, err
+	}
+	if subID.Valid {
+		m.Subscription = &Subscription{
+			ID:               subID.String,
+			PlanID:           planID.String,
+			Status:           SubscriptionStatus(status.String),
+			CurrentPeriodEnd: sqliteTime(periodEnd),
+		}
+	}
+	return m, nil
+}
+
+const sqlitePlanColumns = `id, community_id, name, price_cents, currency, billing_interval, trial_days, created_at`
+
+func scanSQLitePlan(row interface{ Scan(...any) error }) (Plan, error) {
+	var (
+		plan      Plan
+		createdAt int64
+	)
+	if err := row.Scan(&plan.ID, &plan.CommunityID, &plan.Name, &plan.PriceCents, &plan.Currency, &plan.Interval, &plan.TrialDays, &createdAt); err != nil {
+		return Plan{}, err
+	}
+	plan.CreatedAt = time.Unix(0, createdAt).UTC()
+	return plan, nil
+}
+
+func scanSQLiteInvite(row interface{ Scan(...any) error }) (Invite, error) {
+	var (
+		invite    Invite
+		expiresAt sql.NullInt64
+		createdAt int64
+	)
+	if err := row.Scan(&invite.Code, &invite.CommunityID, &expiresAt, &invite.MaxUses, &invite.Uses, &createdAt); err != nil {
+		return Invite{}, err
+	}
+	invite.ExpiresAt = sqliteTime(expiresAt)
+	invite.CreatedAt = time.Unix(0, createdAt).UTC()
+	return invite, nil
+}
+
+func scanSQLiteJoinRequest(row interface{ Scan(...any) error }) (JoinRequest, error) {
+	var (
+		req       JoinRequest
+		createdAt int64
+		decidedAt sql.NullInt64
+	)
+	if err := row.Scan(&req.ID, &req.CommunityID, &req.UserID, &req.Status, &createdAt, &decidedAt); err != nil {
+		return JoinRequest{}, err
+	}
+	req.CreatedAt = time.Unix(0, createdAt).UTC()
+	req.DecidedAt = sqliteTime(decidedAt)
+	return req, nil
+}
+
+// sqliteNullTime stores an optional timestamp as Unix nanoseconds or NULL.
+func sqliteNullTime(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// sqliteTime reads an optional timestamp stored by sqliteNullTime.
+func sqliteTime(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64).UTC()
+	return &t
+}
+
+func isSQLiteForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func openSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(context.Background(), SQLiteScheme+path, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("open sqlite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStorePersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "skool.db")
+
+	first := openSQLiteStore(t, path)
+	community, err := first.CreateCommunity(ctx, CommunityInput{Name: "Go"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	post, err := first.CreatePost(ctx, community.ID, PostInput{AuthorID: "u1", Title: "Hi", Content: "x"})
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	if _, err := first.UpsertFeatureFlag(ctx, "beta", FeatureFlagInput{Enabled: true, Percentage: 10, Communities: []string{community.ID}}); err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	second := openSQLiteStore(t, path)
+	posts, err := second.ListPostsByCommunity(ctx, community.ID)
+	if err != nil || len(posts) != 1 || posts[0].ID != post.ID || !posts[0].CreatedAt.Equal(post.CreatedAt) {
+		t.Fatalf("expected post %+v to survive reopen, got %+v, %v", post, posts, err)
+	}
+	flag, err := second.GetFeatureFlag(ctx, "beta")
+	if err != nil || !flag.Enabled || len(flag.Communities) != 1 {
+		t.Fatalf("unexpected flag %+v, %v", flag, err)
+	}
+
+	if err := second.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if stats, err := second.Stats(ctx); err != nil || stats.Posts != 0 {
+		t.Fatalf("expected posts to cascade, got %+v, %v", stats, err)
+	}
+}
+
+func TestSQLiteStoreMigratesSlugs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "skool.db")
+
+	// A database created before communities had slugs.
+	legacy, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("open legacy db: %v", err)
+	}
+	ids := []string{newID(), newID()}
+	for _, stmt := range []string{
+		`CREATE TABLE communities (id TEXT PRIMARY KEY, name TEXT NOT NULL, description TEXT DEFAULT '')`,
+		`INSERT INTO communities (id, name) VALUES ('` + ids[0] + `', 'Go Fans'), ('` + ids[1] + `', 'Go fans')`,
+	} {
+		if _, err := legacy.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("legacy schema: %v", err)
+		}
+	}
+	legacy.Close()
+
+	store := openSQLiteStore(t, path)
+	first, err := store.GetCommunityBySlug(ctx, "go-fans")
+	if err != nil || first.ID != ids[0] || first.Visibility != VisibilityPublic {
+		t.Fatalf("get by slug = %+v, %v; want community %s", first, err, ids[0])
+	}
+	second, err := store.GetCommunityBySlug(ctx, "go-fans-2")
+	if err != nil || second.ID != ids[1] {
+		t.Fatalf("get by slug = %+v, %v; want community %s", second, err, ids[1])
+	}
+	if c, err := store.CreateCommunity(ctx, CommunityInput{Name: "Go Fans"}); err != nil || c.Slug != "go-fans-3" {
+		t.Fatalf("create after migration = %+v, %v", c, err)
+	}
+}
+
+func TestOpenRejectsEmptySQLitePath(t *testing.T) {
+	if _, err := Open(context.Background(), SQLiteScheme, DefaultPoolConfig(), nil); err == nil {
+		t.Fatal("expected error for sqlite URL without a path")
+	}
+}
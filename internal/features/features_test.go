@@ -0,0 +1,81 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestEvaluate(t *testing.T) {
+	flag := db.FeatureFlag{Name: "comments", Enabled: true, Communities: []string{"c1"}}
+
+	if !Evaluate(flag, Subject{CommunityID: "c1"}) {
+		t.Fatalf("expected allow-listed community to be enabled")
+	}
+	if Evaluate(flag, Subject{CommunityID: "c2", UserID: "u1"}) {
+		t.Fatalf("expected 0%% rollout to be disabled outside the allow list")
+	}
+
+	flag.Enabled = false
+	if Evaluate(flag, Subject{CommunityID: "c1"}) {
+		t.Fatalf("expected disabled flag to be off for allow-listed community")
+	}
+
+	flag = db.FeatureFlag{Name: "comments", Enabled: true, Percentage: 100}
+	if !Evaluate(flag, Subject{}) {
+		t.Fatalf("expected 100%% rollout to be enabled for anonymous callers")
+	}
+}
+
+func TestEvaluatePercentageIsStableAndProportional(t *testing.T) {
+	flag := db.FeatureFlag{Name: "reactions", Enabled: true, Percentage: 30}
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		subject := Subject{UserID: fmt.Sprintf("user-%d", i)}
+		got := Evaluate(flag, subject)
+		if got != Evaluate(flag, subject) {
+			t.Fatalf("evaluation is not stable for %s", subject.UserID)
+		}
+		if got {
+			enabled++
+		}
+	}
+	if enabled < 250 || enabled > 350 {
+		t.Fatalf("expected roughly 30%% of users enabled, got %d/1000", enabled)
+	}
+
+	// Growing the rollout never turns a user off.
+	wider := flag
+	wider.Percentage = 60
+	for i := 0; i < 1000; i++ {
+		subject := Subject{UserID: fmt.Sprintf("user-%d", i)}
+		if Evaluate(flag, subject) && !Evaluate(wider, subject) {
+			t.Fatalf("user %s lost the feature when the rollout grew", subject.UserID)
+		}
+	}
+}
+
+func TestServiceOverrides(t *testing.T) {
+	store := db.NewInMemoryStore()
+	ctx := context.Background()
+	if _, err := store.UpsertFeatureFlag(ctx, "comments", db.FeatureFlagInput{Enabled: true, Percentage: 100}); err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+
+	svc := NewService(store, map[string]bool{"comments": false})
+	if on, err := svc.Enabled(ctx, "comments", Subject{}); err != nil || on {
+		t.Fatalf("expected config override to disable flag, got %v, %v", on, err)
+	}
+
+	svc.SetOverrides(nil)
+	if on, err := svc.Enabled(ctx, "comments", Subject{}); err != nil || !on {
+		t.Fatalf("expected stored flag to be enabled, got %v, %v", on, err)
+	}
+
+	if on, err := svc.Enabled(ctx, "unknown", Subject{}); err != nil || on {
+		t.Fatalf("expected unknown flag to be disabled, got %v, %v", on, err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package fixtures loads seed data into a db.Store. A fixture file (YAML or
+// JSON) lists users, communities, memberships and posts; records refer to each
+// other by fixture keys because the store assigns the real IDs.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// Demo is demo.yaml, the demo data for local development, embedded for tools
+// that seed data without a checkout at hand.
+//
+//go:embed demo.yaml
+var Demo []byte
+
+// Fixture is the parsed contents of a fixture file.
+type Fixture struct {
+	Users       []User       `yaml:"users" json:"users"`
+	Communities []Community  `yaml:"communities" json:"communities"`
+	Memberships []Membership `yaml:"memberships" json:"memberships"`
+	Posts       []Post       `yaml:"posts" json:"posts"`
+}
+
+// User is a user to create; Key is how memberships and posts refer to it.
+type User struct {
+	Key   string `yaml:"key" json:"key"`
+	Name  string `yaml:"name" json:"name"`
+	Email string `yaml:"email" json:"email"`
+}
+
+// Community is a community to create; Key is how memberships and posts refer to it.
+type Community struct {
+	Key         string `yaml:"key" json:"key"`
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+}
+
+// Membership adds the user with key User to the community with key Community.
+type Membership struct {
+	Community string `yaml:"community" json:"community"`
+	User      string `yaml:"user" json:"user"`
+}
+
+// Post is a post by the user with key Author in the community with key Community.
+type Post struct {
+	Community string `yaml:"community" json:"community"`
+	Author    string `yaml:"author" json:"author"`
+	Title     string `yaml:"title" json:"title"`
+	Content   string `yaml:"content" json:"content"`
+}
+
+// Result reports what Load created, mapping fixture keys to store IDs.
+type Result struct {
+	UserIDs      map[string]string
+	CommunityIDs map[string]string
+	Memberships  int
+	Posts        int
+}
+
+// Parse decodes a YAML or JSON fixture and checks its references. Unknown
+// fields are rejected so typos do not silently drop data.
+func Parse(data []byte) (*Fixture, error) {
+	var fx Fixture
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fx); err != nil {
+		return nil, fmt.Errorf("parse fixture: %w", err)
+	}
+	if err := fx.Validate(); err != nil {
+		return nil, err
+	}
+	return &fx, nil
+}
+
+// ReadFile parses the fixture at path.
+func ReadFile(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	fx, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fx, nil
+}
+
+// Validate reports every duplicate key and dangling reference in fx.
+func (fx *Fixture) Validate() error {
+	var errs []error
+	users := make(map[string]bool, len(fx.Users))
+	for i, u := range fx.Users {
+		switch {
+		case u.Key == "":
+			errs = append(errs, fmt.Errorf("users[%d]: key is required", i))
+		case users[u.Key]:
+			errs = append(errs, fmt.Errorf("users[%d]: duplicate key %q", i, u.Key))
+		}
+		users[u.Key] = true
+	}
+	communities := make(map[string]bool, len(fx.Communities))
+	for i, c := range fx.Communities {
+		switch {
+		case c.Key == "":
+			errs = append(errs, fmt.Errorf("communities[%d]: key is required", i))
+		case communities[c.Key]:
+			errs = append(errs, fmt.Errorf("communities[%d]: duplicate key %q", i, c.Key))
+		}
+		communities[c.Key] = true
+	}
+	for i, m := range fx.Memberships {
+		if !communities[m.Community] {
+			errs = append(errs, fmt.Errorf("memberships[%d]: unknown community %q", i, m.Community))
+		}
+		if !users[m.User] {
+			errs = append(errs, fmt.Errorf("memberships[%d]: unknown user %q", i, m.User))
+		}
+	}
+	for i, p := range fx.Posts {
+		if !communities[p.Community] {
+			errs = append(errs, fmt.Errorf("posts[%d]: unknown community %q", i, p.Community))
+		}
+		if p.Author != "" && !users[p.Author] {
+			errs = append(errs, fmt.Errorf("posts[%d]: unknown author %q", i, p.Author))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load creates everything in fx in store: users, then communities,
+// memberships and posts. It stops at the first error; records created before
+// it are left in place.
+func Load(ctx context.Context, store db.Store, fx *Fixture) (Result, error) {
+	res := Result{
+		UserIDs:      make(map[string]string, len(fx.Users)),
+		CommunityIDs: make(map[string]string, len(fx.Communities)),
+	}
+	if err := fx.Validate(); err != nil {
+		return res, err
+	}
+
+	for _, u := range fx.Users {
+		created, err := store.CreateUser(ctx, db.UserInput{Name: u.Name, Email: u.Email})
+		if err != nil {
+			return res, fmt.Errorf("create user %q: %w", u.Key, err)
+		}
+		res.UserIDs[u.Key] = created.ID
+	}
+	for _, c := range fx.Communities {
+		created, err := store.CreateCommunity(ctx, db.CommunityInput{Name: c.Name, Description: c.Description})
+		if err != nil {
+			return res, fmt.Errorf("create community %q: %w", c.Key, err)
+		}
+		res.CommunityIDs[c.Key] = created.ID
+	}
+	for _, m := range fx.Memberships {
+		if err := store.AddMember(ctx, res.CommunityIDs[m.Community], res.UserIDs[m.User]); err != nil {
+			return res, fmt.Errorf("add %q to %q: %w", m.User, m.Community, err)
+		}
+		res.Memberships++
+	}
+	for i, p := range fx.Posts {
+		input := db.PostInput{AuthorID: res.UserIDs[p.Author], Title: p.Title, Content: p.Content}
+		if _, err := store.CreatePost(ctx, res.CommunityIDs[p.Community], input); err != nil {
+			return res, fmt.Errorf("create posts[%d]: %w", i, err)
+		}
+		res.Posts++
+	}
+	return res, nil
+}
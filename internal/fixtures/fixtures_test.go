@@ -0,0 +1,80 @@
+package fixtures
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestLoadDemoFixture(t *testing.T) {
+	fx, err := Parse(Demo)
+	if err != nil {
+		t.Fatalf("read demo fixture: %v", err)
+	}
+	store := db.NewInMemoryStore()
+	ctx := context.Background()
+
+	res, err := Load(ctx, store, fx)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(res.UserIDs) != 3 || len(res.CommunityIDs) != 2 || res.Memberships != 4 || res.Posts != 3 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	members, err := store.ListMembers(ctx, res.CommunityIDs["sre"])
+	if err != nil || len(members) != 2 || members[0].ID != res.UserIDs["ben"] {
+		t.Fatalf("unexpected sre members %+v, %v", members, err)
+	}
+	posts, err := store.ListPostsByCommunity(ctx, res.CommunityIDs["go"])
+	// Posts list newest first, so the first fixture post comes last.
+	if err != nil || len(posts) != 2 || posts[1].AuthorID != res.UserIDs["ana"] {
+		t.Fatalf("unexpected go posts %+v, %v", posts, err)
+	}
+}
+
+func TestParseJSONAndReferenceErrors(t *testing.T) {
+	_, err := Parse([]byte(`{
+		"users": [{"key": "a", "name": "A", "email": "a@example.com"}, {"key": "a", "name": "B", "email": "b@example.com"}],
+		"communities": [{"key": "c", "name": "C"}],
+		"memberships": [{"community": "nope", "user": "a"}],
+		"posts": [{"community": "c", "author": "ghost", "title": "t", "content": "x"}]
+	}`))
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{`duplicate key "a"`, `unknown community "nope"`, `unknown author "ghost"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	if _, err := Parse([]byte("users:\n  - key: a\n    nmae: typo\n")); err == nil {
+		t.Fatal("expected unknown field to be rejected")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	opts := GenerateOptions{Seed: 7, Users: 50, Communities: 5, Posts: 1000}
+	fx := Generate(opts)
+	if err := fx.Validate(); err != nil {
+		t.Fatalf("generated fixture is invalid: %v", err)
+	}
+	if len(fx.Users) != 50 || len(fx.Communities) != 5 || len(fx.Posts) != 1000 {
+		t.Fatalf("unexpected sizes: %d users, %d communities, %d posts", len(fx.Users), len(fx.Communities), len(fx.Posts))
+	}
+	if again := Generate(opts); again.Posts[999] != fx.Posts[999] {
+		t.Fatal("same seed should generate the same data")
+	}
+
+	store := db.NewInMemoryStore()
+	if _, err := Load(context.Background(), store, fx); err != nil {
+		t.Fatalf("load generated fixture: %v", err)
+	}
+	stats, err := store.Stats(context.Background())
+	if err != nil || stats.Posts != 1000 {
+		t.Fatalf("stats = %+v, %v", stats, err)
+	}
+}
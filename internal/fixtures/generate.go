@@ -0,0 +1,127 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// GenerateOptions sizes a synthetic fixture.
+type GenerateOptions struct {
+	// Seed makes the output reproducible; the same options always produce
+	// the same fixture.
+	Seed        uint64
+	Users       int
+	Communities int
+	Posts       int
+}
+
+// DefaultGenerateOptions returns a data set big enough for load testing.
+func DefaultGenerateOptions() GenerateOptions {
+	return GenerateOptions{Seed: 1, Users: 200, Communities: 20, Posts: 5000}
+}
+
+var (
+	firstNames = []string{"Ava", "Ben", "Chloe", "Diego", "Elena", "Farah", "Gus", "Hana", "Ivan", "Jade", "Kofi", "Lena", "Mateo", "Nia", "Omar", "Priya", "Quinn", "Rosa", "Sam", "Theo", "Uma", "Vik", "Wen", "Yara", "Zane"}
+	lastNames  = []string{"Adams", "Brooks", "Chen", "Diaz", "Evans", "Fischer", "Garcia", "Haddad", "Ito", "Jensen", "Kim", "Lopez", "Murphy", "Novak", "Okafor", "Patel", "Rossi", "Silva", "Tanaka", "Walsh"}
+	topics     = []string{"Go", "Kubernetes", "Photography", "Sourdough", "Running", "Guitar", "Startups", "Design", "Chess", "Gardening", "Rust", "Woodworking", "Investing", "Climbing", "Spanish", "Writing", "Data Science", "Cycling", "Home Lab", "Coffee"}
+	groupWords = []string{"Fans", "Guild", "Club", "Lab", "Collective", "Circle", "Academy", "Crew"}
+	titles     = []string{
+		"How I got started with %s",
+		"Weekly %s check-in",
+		"Beginner question about %s",
+		"My %s setup in 2025",
+		"Resources that helped me learn %s",
+		"What is your biggest %s mistake?",
+		"%s wins this month",
+		"Unpopular opinion about %s",
+		"Looking for a %s accountability partner",
+		"Show and tell: my latest %s project",
+	}
+	sentences = []string{
+		"I have been at this for a few months now and wanted to share what worked.",
+		"Curious how others approach this.",
+		"The first week was rough, but it clicked after a while.",
+		"Happy to answer questions in the comments.",
+		"Any feedback is welcome, especially from people further along.",
+		"I wrote down my notes so others can skip the trial and error.",
+		"This community has been a huge help, so thank you all.",
+		"Next step is to make this a daily habit.",
+		"Does anyone have a good checklist for this?",
+		"I would love to hear how long it took you to see results.",
+	}
+)
+
+// Generate builds a synthetic fixture of users, communities, memberships and
+// posts. Every user joins one to three communities and posts are written by
+// members of the community they are posted in.
+func Generate(opts GenerateOptions) *Fixture {
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15))
+	fx := &Fixture{}
+
+	for i := range opts.Users {
+		first := firstNames[rng.IntN(len(firstNames))]
+		last := lastNames[rng.IntN(len(lastNames))]
+		fx.Users = append(fx.Users, User{
+			Key:   fmt.Sprintf("user-%d", i+1),
+			Name:  first + " " + last,
+			Email: fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1),
+		})
+	}
+
+	topicOf := make([]string, opts.Communities)
+	for i := range opts.Communities {
+		topic := topics[i%len(topics)]
+		topicOf[i] = topic
+		name := topic + " " + groupWords[rng.IntN(len(groupWords))]
+		if i >= len(topics) {
+			name = fmt.Sprintf("%s %d", name, i/len(topics)+1)
+		}
+		fx.Communities = append(fx.Communities, Community{
+			Key:         fmt.Sprintf("community-%d", i+1),
+			Name:        name,
+			Description: fmt.Sprintf("A place to learn and talk about %s.", strings.ToLower(topic)),
+		})
+	}
+	if opts.Communities == 0 {
+		return fx
+	}
+
+	members := make([][]string, opts.Communities)
+	for _, u := range fx.Users {
+		joined := make(map[int]bool)
+		for range 1 + rng.IntN(min(3, opts.Communities)) {
+			c := rng.IntN(opts.Communities)
+			if joined[c] {
+				continue
+			}
+			joined[c] = true
+			members[c] = append(members[c], u.Key)
+			fx.Memberships = append(fx.Memberships, Membership{Community: fx.Communities[c].Key, User: u.Key})
+		}
+	}
+
+	for range opts.Posts {
+		c := rng.IntN(opts.Communities)
+		var author string
+		if len(members[c]) > 0 {
+			author = members[c][rng.IntN(len(members[c]))]
+		}
+		fx.Posts = append(fx.Posts, Post{
+			Community: fx.Communities[c].Key,
+			Author:    author,
+			Title:     fmt.Sprintf(titles[rng.IntN(len(titles))], topicOf[c]),
+			Content:   paragraph(rng),
+		})
+	}
+	return fx
+}
+
+func paragraph(rng *rand.Rand) string {
+	n := 2 + rng.IntN(4)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = sentences[rng.IntN(len(sentences))]
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,280 @@
+package apihttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// access is what a route under /communities/{id} requires of the caller.
+type access int
+
+const (
+	// accessView lets anyone see public and private communities, and only
+	// members see hidden ones.
+	accessView access = iota
+	// accessPosts additionally requires membership outside public
+	// communities, and a paid subscription in communities with plans.
+	accessPosts
+	// accessManage requires the admin role. Communities without any admin,
+	// created before roles existed or anonymously, stay open to everyone.
+	accessManage
+	// accessAdminOnly requires the admin role even where accessManage is open
+	// to everyone: only an admin may restrict a community or put it behind a
+	// paywall, or no one could ever admit anybody or take it down.
+	accessAdminOnly
+)
+
+// grant is what authorize found: the {id} community and the caller's
+// membership in it, with an empty role for non-members.
+type grant struct {
+	community  db.CommunityDetail
+	membership db.Membership
+}
+
+// allows reports whether the caller has the role need requires.
+func (g grant) allows(need access) bool {
+	switch need {
+	case accessManage:
+		return g.community.AdminCount == 0 || g.membership.Role == db.RoleAdmin
+	case accessAdminOnly:
+		return g.membership.Role == db.RoleAdmin
+	default:
+		return true
+	}
+}
+
+type grantKey struct{}
+
+// grantFromContext returns the grant authorize stored in ctx.
+func grantFromContext(ctx context.Context) grant {
+	g, _ := ctx.Value(grantKey{}).(grant)
+	return g
+}
+
+// membership returns userID's membership in communityID, with an empty role
+// when the caller is anonymous or not a member.
+func (h *Handler) membership(ctx context.Context, communityID, userID string) (db.Membership, error) {
+	if userID == "" {
+		return db.Membership{CommunityID: communityID}, nil
+	}
+	m, err := h.store.GetMembership(ctx, communityID, userID)
+	if errors.Is(err, db.ErrNotMember) {
+		return db.Membership{CommunityID: communityID, UserID: userID}, nil
+	}
+	return m, err
+}
+
+// authorize checks the caller, identified by X-User-ID, against need for the
+// {id} community. Hidden communities are reported as not found to
+// non-members so their existence does not leak. Admins read and write posts
+// without a subscription.
+func (h *Handler) authorize(need access) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", userIDHeader)
+			ctx := r.Context()
+			community, err := h.store.GetCommunity(ctx, chi.URLParam(r, "id"))
+			if err != nil {
+				if errors.Is(err, db.ErrCommunityNotFound) {
+					http.Error(w, "community not found", http.StatusNotFound)
+					return
+				}
+				h.logger.Error("authorize community failed", zap.Error(err))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			m, err := h.membership(ctx, community.ID, userIDFromRequest(r))
+			if err != nil && !errors.Is(err, db.ErrCommunityNotFound) {
+				h.logger.Error("authorize membership failed", zap.Error(err))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			g := grant{community: community, membership: m}
+			switch {
+			case community.Visibility == db.VisibilityHidden && m.Role == "":
+				http.Error(w, "community not found", http.StatusNotFound)
+				return
+			case need == accessPosts && community.Visibility != db.VisibilityPublic && m.Role == "":
+				http.Error(w, "membership required", http.StatusForbidden)
+				return
+			case need == accessPosts && community.PlanCount > 0 && m.Role != db.RoleAdmin && !m.Subscription.Paid():
+				http.Error(w, "active subscription required", http.StatusPaymentRequired)
+				return
+			case !g.allows(need):
+				http.Error(w, "admin role required", http.StatusForbidden)
+				return
+			}
+			ctx = context.WithValue(ctx, grantKey{}, g)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// varyByCaller marks responses as depending on X-User-ID, before cacheable
+// picks their Cache-Control. authorize does the same for {id} routes.
+func varyByCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", userIDHeader)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// visibleCommunities drops the hidden communities the caller is not a member
+// of.
+func (h *Handler) visibleCommunities(ctx context.Context, userID string, communities []db.Community) ([]db.Community, error) {
+	member := make(map[string]bool)
+	if userID != "" {
+		memberships, err := h.store.ListMemberships(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range memberships {
+			member[m.CommunityID] = true
+		}
+	}
+	visible := make([]db.Community, 0, len(communities))
+	for _, c := range communities {
+		if c.Visibility != db.VisibilityHidden || member[c.ID] {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+// JoinCommunity adds the caller to a public community, or files a join
+// request for an admin to decide on in a private one. Hidden communities are
+// joined through invites.
+func (h *Handler) JoinCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromRequest(r)
+	if userID == "" {
+		http.Error(w, "X-User-ID required", http.StatusUnauthorized)
+		return
+	}
+	g := grantFromContext(r.Context())
+	membership := g.membership
+	if membership.Role != "" {
+		writeResponse(w, r, http.StatusOK, membership)
+		return
+	}
+
+	var err error
+	if g.community.Visibility == db.VisibilityPublic {
+		if err = h.store.AddMember(r.Context(), g.community.ID, userID); err == nil {
+			membership.Role = db.RoleMember
+			writeResponse(w, r, http.StatusOK, membership)
+			return
+		}
+	} else {
+		var req db.JoinRequest
+		if req, err = h.store.CreateJoinRequest(r.Context(), g.community.ID, userID); err == nil {
+			writeResponse(w, r, http.StatusAccepted, req)
+			return
+		}
+	}
+	switch {
+	case errors.Is(err, db.ErrCommunityNotFound):
+		http.Error(w, "community not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrUserNotFound):
+		http.Error(w, "user not found", http.StatusBadRequest)
+	default:
+		h.logger.Error("join community failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) ListJoinRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.store.ListJoinRequests(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, db.ErrCommunityNotFound) {
+			http.Error(w, "community not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("list join requests failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	requests, ok := paginate(w, r, requests)
+	if !ok {
+		return
+	}
+	writeList(w, r, requests)
+}
+
+func (h *Handler) DecideJoinRequest(w http.ResponseWriter, r *http.Request) {
+	var decision db.JoinRequestDecision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req, err := h.store.DecideJoinRequest(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "requestId"), decision.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrCommunityNotFound):
+			http.Error(w, "community not found", http.StatusNotFound)
+		case errors.Is(err, db.ErrJoinRequestNotFound):
+			http.Error(w, "join request not found", http.StatusNotFound)
+		case errors.Is(err, db.ErrJoinRequestDecided):
+			http.Error(w, "join request already decided", http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf("unable to decide join request: %v", err), http.StatusBadRequest)
+		}
+		return
+	}
+	writeResponse(w, r, http.StatusOK, req)
+}
+
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	var input db.InviteInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	invite, err := h.store.CreateInvite(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		if errors.Is(err, db.ErrCommunityNotFound) {
+			http.Error(w, "community not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("unable to create invite: %v", err), http.StatusBadRequest)
+		return
+	}
+	writeResponse(w, r, http.StatusCreated, invite)
+}
+
+// RedeemInvite makes the caller a member of the invite's community. Expired
+// and used up invites answer 410 Gone.
+func (h *Handler) RedeemInvite(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromRequest(r)
+	if userID == "" {
+		http.Error(w, "X-User-ID required", http.StatusUnauthorized)
+		return
+	}
+
+	membership, err := h.store.RedeemInvite(r.Context(), chi.URLParam(r, "code"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrInviteNotFound):
+			http.Error(w, "invite not found", http.StatusNotFound)
+		case errors.Is(err, db.ErrInviteExpired):
+			http.Error(w, "invite expired or used up", http.StatusGone)
+		case errors.Is(err, db.ErrUserNotFound):
+			http.Error(w, "user not found", http.StatusBadRequest)
+		default:
+			h.logger.Error("redeem invite failed", zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeResponse(w, r, http.StatusOK, membership)
+}
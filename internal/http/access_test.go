@@ -0,0 +1,202 @@
+package apihttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestCommunityAccess(t *testing.T) {
+	store := db.NewInMemoryStore()
+	ts := NewRouter(store, zaptest.NewLogger(t),
+		WithValidation(loadSpec(t), ValidationOptions{Requests: true}))
+
+	users := make(map[string]string)
+	for _, name := range []string{"Ada", "Bob", "Cy"} {
+		u, err := store.CreateUser(context.Background(), db.UserInput{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[name] = u.ID
+	}
+
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest(method, path, nil)
+		} else {
+			req = httptest.NewRequest(method, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if user != "" {
+			req.Header.Set(userIDHeader, users[user])
+		}
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("", http.MethodPost, "/communities", `{"name":"Club","visibility":"private"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("anonymous private community: expected 400, got %d", rr.Code)
+	}
+	rr := do("Ada", http.MethodPost, "/communities", `{"name":"Club","visibility":"private"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create private community: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	club := decodeResponse[db.Community](t, rr.Body.Bytes())
+	if club.Visibility != db.VisibilityPrivate {
+		t.Fatalf("expected a private community, got %+v", club)
+	}
+
+	// Private communities are visible, but their posts are for members.
+	if rr := do("Bob", http.MethodGet, "/communities/club", ""); rr.Code != http.StatusOK {
+		t.Fatalf("view private community: expected 200, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("posts as non-member: expected 403, got %d", rr.Code)
+	}
+	rr = do("Ada", http.MethodPost, "/communities/club/posts", `{"title":"Hi","content":"members only"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("post as admin: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	adaPost := decodeResponse[db.Post](t, rr.Body.Bytes())
+
+	if rr := do("", http.MethodPost, "/communities/club/join", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous join: expected 401, got %d", rr.Code)
+	}
+	rr = do("Bob", http.MethodPost, "/communities/club/join", "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("join private community: expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	request := decodeResponse[db.JoinRequest](t, rr.Body.Bytes())
+	if rr := do("Bob", http.MethodPost, "/communities/club/join", ""); decodeResponse[db.JoinRequest](t, rr.Body.Bytes()).ID != request.ID {
+		t.Fatalf("expected asking again to return the pending request, got %s", rr.Body.String())
+	}
+
+	if rr := do("Bob", http.MethodGet, "/communities/club/join-requests", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("join requests as non-admin: expected 403, got %d", rr.Code)
+	}
+	rr = do("Ada", http.MethodGet, "/communities/club/join-requests", "")
+	if pending := decodeResponse[[]db.JoinRequest](t, rr.Body.Bytes()); rr.Code != http.StatusOK || len(pending) != 1 || pending[0].ID != request.ID {
+		t.Fatalf("list join requests: got %d: %s", rr.Code, rr.Body.String())
+	}
+	decide := "/communities/club/join-requests/" + request.ID
+	if rr := do("Ada", http.MethodPut, decide, `{"status":"approved"}`); rr.Code != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("Ada", http.MethodPut, decide, `{"status":"rejected"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("decide twice: expected 409, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusOK {
+		t.Fatalf("posts as approved member: expected 200, got %d", rr.Code)
+	}
+
+	// Members post as themselves only.
+	if rr := do("Bob", http.MethodPost, "/communities/club/posts", `{"authorId":"`+users["Ada"]+`","title":"Hi","content":"forged"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("post as another user: expected 403, got %d", rr.Code)
+	}
+	rr = do("Bob", http.MethodPost, "/communities/club/posts", `{"title":"Hi","content":"from Bob"}`)
+	bobPost := decodeResponse[db.Post](t, rr.Body.Bytes())
+	if rr.Code != http.StatusCreated || bobPost.AuthorID != users["Bob"] {
+		t.Fatalf("post as member: expected 201 authored by Bob, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Posts are deleted by their author or an admin.
+	if rr := do("Bob", http.MethodDelete, "/communities/club/posts/"+adaPost.ID, ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("delete another member's post: expected 403, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodDelete, "/communities/club/posts/"+bobPost.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete own post: expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("Ada", http.MethodDelete, "/communities/club/posts/"+adaPost.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete as admin: expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do("Bob", http.MethodPost, "/communities/club/join", "")
+	if m := decodeResponse[db.Membership](t, rr.Body.Bytes()); rr.Code != http.StatusOK || m.Role != db.RoleMember {
+		t.Fatalf("join as member: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("Bob", http.MethodPut, "/communities/club", `{"name":"Mine"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("update as member: expected 403, got %d", rr.Code)
+	}
+
+	// Hidden communities exist only for their members.
+	rr = do("Ada", http.MethodPost, "/communities", `{"name":"Secret","visibility":"hidden"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create hidden community: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if list := decodeResponse[[]db.Community](t, do("Bob", http.MethodGet, "/communities", "").Body.Bytes()); len(list) != 1 {
+		t.Fatalf("expected the hidden community to be unlisted for non-members, got %+v", list)
+	}
+	rr = do("Ada", http.MethodGet, "/communities", "")
+	if list := decodeResponse[[]db.Community](t, rr.Body.Bytes()); len(list) != 2 {
+		t.Fatalf("expected the hidden community to be listed for its admin, got %+v", list)
+	}
+	if !contains(rr.Header().Values("Vary"), userIDHeader) {
+		t.Fatalf("expected Vary: %s, got %v", userIDHeader, rr.Header().Values("Vary"))
+	}
+	for _, path := range []string{"/communities/secret", "/communities/secret/posts"} {
+		if rr := do("Bob", http.MethodGet, path, ""); rr.Code != http.StatusNotFound {
+			t.Fatalf("GET %s as non-member: expected 404, got %d", path, rr.Code)
+		}
+	}
+	if rr := do("Bob", http.MethodPost, "/communities/secret/join", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("join hidden community: expected 404, got %d", rr.Code)
+	}
+
+	if rr := do("Bob", http.MethodPost, "/communities/club/invites", `{}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("invite as member: expected 403, got %d", rr.Code)
+	}
+	rr = do("Ada", http.MethodPost, "/communities/secret/invites", `{"maxUses":1}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create invite: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	invite := decodeResponse[db.Invite](t, rr.Body.Bytes())
+	if rr := do("", http.MethodPost, "/invites/"+invite.Code, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous redeem: expected 401, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodPost, "/invites/no-such-code", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown invite: expected 404, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodPost, "/invites/"+invite.Code, ""); rr.Code != http.StatusOK {
+		t.Fatalf("redeem: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("Cy", http.MethodPost, "/invites/"+invite.Code, ""); rr.Code != http.StatusGone {
+		t.Fatalf("used up invite: expected 410, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/secret/posts", ""); rr.Code != http.StatusOK {
+		t.Fatalf("posts as invited member: expected 200, got %d", rr.Code)
+	}
+
+	// A former slug only redirects those who may see the community.
+	if rr := do("Ada", http.MethodPut, "/communities/secret", `{"name":"Secret","slug":"hush"}`); rr.Code != http.StatusOK {
+		t.Fatalf("rename: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("Cy", http.MethodGet, "/communities/secret", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("former slug as non-member: expected 404, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/secret", ""); rr.Code != http.StatusMovedPermanently {
+		t.Fatalf("former slug as member: expected 301, got %d", rr.Code)
+	}
+
+	// Communities without an admin stay open, but nobody may restrict them.
+	rr = do("", http.MethodPost, "/communities", `{"name":"Legacy"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create anonymous community: expected 201, got %d", rr.Code)
+	}
+	if rr := do("Cy", http.MethodPut, "/communities/legacy", `{"name":"Legacy","description":"edited"}`); rr.Code != http.StatusOK {
+		t.Fatalf("update adminless community: expected 200, got %d", rr.Code)
+	}
+	if rr := do("Cy", http.MethodPut, "/communities/legacy", `{"name":"Legacy","visibility":"hidden"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("restrict adminless community: expected 403, got %d", rr.Code)
+	}
+	if rr := do("Cy", http.MethodPost, "/communities/legacy/join", ""); rr.Code != http.StatusOK {
+		t.Fatalf("join public community: expected 200, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,215 @@
+package apihttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/billing"
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// maxWebhookBytes bounds the webhook payloads read from the billing provider.
+const maxWebhookBytes = 1 << 20
+
+// WithBilling takes subscriptions to paid communities through provider and
+// mounts its webhook at /billing/webhook. Without it, plans can be created
+// but not subscribed to.
+func WithBilling(provider billing.Provider) Option {
+	return func(h *Handler) {
+		h.billing = provider
+	}
+}
+
+func (h *Handler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.store.ListPlans(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, db.ErrCommunityNotFound) {
+			http.Error(w, "community not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("list plans failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	plans, ok := paginate(w, r, plans)
+	if !ok {
+		return
+	}
+	writeList(w, r, plans)
+}
+
+// CreatePlan adds a pricing plan. From then on, posts are for admins and
+// members with a trialing or active subscription.
+func (h *Handler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var input db.PlanInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.store.CreatePlan(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		if errors.Is(err, db.ErrCommunityNotFound) {
+			http.Error(w, "community not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("unable to create plan: %v", err), http.StatusBadRequest)
+		return
+	}
+	writeResponse(w, r, http.StatusCreated, plan)
+}
+
+// Subscribe starts the caller's subscription to a plan with the billing
+// provider and records it on their membership, making them a member of
+// public communities. Private communities must be joined first.
+func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromRequest(r)
+	if userID == "" {
+		http.Error(w, "X-User-ID required", http.StatusUnauthorized)
+		return
+	}
+	g := grantFromContext(r.Context())
+	if g.community.Visibility != db.VisibilityPublic && g.membership.Role == "" {
+		http.Error(w, "membership required", http.StatusForbidden)
+		return
+	}
+	if g.membership.Subscription.Paid() {
+		http.Error(w, "already subscribed", http.StatusConflict)
+		return
+	}
+	if h.billing == nil {
+		http.Error(w, "billing not configured", http.StatusNotImplemented)
+		return
+	}
+
+	ctx := r.Context()
+	plan, err := h.store.GetPlan(ctx, g.community.ID, chi.URLParam(r, "planId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrCommunityNotFound):
+			http.Error(w, "community not found", http.StatusNotFound)
+		case errors.Is(err, db.ErrPlanNotFound):
+			http.Error(w, "plan not found", http.StatusNotFound)
+		default:
+			h.logger.Error("get plan failed", zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	sub, err := h.billing.Subscribe(ctx, plan, userID)
+	if err != nil {
+		h.logger.Error("billing subscribe failed", zap.String("plan", plan.ID), zap.Error(err))
+		http.Error(w, "billing provider unavailable", http.StatusBadGateway)
+		return
+	}
+	membership, err := h.store.SetSubscription(ctx, g.community.ID, userID, sub)
+	if err != nil {
+		// Do not leave the caller paying for a subscription nobody knows of.
+		if cancelErr := h.billing.Cancel(ctx, sub.ID); cancelErr != nil {
+			h.logger.Error("cancel unrecorded subscription failed", zap.String("subscription", sub.ID), zap.Error(cancelErr))
+		}
+		switch {
+		case errors.Is(err, db.ErrCommunityNotFound):
+			http.Error(w, "community not found", http.StatusNotFound)
+		case errors.Is(err, db.ErrUserNotFound):
+			http.Error(w, "user not found", http.StatusBadRequest)
+		default:
+			h.logger.Error("record subscription failed", zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeResponse(w, r, http.StatusCreated, membership)
+}
+
+// CancelSubscription ends the caller's subscription with the billing
+// provider. The caller stays a member, without access to posts.
+func (h *Handler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	if userIDFromRequest(r) == "" {
+		http.Error(w, "X-User-ID required", http.StatusUnauthorized)
+		return
+	}
+	membership := grantFromContext(r.Context()).membership
+	sub := membership.Subscription
+	if sub == nil {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+	if sub.Status == db.SubscriptionCanceled {
+		writeResponse(w, r, http.StatusOK, membership)
+		return
+	}
+	if h.billing == nil {
+		http.Error(w, "billing not configured", http.StatusNotImplemented)
+		return
+	}
+
+	if err := h.billing.Cancel(r.Context(), sub.ID); err != nil {
+		h.logger.Error("billing cancel failed", zap.String("subscription", sub.ID), zap.Error(err))
+		http.Error(w, "billing provider unavailable", http.StatusBadGateway)
+		return
+	}
+	membership, err := h.store.UpdateSubscription(r.Context(), sub.ID, db.SubscriptionUpdate{Status: db.SubscriptionCanceled})
+	if err != nil {
+		if errors.Is(err, db.ErrSubscriptionNotFound) {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("cancel subscription failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, membership)
+}
+
+// BillingWebhook applies the subscription changes the billing provider
+// reports, such as renewals and failed payments. Unknown subscriptions answer
+// 404 so the provider retries deliveries that race the subscribe request.
+// Replayed events and events for canceled subscriptions are acknowledged
+// without effect, so the provider stops retrying them.
+func (h *Handler) BillingWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	event, err := h.billing.ParseWebhook(payload, r.Header)
+	if err != nil {
+		if errors.Is(err, billing.ErrInvalidSignature) {
+			http.Error(w, "invalid signature", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("invalid event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.store.UpdateSubscription(r.Context(), event.SubscriptionID, db.SubscriptionUpdate{
+		EventID:          event.ID,
+		Status:           event.Status,
+		CurrentPeriodEnd: event.CurrentPeriodEnd,
+	})
+	switch {
+	case errors.Is(err, db.ErrSubscriptionNotFound):
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrDuplicateEvent), errors.Is(err, db.ErrSubscriptionCanceled):
+		h.logger.Info("ignored billing event", zap.String("event", event.ID),
+			zap.String("subscription", event.SubscriptionID), zap.Error(err))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case err != nil:
+		h.logger.Error("apply billing event failed", zap.String("event", event.ID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("applied billing event", zap.String("event", event.ID), zap.String("type", event.Type),
+		zap.String("subscription", event.SubscriptionID), zap.String("status", string(event.Status)))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,228 @@
+package apihttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/billing"
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestPaidCommunity(t *testing.T) {
+	store := db.NewInMemoryStore()
+	provider := billing.NewFake("whsec")
+	ts := NewRouter(store, zaptest.NewLogger(t),
+		WithValidation(loadSpec(t), ValidationOptions{Requests: true}), WithBilling(provider))
+
+	users := make(map[string]string)
+	for _, name := range []string{"Ada", "Bob", "Cy"} {
+		u, err := store.CreateUser(context.Background(), db.UserInput{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[name] = u.ID
+	}
+
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest(method, path, nil)
+		} else {
+			req = httptest.NewRequest(method, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if user != "" {
+			req.Header.Set(userIDHeader, users[user])
+		}
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr
+	}
+	webhook := func(payload []byte, header http.Header) int {
+		req := httptest.NewRequest(http.MethodPost, "/billing/webhook", bytes.NewReader(payload))
+		req.Header = header
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if rr := do("Ada", http.MethodPost, "/communities", `{"name":"Club"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create community: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("Bob", http.MethodPost, "/communities/club/join", ""); rr.Code != http.StatusOK {
+		t.Fatalf("join: expected 200, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusOK {
+		t.Fatalf("posts of free community: expected 200, got %d", rr.Code)
+	}
+
+	if rr := do("Bob", http.MethodPost, "/communities/club/plans", `{"name":"Monthly","priceCents":900,"currency":"usd"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("create plan as member: expected 403, got %d", rr.Code)
+	}
+	// Communities without an admin are open, but not to paywalls.
+	if rr := do("", http.MethodPost, "/communities", `{"name":"Open"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create anonymous community: expected 201, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodPost, "/communities/open/plans", `{"name":"Monthly","priceCents":900,"currency":"usd"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("create plan without admin: expected 403, got %d", rr.Code)
+	}
+	if rr := do("Ada", http.MethodPost, "/communities/club/plans", `{"name":"Monthly","priceCents":0,"currency":"usd"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("free plan: expected 400, got %d", rr.Code)
+	}
+	rr := do("Ada", http.MethodPost, "/communities/club/plans", `{"name":"Monthly","priceCents":900,"currency":"usd"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create plan: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	plan := decodeResponse[db.Plan](t, rr.Body.Bytes())
+	if plans := decodeResponse[[]db.Plan](t, do("Cy", http.MethodGet, "/communities/club/plans", "").Body.Bytes()); len(plans) != 1 || plans[0] != plan {
+		t.Fatalf("expected plans to be listed to anyone, got %+v", plans)
+	}
+
+	// Plans make posts paid, except for admins.
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusPaymentRequired {
+		t.Fatalf("posts without subscription: expected 402, got %d", rr.Code)
+	}
+	if rr := do("Ada", http.MethodPost, "/communities/club/posts", `{"title":"Hi","content":"paid"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("post as admin: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	subscribe := "/communities/club/plans/" + plan.ID + "/subscribe"
+	if rr := do("", http.MethodPost, subscribe, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous subscribe: expected 401, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodPost, "/communities/club/plans/missing/subscribe", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown plan: expected 404, got %d", rr.Code)
+	}
+	rr = do("Bob", http.MethodPost, subscribe, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("subscribe: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	membership := decodeResponse[db.Membership](t, rr.Body.Bytes())
+	if membership.Role != db.RoleMember || membership.Subscription == nil || membership.Subscription.Status != db.SubscriptionActive {
+		t.Fatalf("unexpected membership %+v", membership)
+	}
+	sub := membership.Subscription
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusOK {
+		t.Fatalf("posts as subscriber: expected 200, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodPost, subscribe, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("subscribe twice: expected 409, got %d", rr.Code)
+	}
+	// Subscribing to a public community joins it.
+	rr = do("Cy", http.MethodPost, subscribe, "")
+	cyMembership := decodeResponse[db.Membership](t, rr.Body.Bytes())
+	if rr.Code != http.StatusCreated || cyMembership.Role != db.RoleMember {
+		t.Fatalf("subscribe as non-member: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Payment events arrive through the signed webhook.
+	payload, header := provider.Webhook(billing.Event{SubscriptionID: sub.ID, Status: db.SubscriptionPastDue})
+	if code := webhook(payload, header); code != http.StatusNoContent {
+		t.Fatalf("webhook: expected 204, got %d", code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusPaymentRequired {
+		t.Fatalf("posts while past due: expected 402, got %d", rr.Code)
+	}
+	// A lost renewal does not keep access past the end of the period.
+	past := time.Now().Add(-time.Minute)
+	if code := webhook(provider.Webhook(billing.Event{SubscriptionID: cyMembership.Subscription.ID, Status: db.SubscriptionActive, CurrentPeriodEnd: &past})); code != http.StatusNoContent {
+		t.Fatalf("expiring webhook: expected 204, got %d", code)
+	}
+	if rr := do("Cy", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusPaymentRequired {
+		t.Fatalf("posts after the period ended: expected 402, got %d", rr.Code)
+	}
+	if code := webhook(billing.NewFake("other").Webhook(billing.Event{SubscriptionID: sub.ID, Status: db.SubscriptionActive})); code != http.StatusBadRequest {
+		t.Fatalf("forged webhook: expected 400, got %d", code)
+	}
+	if code := webhook(provider.Webhook(billing.Event{SubscriptionID: "sub_missing", Status: db.SubscriptionActive})); code != http.StatusNotFound {
+		t.Fatalf("unknown subscription: expected 404, got %d", code)
+	}
+	if code := webhook(provider.Webhook(billing.Event{SubscriptionID: sub.ID, Status: db.SubscriptionActive})); code != http.StatusNoContent {
+		t.Fatalf("renewal webhook: expected 204, got %d", code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusOK {
+		t.Fatalf("posts after renewal: expected 200, got %d", rr.Code)
+	}
+	// A redelivered event is acknowledged but not applied again.
+	if code := webhook(payload, header); code != http.StatusNoContent {
+		t.Fatalf("replayed webhook: expected 204, got %d", code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusOK {
+		t.Fatalf("posts after replayed past due event: expected 200, got %d", rr.Code)
+	}
+	late, lateHeader := provider.Webhook(billing.Event{SubscriptionID: sub.ID, Status: db.SubscriptionActive})
+
+	if rr := do("Ada", http.MethodDelete, "/communities/club/subscription", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("cancel without subscription: expected 404, got %d", rr.Code)
+	}
+	for range 2 {
+		rr = do("Bob", http.MethodDelete, "/communities/club/subscription", "")
+		if m := decodeResponse[db.Membership](t, rr.Body.Bytes()); rr.Code != http.StatusOK || m.Subscription.Status != db.SubscriptionCanceled {
+			t.Fatalf("cancel: got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	if got, _ := provider.Subscription(sub.ID); got.Status != db.SubscriptionCanceled {
+		t.Fatalf("expected the provider subscription to be canceled, got %+v", got)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusPaymentRequired {
+		t.Fatalf("posts after cancel: expected 402, got %d", rr.Code)
+	}
+	// An event sent before the cancellation but delivered after it does not
+	// revive the subscription.
+	if code := webhook(late, lateHeader); code != http.StatusNoContent {
+		t.Fatalf("late webhook: expected 204, got %d", code)
+	}
+	if rr := do("Bob", http.MethodGet, "/communities/club/posts", ""); rr.Code != http.StatusPaymentRequired {
+		t.Fatalf("posts after late event: expected 402, got %d", rr.Code)
+	}
+	if rr := do("Bob", http.MethodPost, subscribe, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("resubscribe: expected 201, got %d", rr.Code)
+	}
+
+	// Private communities must be joined before subscribing.
+	if rr := do("Ada", http.MethodPost, "/communities", `{"name":"Inner","visibility":"private"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create private community: expected 201, got %d", rr.Code)
+	}
+	rr = do("Ada", http.MethodPost, "/communities/inner/plans", `{"name":"Yearly","priceCents":9000,"currency":"usd","interval":"year"}`)
+	inner := decodeResponse[db.Plan](t, rr.Body.Bytes())
+	if rr := do("Bob", http.MethodPost, "/communities/inner/plans/"+inner.ID+"/subscribe", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("subscribe to private community as non-member: expected 403, got %d", rr.Code)
+	}
+}
+
+func TestSubscribeWithoutBilling(t *testing.T) {
+	store := db.NewInMemoryStore()
+	ts := NewRouter(store, zaptest.NewLogger(t))
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, db.UserInput{Name: "Bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Club"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	plan, err := store.CreatePlan(ctx, community.ID, db.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/communities/club/plans/"+plan.ID+"/subscribe", nil)
+	req.Header.Set(userIDHeader, user.ID)
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a billing provider, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/billing/webhook", bytes.NewBufferString(`{}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the webhook to be unmounted without a billing provider, got %d", rr.Code)
+	}
+}
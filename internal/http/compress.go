@@ -0,0 +1,235 @@
+package apihttp
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultCompressMinSize is the smallest response body compressed when
+// WithCompression is not given. Below it the encoding overhead outweighs the
+// savings.
+const DefaultCompressMinSize = 1024
+
+// Content codings the API can apply, in order of preference on ties.
+var encodings = []string{"zstd", "br", "gzip"}
+
+// encoder is a pooled compressor that can be pointed at a new destination.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any { return gzip.NewWriter(io.Discard) }},
+	"br":   {New: func() any { return brotli.NewWriterLevel(io.Discard, 4) }},
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// WithCompression compresses response bodies of at least minSize bytes with
+// the client's preferred content coding. A negative minSize disables
+// compression.
+func WithCompression(minSize int) Option {
+	return func(h *Handler) {
+		h.compressMinSize = minSize
+	}
+}
+
+// negotiateEncoding returns the coding from encodings the Accept-Encoding
+// header weighs highest, or "" for identity.
+func negotiateEncoding(header string) string {
+	accepts := parseWeighted(header)
+	best, bestQ := "", 0.0
+	for _, coding := range encodings {
+		q, specific := 0.0, false
+		for _, accept := range accepts {
+			switch {
+			case accept.value == coding:
+				q, specific = accept.q, true
+			case accept.value == "*" && !specific:
+				q = accept.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether responses of contentType shrink when
+// compressed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == mediaJSON, mediaType == mediaNDJSON, mediaType == mediaMsgPack,
+		mediaType == "application/yaml", mediaType == "application/javascript",
+		mediaType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compress applies the negotiated content coding to compressible responses
+// of at least minSize bytes. A response that is flushed before reaching
+// minSize is compressed anyway, so NDJSON streams stay compressed. Responses
+// that already carry a Content-Encoding, such as /metrics, are left alone.
+func compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			coding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if coding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, coding: coding, minSize: minSize}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	coding  string
+	minSize int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = code
+	// Responses without a body go out at once; a 304 still names the
+	// compressed representation the client holds.
+	if code == http.StatusNotModified || code == http.StatusNoContent || code < http.StatusOK {
+		if code == http.StatusNotModified {
+			cw.tagETag()
+		}
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush commits to compressing (if the response qualifies) and pushes out
+// everything written so far.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if err := cw.decide(true); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide sends the headers and the buffered body, compressing if the
+// response qualifies and large is set.
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	header := cw.Header()
+	eligible := header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+		compressible(header.Get("Content-Type"))
+	if eligible {
+		cw.tagETag()
+	}
+	if eligible && large {
+		header.Set("Content-Encoding", cw.coding)
+		header.Del("Content-Length")
+		cw.enc = encoderPools[cw.coding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// tagETag makes a strong ETag name this coding, since the compressed bytes
+// are a different representation; etagMatches strips the suffix again. Small
+// responses sent uncompressed keep the tag too, so a client's validator does
+// not depend on the body size.
+func (cw *compressWriter) tagETag() {
+	etag := cw.Header().Get("ETag")
+	if strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) && len(etag) > 1 {
+		cw.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+cw.coding+`"`)
+	}
+}
+
+// close finishes the response once the handler returns.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return
+		}
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		_ = cw.decide(len(cw.buf) >= cw.minSize)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(io.Discard)
+		encoderPools[cw.coding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
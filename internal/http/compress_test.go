@@ -0,0 +1,151 @@
+package apihttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     "gzip",
+		"gzip, br":                 "br",
+		"gzip, br, zstd":           "zstd",
+		"gzip;q=1, br;q=0.5":       "gzip",
+		"zstd;q=0, gzip":           "gzip",
+		"*":                        "zstd",
+		"*;q=0.1, gzip;q=0.5":      "gzip",
+		"br;q=0, *":                "zstd",
+		"gzip;q=abc":               "",
+		"deflate, compress, x-foo": "",
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func decompress(t *testing.T, coding string, body []byte) []byte {
+	t.Helper()
+	var (
+		r   io.Reader
+		err error
+	)
+	switch coding {
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		var dec *zstd.Decoder
+		dec, err = zstd.NewReader(bytes.NewReader(body))
+		if err == nil {
+			defer dec.Close()
+			r = dec
+		}
+	default:
+		return body
+	}
+	if err != nil {
+		t.Fatalf("open %s reader: %v", coding, err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress %s: %v", coding, err)
+	}
+	return out
+}
+
+func TestCompression(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	for i := range 50 {
+		if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: strings.Repeat("x", i+1), Description: "compressible"}); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	ts := NewRouter(store, zaptest.NewLogger(t))
+
+	plain := httptest.NewRecorder()
+	ts.ServeHTTP(plain, httptest.NewRequest(http.MethodGet, "/communities", nil))
+	if plain.Header().Get("Content-Encoding") != "" || plain.Body.Len() < DefaultCompressMinSize {
+		t.Fatalf("expected a large identity response, got %q with %d bytes", plain.Header().Get("Content-Encoding"), plain.Body.Len())
+	}
+	plainETag := plain.Header().Get("ETag")
+
+	for _, coding := range []string{"gzip", "br", "zstd"} {
+		req := httptest.NewRequest(http.MethodGet, "/communities", nil)
+		req.Header.Set("Accept-Encoding", coding)
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Content-Encoding"); got != coding {
+			t.Fatalf("%s: unexpected Content-Encoding %q", coding, got)
+		}
+		if !strings.Contains(rr.Header().Get("Vary"), "Accept-Encoding") {
+			t.Fatalf("%s: missing Vary: Accept-Encoding, got %q", coding, rr.Header().Values("Vary"))
+		}
+		if rr.Body.Len() >= plain.Body.Len() {
+			t.Fatalf("%s: body did not shrink (%d >= %d)", coding, rr.Body.Len(), plain.Body.Len())
+		}
+		if got := decompress(t, coding, rr.Body.Bytes()); !bytes.Equal(got, plain.Body.Bytes()) {
+			t.Fatalf("%s: round trip mismatch", coding)
+		}
+		etag := rr.Header().Get("ETag")
+		if etag == plainETag || !strings.HasSuffix(etag, "-"+coding+`"`) {
+			t.Fatalf("%s: expected a coding-specific ETag, got %q (identity %q)", coding, etag, plainETag)
+		}
+
+		// The tagged ETag revalidates.
+		req = httptest.NewRequest(http.MethodGet, "/communities", nil)
+		req.Header.Set("Accept-Encoding", coding)
+		req.Header.Set("If-None-Match", etag)
+		rr = httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotModified || rr.Header().Get("ETag") != etag {
+			t.Fatalf("%s: expected 304 with ETag %q, got %d with %q", coding, etag, rr.Code, rr.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t))
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" || !strings.Contains(rr.Body.String(), `"ok"`) {
+		t.Fatalf("expected small response to stay uncompressed, got %q: %q", rr.Header().Get("Content-Encoding"), rr.Body.String())
+	}
+
+	ts = NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t), WithCompression(0))
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip with no threshold, got %q", rr.Header().Get("Content-Encoding"))
+	}
+	if got := decompress(t, "gzip", rr.Body.Bytes()); !strings.Contains(string(got), `"ok"`) {
+		t.Fatalf("unexpected body %q", got)
+	}
+
+	ts = NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t), WithCompression(-1))
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" || rr.Header().Get("Vary") != "" {
+		t.Fatalf("expected compression disabled, got Content-Encoding %q, Vary %q", rr.Header().Get("Content-Encoding"), rr.Header().Get("Vary"))
+	}
+}
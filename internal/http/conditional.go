@@ -0,0 +1,178 @@
+package apihttp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Route names that carry a cache policy, for WithCachePolicy.
+const (
+	RouteCommunities = "communities"
+	RoutePosts       = "posts"
+	RouteAdmin       = "admin"
+)
+
+// CachePolicy describes the Cache-Control header for a route.
+type CachePolicy struct {
+	// NoStore forbids caching entirely; no validators are computed.
+	NoStore bool
+	// Private restricts caching to the client, excluding shared caches.
+	Private bool
+	// MaxAge is how long a response may be reused without revalidation. At
+	// zero, clients must revalidate every time, which is cheap with the
+	// ETag.
+	MaxAge time.Duration
+}
+
+// header renders p as a Cache-Control value.
+func (p CachePolicy) header() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	scope := "public"
+	if p.Private {
+		scope = "private"
+	}
+	value := fmt.Sprintf("%s, max-age=%d", scope, int(p.MaxAge.Seconds()))
+	if p.MaxAge <= 0 {
+		value += ", must-revalidate"
+	}
+	return value
+}
+
+// defaultCachePolicies make reads revalidate on every use, so a client never
+// sees a list older than the store, and keep admin responses out of caches.
+func defaultCachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{
+		RouteCommunities: {},
+		RoutePosts:       {},
+		RouteAdmin:       {NoStore: true},
+	}
+}
+
+// WithCachePolicy replaces the cache policy of route (RouteCommunities,
+// RoutePosts or RouteAdmin).
+func WithCachePolicy(route string, policy CachePolicy) Option {
+	return func(h *Handler) {
+		h.cachePolicies[route] = policy
+	}
+}
+
+// cacheable applies route's cache policy. Successful GET responses get a
+// strong ETag over the body, and a 304 Not Modified replaces them when the
+// request's If-None-Match, or failing that If-Modified-Since, shows the
+// client already has them. Handlers provide Last-Modified via
+// setLastModified.
+//
+// Responses that vary by X-User-ID, such as posts of paid communities, are
+// always private: a shared cache must never hand them to another caller.
+func (h *Handler) cacheable(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := h.cachePolicies[route]
+			if variesByCaller(w.Header()) {
+				policy.Private = true
+			}
+			// NDJSON is streamed, so it is never buffered to compute an ETag.
+			streamed := negotiate(r.Header.Get("Accept"), listMedia) == mediaNDJSON
+			if policy.NoStore || streamed || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				w.Header().Set("Cache-Control", policy.header())
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &bufferedResponse{header: make(http.Header)}
+			next.ServeHTTP(rec, r)
+			if rec.statusCode() != http.StatusOK {
+				rec.flushTo(w)
+				return
+			}
+
+			rec.header.Set("Cache-Control", policy.header())
+			etag := strongETag(rec.body.Bytes())
+			rec.header.Set("ETag", etag)
+			if notModified(r, etag, rec.header.Get("Last-Modified")) {
+				for _, k := range []string{"Cache-Control", "ETag", "Last-Modified", "Link", "Vary"} {
+					for _, v := range rec.header.Values(k) {
+						w.Header().Add(k, v)
+					}
+				}
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			rec.flushTo(w)
+		})
+	}
+}
+
+// variesByCaller reports whether header lists X-User-ID in Vary.
+func variesByCaller(header http.Header) bool {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), userIDHeader) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// strongETag identifies body byte for byte.
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified evaluates the request's preconditions (RFC 9110 section 13.2):
+// If-Modified-Since only counts when If-None-Match is absent.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatches reports whether the If-None-Match list contains etag, using
+// the weak comparison that header calls for.
+func etagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag || stripCoding(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCoding removes the content coding that compress appends to an ETag.
+func stripCoding(etag string) string {
+	for _, coding := range encodings {
+		if base, ok := strings.CutSuffix(etag, "-"+coding+`"`); ok {
+			return base + `"`
+		}
+	}
+	return etag
+}
+
+// setLastModified sets Last-Modified to t unless t is zero.
+func setLastModified(w http.ResponseWriter, t time.Time) {
+	if !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,134 @@
+package apihttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestConditionalGet(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Go"})
+	if err != nil {
+		t.Fatalf("seed community: %v", err)
+	}
+	if _, err := store.CreatePost(ctx, community.ID, db.PostInput{AuthorID: "u1", Title: "Hello", Content: "World"}); err != nil {
+		t.Fatalf("seed post: %v", err)
+	}
+	ts := NewRouter(store, zaptest.NewLogger(t))
+	postsPath := "/communities/" + community.ID + "/posts"
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(postsPath, nil)
+	etag := rr.Header().Get("ETag")
+	lastModified := rr.Header().Get("Last-Modified")
+	if rr.Code != http.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("expected 200 with validators, got %d, ETag %q, Last-Modified %q", rr.Code, etag, lastModified)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "private, max-age=0, must-revalidate" {
+		t.Fatalf("unexpected Cache-Control %q", cc)
+	}
+
+	rr = get(postsPath, http.Header{"If-None-Match": {`"other", ` + etag}})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag {
+		t.Fatalf("expected empty 304 with ETag, got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = get(postsPath, http.Header{"If-None-Match": {"W/" + etag}})
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("expected weak match to return 304, got %d", rr.Code)
+	}
+	rr = get(postsPath, http.Header{"If-Modified-Since": {lastModified}})
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for If-Modified-Since, got %d", rr.Code)
+	}
+	// If-None-Match wins over If-Modified-Since.
+	rr = get(postsPath, http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {lastModified}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for mismatched ETag, got %d", rr.Code)
+	}
+
+	// Pages of the same list have their own validators.
+	rr = get(postsPath+"?limit=1&offset=1", http.Header{"If-None-Match": {etag}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a different page, got %d", rr.Code)
+	}
+
+	// A write changes the ETag.
+	communitiesETag := get("/communities", nil).Header().Get("ETag")
+	if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Rust"}); err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	rr = get("/communities", http.Header{"If-None-Match": {communitiesETag}})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == communitiesETag {
+		t.Fatalf("expected fresh 200 after a write, got %d with ETag %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	// Errors are not given validators.
+	rr = get("/communities/missing/posts", nil)
+	if rr.Code != http.StatusNotFound || rr.Header().Get("ETag") != "" {
+		t.Fatalf("expected 404 without ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestCachePolicies(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	admin, err := store.CreateUser(ctx, db.UserInput{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Paid", AdminID: admin.ID})
+	if err != nil {
+		t.Fatalf("seed community: %v", err)
+	}
+	if _, err := store.CreatePlan(ctx, community.ID, db.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd"}); err != nil {
+		t.Fatalf("seed plan: %v", err)
+	}
+	ts := NewRouter(store, zaptest.NewLogger(t),
+		WithCachePolicy(RouteCommunities, CachePolicy{MaxAge: 30 * time.Second}),
+		WithCachePolicy(RoutePosts, CachePolicy{MaxAge: time.Minute}),
+		WithAdmin("secret", zap.NewAtomicLevel()),
+	)
+
+	// Responses that depend on the caller stay out of shared caches, even
+	// under a public policy.
+	for path, want := range map[string]string{
+		"/communities":                              "private, max-age=30",
+		"/communities/" + community.Slug:            "private, max-age=30",
+		"/communities/" + community.Slug + "/posts": "private, max-age=60",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(userIDHeader, admin.ID)
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != want {
+			t.Fatalf("GET %s: expected 200 with Cache-Control %q, got %d %q", path, want, rr.Code, rr.Header().Get("Cache-Control"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/flags", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "no-store" || rr.Header().Get("ETag") != "" {
+		t.Fatalf("expected uncached admin response, got %d, Cache-Control %q, ETag %q",
+			rr.Code, rr.Header().Get("Cache-Control"), rr.Header().Get("ETag"))
+	}
+}
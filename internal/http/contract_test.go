@@ -0,0 +1,278 @@
+package apihttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/docs"
+	"github.com/hcuri/skool-mvp-app/internal/billing"
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
+)
+
+// undocumentedRoutes are served by the router but intentionally left out of
+// the OpenAPI spec (tooling endpoints and HEAD aliases of documented GETs).
+var undocumentedRoutes = map[string]bool{
+	"GET /metrics":                           true,
+	"GET /swagger":                           true,
+	"GET /swagger/*":                         true,
+	"GET /swagger/openapi.yaml":              true,
+	"GET /swagger/openapi.json":              true,
+	"GET /swagger/redoc":                     true,
+	"GET /swagger/redoc/redoc.standalone.js": true,
+}
+
+const (
+	contractAdminToken    = "contract-token"
+	contractWebhookSecret = "contract-secret"
+)
+
+func loadSpec(t *testing.T) *openapi.Spec {
+	t.Helper()
+	spec, err := openapi.Load(docs.OpenAPISpec)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	return spec
+}
+
+func newContractRouter(t *testing.T, store db.Store) http.Handler {
+	t.Helper()
+	return NewRouter(store, zaptest.NewLogger(t), WithAdmin(contractAdminToken, zap.NewAtomicLevel()),
+		WithBilling(billing.NewFake(contractWebhookSecret)))
+}
+
+// TestSpecMatchesRouter fails when a route is added without documenting it, or
+// the spec documents a route the router does not serve.
+func TestSpecMatchesRouter(t *testing.T) {
+	spec := loadSpec(t)
+	routes, ok := newContractRouter(t, db.NewInMemoryStore()).(chi.Routes)
+	if !ok {
+		t.Fatalf("router does not expose chi.Routes")
+	}
+
+	served := make(map[string]bool)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if method == http.MethodHead {
+			return nil
+		}
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		served[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+
+	documented := make(map[string]bool)
+	for _, route := range spec.Routes() {
+		key := route.Method + " " + route.Path
+		documented[key] = true
+		if !served[key] {
+			t.Errorf("%s is documented but not served", key)
+		}
+	}
+	for key := range served {
+		if !documented[key] && !undocumentedRoutes[key] {
+			t.Errorf("%s is served but not documented in docs/openapi.yaml", key)
+		}
+	}
+}
+
+// TestSpecMatchesModels keeps component schemas in sync with the db models.
+func TestSpecMatchesModels(t *testing.T) {
+	spec := loadSpec(t)
+	models := map[string]reflect.Type{
+		"Community":           reflect.TypeOf(db.Community{}),
+		"CommunityInput":      reflect.TypeOf(db.CommunityInput{}),
+		"CommunityDetail":     reflect.TypeOf(db.CommunityDetail{}),
+		"Post":                reflect.TypeOf(db.Post{}),
+		"FeatureFlag":         reflect.TypeOf(db.FeatureFlag{}),
+		"FeatureFlagInput":    reflect.TypeOf(db.FeatureFlagInput{}),
+		"HealthReport":        reflect.TypeOf(HealthReport{}),
+		"Membership":          reflect.TypeOf(db.Membership{}),
+		"Invite":              reflect.TypeOf(db.Invite{}),
+		"InviteInput":         reflect.TypeOf(db.InviteInput{}),
+		"JoinRequest":         reflect.TypeOf(db.JoinRequest{}),
+		"JoinRequestDecision": reflect.TypeOf(db.JoinRequestDecision{}),
+		"Plan":                reflect.TypeOf(db.Plan{}),
+		"PlanInput":           reflect.TypeOf(db.PlanInput{}),
+		"Subscription":        reflect.TypeOf(db.Subscription{}),
+		"BillingEvent":        reflect.TypeOf(billing.Event{}),
+	}
+	for name, typ := range models {
+		for _, diff := range spec.CompareStruct(name, typ) {
+			t.Error(diff)
+		}
+	}
+}
+
+// TestContract replays every documented operation against a freshly seeded
+// router and validates status codes and response bodies against the spec.
+func TestContract(t *testing.T) {
+	spec := loadSpec(t)
+
+	for _, route := range spec.Routes() {
+		route := route
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			op, _ := spec.Operation(route.Method, route.Path)
+			store := db.NewInMemoryStore()
+			params := seedContractFixtures(t, store)
+			ts := newContractRouter(t, store)
+
+			path := route.Path
+			for name, value := range params {
+				path = strings.ReplaceAll(path, "{"+name+"}", value)
+			}
+			if strings.Contains(path, "{") {
+				t.Fatalf("no fixture for path parameters in %s", route.Path)
+			}
+
+			var body []byte
+			if op.RequestBody != nil {
+				media, ok := op.RequestBody.Content["application/json"]
+				if !ok {
+					t.Fatalf("request body is not documented as application/json")
+				}
+				example := exampleValue(media)
+				if example == nil {
+					t.Fatalf("request body has no example to replay")
+				}
+				var err error
+				if body, err = json.Marshal(example); err != nil {
+					t.Fatalf("encode example: %v", err)
+				}
+				if errs := spec.ValidateJSON(media.Schema, body, "request"); len(errs) > 0 {
+					t.Fatalf("request example does not match its own schema: %v", errs)
+				}
+			}
+
+			for status, resp := range op.Responses {
+				media, ok := resp.Content["application/json"]
+				if !ok {
+					continue
+				}
+				if example := exampleValue(media); example != nil {
+					encoded, err := json.Marshal(example)
+					if err != nil {
+						t.Fatalf("encode %s example: %v", status, err)
+					}
+					for _, err := range spec.ValidateJSON(media.Schema, encoded, status+" example") {
+						t.Errorf("documented example does not match its schema: %v", err)
+					}
+				}
+			}
+
+			req := httptest.NewRequest(route.Method, path, bytes.NewReader(body))
+			if body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if len(op.Security) > 0 {
+				req.Header.Set("Authorization", "Bearer "+contractAdminToken)
+			}
+			if route.Path == "/billing/webhook" {
+				req.Header.Set(billing.SignatureHeader, billing.Sign([]byte(contractWebhookSecret), body, time.Now()))
+			}
+			req.Header.Set("X-User-ID", params["userId"])
+			rr := httptest.NewRecorder()
+			ts.ServeHTTP(rr, req)
+
+			resp, ok := op.Responses[strconv.Itoa(rr.Code)]
+			if !ok {
+				t.Fatalf("status %d is not documented; body: %s", rr.Code, rr.Body.String())
+			}
+			if rr.Code >= 400 {
+				t.Fatalf("replaying the documented example failed with %d: %s", rr.Code, rr.Body.String())
+			}
+			media, ok := resp.Content["application/json"]
+			if !ok {
+				return
+			}
+			if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Fatalf("expected application/json response, got %q", ct)
+			}
+			for _, err := range spec.ValidateJSON(media.Schema, rr.Body.Bytes(), "response") {
+				t.Errorf("schema mismatch: %v", err)
+			}
+		})
+	}
+}
+
+// seedContractFixtures creates one of each resource and returns the values to
+// substitute for path parameters. userId, the admin of the private fixture
+// community, is sent as X-User-ID; their canceled subscription sub_fixture is
+// the one the webhook example renews.
+func seedContractFixtures(t *testing.T, store db.Store) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+
+	admin, err := store.CreateUser(ctx, db.UserInput{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	applicant, err := store.CreateUser(ctx, db.UserInput{Name: "Bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	community, err := store.CreateCommunity(ctx, db.CommunityInput{Name: "Contract", Description: "fixture", Visibility: db.VisibilityPrivate, AdminID: admin.ID})
+	if err != nil {
+		t.Fatalf("seed community: %v", err)
+	}
+	request, err := store.CreateJoinRequest(ctx, community.ID, applicant.ID)
+	if err != nil {
+		t.Fatalf("seed join request: %v", err)
+	}
+	invite, err := store.CreateInvite(ctx, community.ID, db.InviteInput{})
+	if err != nil {
+		t.Fatalf("seed invite: %v", err)
+	}
+	post, err := store.CreatePost(ctx, community.ID, db.PostInput{AuthorID: "u1", Title: "Fixture", Content: "Body"})
+	if err != nil {
+		t.Fatalf("seed post: %v", err)
+	}
+	plan, err := store.CreatePlan(ctx, community.ID, db.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("seed plan: %v", err)
+	}
+	if _, err := store.SetSubscription(ctx, community.ID, admin.ID, db.Subscription{ID: "sub_fixture", PlanID: plan.ID, Status: db.SubscriptionCanceled}); err != nil {
+		t.Fatalf("seed subscription: %v", err)
+	}
+	flag, err := store.UpsertFeatureFlag(ctx, "contract", db.FeatureFlagInput{Enabled: true, Percentage: 50})
+	if err != nil {
+		t.Fatalf("seed feature flag: %v", err)
+	}
+
+	return map[string]string{
+		"id":        community.ID,
+		"postId":    post.ID,
+		"name":      flag.Name,
+		"requestId": request.ID,
+		"code":      invite.Code,
+		"planId":    plan.ID,
+		"userId":    admin.ID,
+	}
+}
+
+func exampleValue(media *openapi.MediaType) any {
+	if media.Example != nil {
+		return media.Example
+	}
+	for _, ex := range media.Examples {
+		return ex.Value
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package apihttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/features"
+)
+
+// RequireFeature hides the wrapped routes behind feature flag name, evaluated
+// by svc for subject(r). Callers for whom the flag is off get a 404, as if the
+// route did not exist.
+func RequireFeature(svc *features.Service, name string, subject func(*http.Request) features.Subject, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			enabled, err := svc.Enabled(r.Context(), name, subject(r))
+			if err != nil {
+				logger.Error("evaluate feature flag failed", zap.String("flag", name), zap.Error(err))
+			}
+			if !enabled {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CommunitySubject is the {id} community and the X-User-ID caller. Use it
+// inside the /communities/{id} route, after resolveCommunity has swapped a
+// slug for the community's ID; elsewhere CommunityID is a slug or empty.
+func CommunitySubject(r *http.Request) features.Subject {
+	return features.Subject{
+		CommunityID: chi.URLParam(r, "id"),
+		UserID:      userIDFromRequest(r),
+	}
+}
+
+func (h *Handler) ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.store.ListFeatureFlags(r.Context())
+	if err != nil {
+		h.logger.Error("list feature flags failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, flags)
+}
+
+func (h *Handler) GetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	flag, err := h.store.GetFeatureFlag(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, db.ErrFeatureFlagNotFound) {
+			http.Error(w, "feature flag not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("get feature flag failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, flag)
+}
+
+func (h *Handler) PutFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	var input db.FeatureFlagInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	flag, err := h.store.UpsertFeatureFlag(r.Context(), name, input)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to save feature flag: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, overridden := h.features.Override(name); overridden {
+		h.logger.Warn("feature flag is overridden by config; stored value has no effect", zap.String("flag", name))
+	}
+	h.logger.Info("feature flag updated",
+		zap.String("flag", flag.Name),
+		zap.Bool("enabled", flag.Enabled),
+		zap.Int("percentage", flag.Percentage),
+		zap.Strings("communities", flag.Communities),
+	)
+
+	writeJSON(w, http.StatusOK, flag)
+}
+
+func (h *Handler) DeleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.DeleteFeatureFlag(r.Context(), chi.URLParam(r, "name")); err != nil {
+		if errors.Is(err, db.ErrFeatureFlagNotFound) {
+			http.Error(w, "feature flag not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("delete feature flag failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
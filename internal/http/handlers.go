@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/metrics"
 )
 
 func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
@@ -18,12 +19,33 @@ func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) ListCommunities(w http.ResponseWriter, r *http.Request) {
 	communities, err := h.store.ListCommunities(r.Context())
+	if err == nil {
+		communities, err = h.visibleCommunities(r.Context(), userIDFromRequest(r), communities)
+	}
 	if err != nil {
 		h.logger.Error("list communities failed", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, http.StatusOK, communities)
+	communities, ok := paginate(w, r, communities)
+	if !ok {
+		return
+	}
+	writeList(w, r, communities)
+}
+
+func (h *Handler) GetCommunity(w http.ResponseWriter, r *http.Request) {
+	community, err := h.store.GetCommunity(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, db.ErrCommunityNotFound) {
+			http.Error(w, "community not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("get community failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, community)
 }
 
 func (h *Handler) CreateCommunity(w http.ResponseWriter, r *http.Request) {
@@ -32,14 +54,58 @@ func (h *Handler) CreateCommunity(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
+	// The creator becomes the first admin. Without one nobody could let
+	// members into a private or hidden community.
+	input.AdminID = userIDFromRequest(r)
+	if input.AdminID == "" && input.Visibility != "" && input.Visibility != db.VisibilityPublic {
+		http.Error(w, "X-User-ID required for a private or hidden community", http.StatusBadRequest)
+		return
+	}
 
 	community, err := h.store.CreateCommunity(r.Context(), input)
 	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrSlugTaken):
+			http.Error(w, "slug already taken", http.StatusConflict)
+			return
+		case errors.Is(err, db.ErrUserNotFound):
+			http.Error(w, "user not found", http.StatusBadRequest)
+			return
+		}
 		http.Error(w, fmt.Sprintf("unable to create community: %v", err), http.StatusBadRequest)
 		return
 	}
+	metrics.CommunityCreated()
 
-	writeJSON(w, http.StatusCreated, community)
+	writeResponse(w, r, http.StatusCreated, community)
+}
+
+func (h *Handler) UpdateCommunity(w http.ResponseWriter, r *http.Request) {
+	var input db.CommunityInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Other edits only need accessManage, which the route already checked.
+	if input.Visibility != "" && input.Visibility != db.VisibilityPublic && !grantFromContext(r.Context()).allows(accessAdminOnly) {
+		http.Error(w, "admin role required to restrict visibility", http.StatusForbidden)
+		return
+	}
+
+	community, err := h.store.UpdateCommunity(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrCommunityNotFound):
+			http.Error(w, "community not found", http.StatusNotFound)
+		case errors.Is(err, db.ErrSlugTaken):
+			http.Error(w, "slug already taken", http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf("unable to update community: %v", err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, community)
 }
 
 func (h *Handler) DeleteCommunity(w http.ResponseWriter, r *http.Request) {
@@ -57,6 +123,7 @@ func (h *Handler) DeleteCommunity(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	metrics.CommunityDeleted(communityID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -72,7 +139,36 @@ func (h *Handler) ListPosts(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, http.StatusOK, posts)
+	// Posts are newest first, and a new post shifts every page, so the
+	// first post dates the whole list.
+	if len(posts) > 0 {
+		setLastModified(w, posts[0].CreatedAt)
+	}
+	posts, ok := paginate(w, r, posts)
+	if !ok {
+		return
+	}
+	writeList(w, r, posts)
+}
+
+func (h *Handler) GetPost(w http.ResponseWriter, r *http.Request) {
+	post, err := h.store.GetPost(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "postId"))
+	if err != nil {
+		if errors.Is(err, db.ErrCommunityNotFound) {
+			http.Error(w, "community not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, db.ErrPostNotFound) {
+			http.Error(w, "post not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("get post failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// Posts are never modified, so their creation time is exact.
+	setLastModified(w, post.CreatedAt)
+	writeResponse(w, r, http.StatusOK, post)
 }
 
 func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +178,14 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
+	// Posts are authored by the caller; anonymous posts in public
+	// communities have no author.
+	userID := userIDFromRequest(r)
+	if input.AuthorID != "" && input.AuthorID != userID {
+		http.Error(w, "authorId must match X-User-ID", http.StatusForbidden)
+		return
+	}
+	input.AuthorID = userID
 
 	post, err := h.store.CreatePost(r.Context(), communityID, input)
 	if err != nil {
@@ -92,10 +196,13 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("unable to create post: %v", err), http.StatusBadRequest)
 		return
 	}
+	metrics.PostCreated(communityID)
 
-	writeJSON(w, http.StatusCreated, post)
+	writeResponse(w, r, http.StatusCreated, post)
 }
 
+// DeletePost lets a post's author, or whoever manages the community, delete
+// it.
 func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	communityID := chi.URLParam(r, "id")
 	postID := chi.URLParam(r, "postId")
@@ -104,6 +211,23 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !grantFromContext(r.Context()).allows(accessManage) {
+		post, err := h.store.GetPost(r.Context(), communityID, postID)
+		if err != nil {
+			if errors.Is(err, db.ErrPostNotFound) || errors.Is(err, db.ErrCommunityNotFound) {
+				http.Error(w, "post not found", http.StatusNotFound)
+				return
+			}
+			h.logger.Error("get post failed", zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if userID := userIDFromRequest(r); userID == "" || post.AuthorID != userID {
+			http.Error(w, "only the author or an admin may delete a post", http.StatusForbidden)
+			return
+		}
+	}
+
 	if err := h.store.DeletePost(r.Context(), communityID, postID); err != nil {
 		if errors.Is(err, db.ErrCommunityNotFound) {
 			http.Error(w, "community not found", http.StatusNotFound)
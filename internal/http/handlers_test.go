@@ -2,12 +2,18 @@ package apihttp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/features"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
 )
 
@@ -54,6 +60,202 @@ func TestHealthzHead(t *testing.T) {
 	}
 }
 
+type checkerFunc func(ctx context.Context) error
+
+func (f checkerFunc) HealthCheck(ctx context.Context) error { return f(ctx) }
+
+func TestLivez(t *testing.T) {
+	ts := newTestServer(t)
+	req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+	rr := httptest.NewRecorder()
+
+	ts.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	readiness := NewReadiness()
+	failing := false
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t),
+		WithReadiness(readiness),
+		WithHealthCheck("database", checkerFunc(func(context.Context) error {
+			if failing {
+				return errors.New("connection refused")
+			}
+			return nil
+		})),
+	)
+
+	// Healthy dependency.
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	report := decodeResponse[HealthReport](t, rr.Body.Bytes())
+	if report.Status != "ok" || report.Checks["database"].Status != "ok" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// Failing dependency.
+	failing = true
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+	report = decodeResponse[HealthReport](t, rr.Body.Bytes())
+	if report.Checks["database"].Error != "connection refused" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// Liveness is unaffected by dependencies.
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from livez, got %d", rr.Code)
+	}
+
+	// Draining fails readiness even when dependencies recover.
+	failing = false
+	readiness.SetDraining()
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", rr.Code)
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t), WithAdmin("secret", level))
+
+	// Missing token.
+	req := httptest.NewRequest(http.MethodPut, "/admin/log-level", bytes.NewBufferString(`{"level":"debug"}`))
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/log-level", bytes.NewBufferString(`{"level":"debug"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Fatalf("expected debug level, got %s", level.Level())
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	ts := newTestServer(t)
+	req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t), WithRateLimiter(limiter))
+
+	get := func(path string) int {
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+
+	if code := get("/communities"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := get("/communities"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+	// Probes are never limited.
+	if code := get("/livez"); code != http.StatusOK {
+		t.Fatalf("expected 200 from livez, got %d", code)
+	}
+
+	// Lifting the limit takes effect immediately.
+	limiter.SetLimit(0, 0)
+	if code := get("/communities"); code != http.StatusOK {
+		t.Fatalf("expected 200 after lifting limit, got %d", code)
+	}
+}
+
+func TestFeatureFlagAdminAndGating(t *testing.T) {
+	store := db.NewInMemoryStore()
+	logger := zaptest.NewLogger(t)
+	ts := NewRouter(store, logger, WithAdmin("secret", zap.NewAtomicLevel()))
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr
+	}
+
+	community, err := store.CreateCommunity(context.Background(), db.CommunityInput{Name: "Go Fans"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	if _, err := store.CreateCommunity(context.Background(), db.CommunityInput{Name: "Rustaceans"}); err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+
+	rr := admin(http.MethodPut, "/admin/flags/comments", `{"enabled":true,"communities":["`+community.ID+`"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr = admin(http.MethodPut, "/admin/flags/comments", `{"enabled":true,"percentage":101}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid percentage, got %d", rr.Code)
+	}
+
+	rr = admin(http.MethodGet, "/admin/flags", "")
+	flags := decodeResponse[[]db.FeatureFlag](t, rr.Body.Bytes())
+	if len(flags) != 1 || flags[0].Name != "comments" {
+		t.Fatalf("unexpected flags: %+v", flags)
+	}
+
+	// Gate a route on the flag, addressed by slug as under /communities/{id}.
+	h := &Handler{store: store, logger: logger}
+	gated := chi.NewRouter()
+	gated.Route("/communities/{id}", func(r chi.Router) {
+		r.Use(h.resolveCommunity)
+		r.With(RequireFeature(features.NewService(store, nil), "comments", CommunitySubject, logger)).Get("/comments", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+	get := func(path string) int {
+		rr := httptest.NewRecorder()
+		gated.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+	if code := get("/communities/go-fans/comments"); code != http.StatusOK {
+		t.Fatalf("expected 200 for allow-listed community, got %d", code)
+	}
+	if code := get("/communities/rustaceans/comments"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for other community, got %d", code)
+	}
+
+	if rr = admin(http.MethodDelete, "/admin/flags/comments", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if code := get("/communities/go-fans/comments"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 after flag deleted, got %d", code)
+	}
+}
+
 func TestCommunitiesAndPosts(t *testing.T) {
 	ts := newTestServer(t)
 
@@ -82,6 +284,7 @@ func TestCommunitiesAndPosts(t *testing.T) {
 	// Create a post.
 	postBody := bytes.NewBufferString(`{"authorId":"user-1","title":"Hello","content":"World"}`)
 	req = httptest.NewRequest(http.MethodPost, "/communities/"+community.ID+"/posts", postBody)
+	req.Header.Set("X-User-ID", "user-1")
 	rr = httptest.NewRecorder()
 	ts.ServeHTTP(rr, req)
 	if rr.Code != http.StatusCreated {
@@ -104,6 +307,32 @@ func TestCommunitiesAndPosts(t *testing.T) {
 		t.Fatalf("unexpected posts: %+v", posts)
 	}
 
+	// Get the community with its counts.
+	req = httptest.NewRequest(http.MethodGet, "/communities/"+community.ID, nil)
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	detail := decodeResponse[db.CommunityDetail](t, rr.Body.Bytes())
+	if detail.ID != community.ID || detail.PostCount != 1 || detail.MemberCount != 0 {
+		t.Fatalf("unexpected community detail: %+v", detail)
+	}
+
+	// Get the post.
+	req = httptest.NewRequest(http.MethodGet, "/communities/"+community.ID+"/posts/"+post.ID, nil)
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := decodeResponse[db.Post](t, rr.Body.Bytes()); got.ID != post.ID || got.Content != "World" {
+		t.Fatalf("unexpected post: %+v", got)
+	}
+	if rr.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected Last-Modified on post")
+	}
+
 	// Delete post.
 	rr = httptest.NewRecorder()
 	req = httptest.NewRequest(http.MethodDelete, "/communities/"+community.ID+"/posts/"+post.ID, nil)
@@ -112,6 +341,14 @@ func TestCommunitiesAndPosts(t *testing.T) {
 		t.Fatalf("expected 204 on delete post, got %d: %s", rr.Code, rr.Body.String())
 	}
 
+	// The deleted post is gone.
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/communities/"+community.ID+"/posts/"+post.ID, nil)
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted post, got %d", rr.Code)
+	}
+
 	// Delete community.
 	rr = httptest.NewRecorder()
 	req = httptest.NewRequest(http.MethodDelete, "/communities/"+community.ID, nil)
@@ -127,6 +364,12 @@ func TestCommunitiesAndPosts(t *testing.T) {
 	if rr.Code != http.StatusNotFound {
 		t.Fatalf("expected 404 after community delete, got %d", rr.Code)
 	}
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/communities/"+community.ID, nil)
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted community, got %d", rr.Code)
+	}
 }
 
 func TestCommunityValidationErrors(t *testing.T) {
@@ -161,7 +404,11 @@ func TestPostEdgeCases(t *testing.T) {
 	}
 
 	// Invalid JSON payload.
-	req = httptest.NewRequest(http.MethodPost, "/communities/missing/posts", bytes.NewBufferString(`{"title":`))
+	req = httptest.NewRequest(http.MethodPost, "/communities", bytes.NewBufferString(`{"name":"Go"}`))
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	community := decodeResponse[db.Community](t, rr.Body.Bytes())
+	req = httptest.NewRequest(http.MethodPost, "/communities/"+community.ID+"/posts", bytes.NewBufferString(`{"title":`))
 	rr = httptest.NewRecorder()
 	ts.ServeHTTP(rr, req)
 	if rr.Code != http.StatusBadRequest {
@@ -176,3 +423,44 @@ func TestPostEdgeCases(t *testing.T) {
 		t.Fatalf("expected 404 for missing community posts, got %d", rr.Code)
 	}
 }
+
+func TestListPagination(t *testing.T) {
+	store := db.NewInMemoryStore()
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := store.CreateCommunity(context.Background(), db.CommunityInput{Name: name}); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	ts := NewRouter(store, zaptest.NewLogger(t))
+
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/communities?limit=2", nil))
+	page := decodeResponse[[]db.Community](t, rr.Body.Bytes())
+	if len(page) != 2 || page[0].Name != "a" {
+		t.Fatalf("unexpected first page: %v", page)
+	}
+	if link := rr.Header().Get("Link"); link != `</communities?limit=2&offset=2>; rel="next"` {
+		t.Fatalf("unexpected Link header %q", link)
+	}
+
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/communities?limit=2&offset=2", nil))
+	page = decodeResponse[[]db.Community](t, rr.Body.Bytes())
+	if len(page) != 1 || page[0].Name != "c" || rr.Header().Get("Link") != "" {
+		t.Fatalf("unexpected last page: %v, Link %q", page, rr.Header().Get("Link"))
+	}
+
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/communities?offset=10", nil))
+	if body := rr.Body.String(); body != "[]\n" {
+		t.Fatalf("expected empty page, got %q", body)
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=x", "offset=-1"} {
+		rr = httptest.NewRecorder()
+		ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/communities?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
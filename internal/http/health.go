@@ -0,0 +1,103 @@
+package apihttp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// readinessTimeout bounds the total time spent running dependency checks.
+const readinessTimeout = 3 * time.Second
+
+// Readiness tracks whether the server should keep receiving new traffic.
+type Readiness struct {
+	draining atomic.Bool
+}
+
+// NewReadiness returns a Readiness that reports ready until SetDraining is called.
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// SetDraining flips readiness to failing so load balancers stop routing new
+// requests while in-flight ones finish.
+func (r *Readiness) SetDraining() {
+	r.draining.Store(true)
+}
+
+// Draining reports whether SetDraining has been called.
+func (r *Readiness) Draining() bool {
+	return r.draining.Load()
+}
+
+// HealthReport is the JSON body returned by /readyz.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// CheckResult describes the outcome of a single dependency check.
+type CheckResult struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Livez reports whether the process is alive. It never consults dependencies so
+// a database outage does not cause Kubernetes to restart healthy pods.
+func (h *Handler) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server can serve traffic, checking every
+// registered dependency and failing while the server is draining.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.readiness.Draining() {
+		writeJSON(w, http.StatusServiceUnavailable, HealthReport{Status: "draining", Checks: map[string]CheckResult{}})
+		return
+	}
+
+	report := h.runChecks(r.Context())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func (h *Handler) runChecks(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		report = HealthReport{Status: "ok", Checks: make(map[string]CheckResult, len(h.checks))}
+	)
+	for name, checker := range h.checks {
+		wg.Add(1)
+		go func(name string, checker db.HealthChecker) {
+			defer wg.Done()
+			start := time.Now()
+			err := checker.HealthCheck(ctx)
+			result := CheckResult{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = "unavailable"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = "unavailable"
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+	return report
+}
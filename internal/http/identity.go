@@ -0,0 +1,33 @@
+package apihttp
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// userIDHeader carries the caller's user ID, set by the upstream gateway.
+const userIDHeader = "X-User-ID"
+
+// userIDFromRequest returns the caller's user ID, or "" for anonymous callers.
+func userIDFromRequest(r *http.Request) string {
+	return r.Header.Get(userIDHeader)
+}
+
+// withSession tags the request context with the caller's session, the user ID
+// or else the client address, so stores with read replicas can give callers
+// read-your-writes consistency.
+func withSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := userIDFromRequest(r)
+		if session == "" {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			session = "addr:" + host
+		}
+		next.ServeHTTP(w, r.WithContext(db.WithSession(r.Context(), session)))
+	})
+}
@@ -0,0 +1,164 @@
+package apihttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+)
+
+// Media types the public API can respond with.
+const (
+	mediaJSON    = "application/json"
+	mediaNDJSON  = "application/x-ndjson"
+	mediaMsgPack = "application/msgpack"
+)
+
+// mediaAliases maps other names clients use for a media type to the one the
+// API responds with.
+var mediaAliases = map[string]string{
+	"application/vnd.msgpack": mediaMsgPack,
+	"application/x-msgpack":   mediaMsgPack,
+}
+
+// Offers for writeResponse and writeList, in order of preference.
+var (
+	objectMedia = []string{mediaJSON, mediaMsgPack}
+	listMedia   = []string{mediaJSON, mediaNDJSON, mediaMsgPack}
+)
+
+// ndjsonFlushEvery is how many NDJSON lines are written between flushes.
+const ndjsonFlushEvery = 100
+
+// weighted is one element of an Accept or Accept-Encoding header.
+type weighted struct {
+	value string
+	q     float64
+}
+
+// parseWeighted splits a header such as "gzip;q=0.5, br" into its values and
+// quality factors. Elements with malformed q values are dropped.
+func parseWeighted(header string) []weighted {
+	var out []weighted
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, raw, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				q = -1
+				break
+			}
+			q = parsed
+		}
+		if q >= 0 {
+			out = append(out, weighted{value: value, q: q})
+		}
+	}
+	return out
+}
+
+// negotiate picks the offer the client weighs highest, preferring earlier
+// offers on ties. Each offer is weighed by the most specific media range that
+// matches it. An empty header, or one that accepts none of the offers, gets
+// the first offer rather than a 406, as RFC 9110 allows.
+func negotiate(header string, offers []string) string {
+	if header == "" {
+		return offers[0]
+	}
+	accepts := parseWeighted(header)
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		offerType, _, _ := strings.Cut(offer, "/")
+		q, specificity := 0.0, -1
+		for _, accept := range accepts {
+			value := accept.value
+			if alias, ok := mediaAliases[value]; ok {
+				value = alias
+			}
+			s := -1
+			switch value {
+			case offer:
+				s = 2
+			case offerType + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = accept.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// writeResponse encodes payload as JSON or MessagePack, whichever the
+// request's Accept header prefers.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, payload any) {
+	w.Header().Add("Vary", "Accept")
+	if negotiate(r.Header.Get("Accept"), objectMedia) == mediaMsgPack {
+		writeMsgPack(w, status, payload)
+		return
+	}
+	writeJSON(w, status, payload)
+}
+
+// writeList encodes items as a JSON array, NDJSON (one item per line, flushed
+// as it goes) or a MessagePack array, as the request's Accept header prefers.
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	w.Header().Add("Vary", "Accept")
+	switch negotiate(r.Header.Get("Accept"), listMedia) {
+	case mediaMsgPack:
+		writeMsgPack(w, http.StatusOK, items)
+	case mediaNDJSON:
+		w.Header().Set("Content-Type", mediaNDJSON)
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		enc := json.NewEncoder(w)
+		for i, item := range items {
+			if err := enc.Encode(item); err != nil {
+				zap.L().Error("write ndjson response failed", zap.Error(err))
+				return
+			}
+			if (i+1)%ndjsonFlushEvery == 0 {
+				_ = rc.Flush()
+			}
+		}
+	default:
+		writeJSON(w, http.StatusOK, items)
+	}
+}
+
+// writeMsgPack encodes payload using its JSON field names, so both formats
+// share one schema.
+func writeMsgPack(w http.ResponseWriter, status int, payload any) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(payload); err != nil {
+		zap.L().Error("encode msgpack response failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaMsgPack)
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		zap.L().Error("write msgpack response failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,121 @@
+package apihttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                        mediaJSON,
+		"application/json":        mediaJSON,
+		"*/*":                     mediaJSON,
+		"application/x-ndjson":    mediaNDJSON,
+		"application/msgpack":     mediaMsgPack,
+		"application/vnd.msgpack": mediaMsgPack,
+		"application/json;q=0.5, application/x-msgpack":   mediaMsgPack,
+		"application/*;q=0.2, application/x-ndjson;q=0.9": mediaNDJSON,
+		"application/json;q=0, */*":                       mediaNDJSON,
+		"text/html":                                       mediaJSON,
+	}
+	for header, want := range cases {
+		if got := negotiate(header, listMedia); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestListMediaTypes(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: name}); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	ts := NewRouter(store, zaptest.NewLogger(t))
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/communities", nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", accept, rr.Code)
+		}
+		return rr
+	}
+
+	rr := get(mediaNDJSON)
+	if ct := rr.Header().Get("Content-Type"); ct != mediaNDJSON {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+	if rr.Header().Get("ETag") != "" {
+		t.Fatalf("streamed NDJSON should not carry an ETag")
+	}
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(rr.Body.Bytes()))
+	for scanner.Scan() {
+		var c db.Community
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			t.Fatalf("decode ndjson line %q: %v", scanner.Text(), err)
+		}
+		names = append(names, c.Name)
+	}
+	if len(names) != 3 || names[0] != "a" || names[2] != "c" {
+		t.Fatalf("unexpected ndjson items: %v", names)
+	}
+
+	rr = get("application/msgpack")
+	if ct := rr.Header().Get("Content-Type"); ct != mediaMsgPack {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+	var items []map[string]any
+	if err := msgpack.Unmarshal(rr.Body.Bytes(), &items); err != nil {
+		t.Fatalf("decode msgpack: %v", err)
+	}
+	if len(items) != 3 || items[0]["name"] != "a" {
+		t.Fatalf("expected json field names in msgpack, got %v", items)
+	}
+	if vary := rr.Header().Values("Vary"); !contains(vary, "Accept") {
+		t.Fatalf("expected Vary: Accept, got %v", vary)
+	}
+	msgpackETag := rr.Header().Get("ETag")
+	if msgpackETag == "" || msgpackETag == get(mediaJSON).Header().Get("ETag") {
+		t.Fatalf("expected distinct ETags per media type, got %q", msgpackETag)
+	}
+}
+
+func TestCreateNegotiatesMsgPack(t *testing.T) {
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t))
+	req := httptest.NewRequest(http.MethodPost, "/communities", bytes.NewBufferString(`{"name":"Go"}`))
+	req.Header.Set("Accept", "application/msgpack")
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Type") != mediaMsgPack {
+		t.Fatalf("expected 201 msgpack, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var community map[string]any
+	if err := msgpack.Unmarshal(rr.Body.Bytes(), &community); err != nil || community["name"] != "Go" {
+		t.Fatalf("unexpected msgpack body %v (err %v)", community, err)
+	}
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package apihttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxPageSize caps the limit query parameter on list endpoints.
+const maxPageSize = 100
+
+// paginate applies the optional limit and offset query parameters to items.
+// Without a limit the full list is returned, as before pagination existed.
+// When more items follow, a Link header with rel="next" points at the next
+// page. It writes a 400 and returns false for malformed parameters.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) ([]T, bool) {
+	query := r.URL.Query()
+	if !query.Has("limit") && !query.Has("offset") {
+		return items, true
+	}
+
+	limit := maxPageSize
+	if query.Has("limit") {
+		n, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || n < 1 || n > maxPageSize {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", maxPageSize), http.StatusBadRequest)
+			return nil, false
+		}
+		limit = n
+	}
+	offset := 0
+	if query.Has("offset") {
+		n, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return nil, false
+		}
+		offset = n
+	}
+
+	if offset >= len(items) {
+		return []T{}, true
+	}
+	end := offset + limit
+	if end >= len(items) {
+		return items[offset:], true
+	}
+
+	next := url.URL{Path: r.URL.Path}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(end))
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	return items[offset:end], true
+}
@@ -0,0 +1,43 @@
+package apihttp
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter is a process-wide token bucket whose limits can be changed at
+// runtime, e.g. when configuration is reloaded.
+type RateLimiter struct {
+	limiter *rate.Limiter
+}
+
+// NewRateLimiter allows rps requests per second with the given burst. An rps of
+// zero disables limiting.
+func NewRateLimiter(rps, burst int) *RateLimiter {
+	l := &RateLimiter{limiter: rate.NewLimiter(rate.Inf, 0)}
+	l.SetLimit(rps, burst)
+	return l
+}
+
+// SetLimit updates the limiter in place without dropping queued tokens.
+func (l *RateLimiter) SetLimit(rps, burst int) {
+	if rps <= 0 {
+		l.limiter.SetLimit(rate.Inf)
+		return
+	}
+	l.limiter.SetBurst(burst)
+	l.limiter.SetLimit(rate.Limit(rps))
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.limiter.Allow() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
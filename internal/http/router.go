@@ -7,44 +7,169 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"github.com/hcuri/skool-mvp-app/internal/billing"
 	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/features"
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
 )
 
 // Handler bundles dependencies for HTTP handlers.
 type Handler struct {
-	store  db.Store
-	logger *zap.Logger
+	store     db.Store
+	logger    *zap.Logger
+	readiness *Readiness
+	checks    map[string]db.HealthChecker
+	limiter   *RateLimiter
+	features  *features.Service
+	billing   billing.Provider
+
+	spec       *openapi.Spec
+	validation ValidationOptions
+
+	adminToken string
+	logLevel   *zap.AtomicLevel
+
+	cachePolicies   map[string]CachePolicy
+	compressMinSize int
+
+	redoc bool
+}
+
+// Option customizes the router built by NewRouter.
+type Option func(*Handler)
+
+// WithReadiness shares readiness state with the caller so it can flip /readyz
+// to failing during graceful shutdown.
+func WithReadiness(readiness *Readiness) Option {
+	return func(h *Handler) {
+		h.readiness = readiness
+	}
+}
+
+// WithHealthCheck registers an additional dependency check reported by /readyz.
+func WithHealthCheck(name string, checker db.HealthChecker) Option {
+	return func(h *Handler) {
+		h.checks[name] = checker
+	}
+}
+
+// WithRateLimiter applies limiter to the public API routes. Probes, metrics and
+// docs are never limited.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(h *Handler) {
+		h.limiter = limiter
+	}
+}
+
+// WithAdmin mounts the /admin endpoints behind a bearer token. level is exposed
+// at /admin/log-level so verbosity can be changed without a redeploy.
+func WithAdmin(token string, level zap.AtomicLevel) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+		h.logLevel = &level
+	}
+}
+
+// WithFeatures evaluates feature flags with svc, which may carry config overrides.
+func WithFeatures(svc *features.Service) Option {
+	return func(h *Handler) {
+		h.features = svc
+	}
 }
 
 // NewRouter wires routes to handlers and returns an http.Handler.
-func NewRouter(store db.Store, logger *zap.Logger) http.Handler {
+func NewRouter(store db.Store, logger *zap.Logger, opts ...Option) http.Handler {
 	h := &Handler{
-		store:  store,
-		logger: logger,
+		store:     store,
+		logger:    logger,
+		readiness: NewReadiness(),
+		checks:    make(map[string]db.HealthChecker),
+		limiter:   NewRateLimiter(0, 0),
+		features:  features.NewService(store, nil),
+
+		cachePolicies:   defaultCachePolicies(),
+		compressMinSize: DefaultCompressMinSize,
+	}
+	if checker, ok := db.HealthCheckerFor(store); ok {
+		h.checks["database"] = checker
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
 
 	r := chi.NewRouter()
 	r.Use(metricsMiddleware)
 	r.Use(requestLogger(h.logger))
+	if h.compressMinSize >= 0 {
+		r.Use(compress(h.compressMinSize))
+	}
+	r.Use(withSession)
+	if h.spec != nil {
+		r.Use(h.validate)
+	}
 
 	r.Get("/healthz", h.Healthz)
 	r.Head("/healthz", h.Healthz)
+	r.Get("/livez", h.Livez)
+	r.Head("/livez", h.Livez)
+	r.Get("/readyz", h.Readyz)
+	r.Head("/readyz", h.Readyz)
 	r.Get("/metrics", promhttp.Handler().ServeHTTP)
 
 	r.Route("/communities", func(r chi.Router) {
-		r.Get("/", h.ListCommunities)
+		r.Use(h.limiter.Middleware)
+		r.With(varyByCaller, h.cacheable(RouteCommunities)).Get("/", h.ListCommunities)
 		r.Post("/", h.CreateCommunity)
-		r.Delete("/{id}", h.DeleteCommunity)
 
-		r.Route("/{id}/posts", func(r chi.Router) {
-			r.Get("/", h.ListPosts)
-			r.Post("/", h.CreatePost)
-			r.Delete("/{postId}", h.DeletePost)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(h.resolveCommunity)
+			r.With(h.authorize(accessView), h.cacheable(RouteCommunities)).Get("/", h.GetCommunity)
+			r.With(h.authorize(accessManage)).Put("/", h.UpdateCommunity)
+			r.With(h.authorize(accessManage)).Delete("/", h.DeleteCommunity)
+
+			r.With(h.authorize(accessView)).Post("/join", h.JoinCommunity)
+			r.Route("/join-requests", func(r chi.Router) {
+				r.Use(h.authorize(accessManage))
+				r.Get("/", h.ListJoinRequests)
+				r.Put("/{requestId}", h.DecideJoinRequest)
+			})
+			r.With(h.authorize(accessManage)).Post("/invites", h.CreateInvite)
+
+			r.With(h.authorize(accessView)).Get("/plans", h.ListPlans)
+			r.With(h.authorize(accessAdminOnly)).Post("/plans", h.CreatePlan)
+			r.With(h.authorize(accessView)).Post("/plans/{planId}/subscribe", h.Subscribe)
+			r.With(h.authorize(accessView)).Delete("/subscription", h.CancelSubscription)
+
+			r.Route("/posts", func(r chi.Router) {
+				r.Use(h.authorize(accessPosts))
+				r.With(h.cacheable(RoutePosts)).Get("/", h.ListPosts)
+				r.Post("/", h.CreatePost)
+				r.With(h.cacheable(RoutePosts)).Get("/{postId}", h.GetPost)
+				r.Delete("/{postId}", h.DeletePost)
+			})
 		})
 	})
 
-	r.Get("/swagger", swaggerUIHandler)
-	r.Get("/swagger/openapi.yaml", openAPISpecHandler)
+	r.With(h.limiter.Middleware).Post("/invites/{code}", h.RedeemInvite)
+	if h.billing != nil {
+		r.Post("/billing/webhook", h.BillingWebhook)
+	}
+
+	if h.adminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(requireAdminToken(h.adminToken))
+			r.Use(h.cacheable(RouteAdmin))
+			r.With(jsonContentType).Method(http.MethodGet, "/log-level", h.logLevel)
+			r.With(jsonContentType).Method(http.MethodPut, "/log-level", h.logLevel)
+
+			r.Get("/flags", h.ListFeatureFlags)
+			r.Get("/flags/{name}", h.GetFeatureFlag)
+			r.Put("/flags/{name}", h.PutFeatureFlag)
+			r.Delete("/flags/{name}", h.DeleteFeatureFlag)
+		})
+	}
+
+	h.mountDocs(r)
 
 	return r
 }
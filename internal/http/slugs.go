@@ -0,0 +1,68 @@
+package apihttp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// resolveCommunity lets every /communities/{id} route take a slug in place of
+// the ID. A current slug is swapped for the community's ID before the handler
+// runs. A former slug redirects to the same URL with the current slug: 301 for
+// GET and HEAD, 308 otherwise so the method and body are kept. Redirects are
+// not cached, since deleting a community frees its slugs for reuse. Former
+// slugs of hidden communities only redirect members.
+func (h *Handler) resolveCommunity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ref := chi.URLParam(r, "id")
+		if !db.IsSlug(ref) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		community, err := h.store.GetCommunityBySlug(r.Context(), ref)
+		if err != nil {
+			if errors.Is(err, db.ErrCommunityNotFound) {
+				http.Error(w, "community not found", http.StatusNotFound)
+				return
+			}
+			h.logger.Error("resolve community slug failed", zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if community.Slug != ref {
+			if community.Visibility == db.VisibilityHidden {
+				m, err := h.membership(r.Context(), community.ID, userIDFromRequest(r))
+				if err != nil || m.Role == "" {
+					http.Error(w, "community not found", http.StatusNotFound)
+					return
+				}
+			}
+			// Only the {id} segment changes: a slug may also appear
+			// elsewhere in the path, "communities" included.
+			segments := strings.SplitN(r.URL.Path, "/", 4)
+			segments[2] = community.Slug
+			target := strings.Join(segments, "/")
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			code := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				code = http.StatusMovedPermanently
+			}
+			w.Header().Set("Location", target)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(code)
+			return
+		}
+
+		// chi.URLParam returns the most recently added value for a key.
+		chi.RouteContext(r.Context()).URLParams.Add("id", community.ID)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,110 @@
+package apihttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestCommunitySlugs(t *testing.T) {
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t),
+		WithValidation(loadSpec(t), ValidationOptions{Requests: true}))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest(method, path, nil)
+		} else {
+			req = httptest.NewRequest(method, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/communities", `{"name":"Go Fans"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	community := decodeResponse[db.Community](t, rr.Body.Bytes())
+	if community.Slug != "go-fans" {
+		t.Fatalf("expected generated slug go-fans, got %q", community.Slug)
+	}
+	if rr := do(http.MethodPost, "/communities", `{"name":"Copy","slug":"go-fans"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("taken slug: expected 409, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/communities", `{"name":"Bad","slug":"Not A Slug"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid slug: expected 400, got %d", rr.Code)
+	}
+
+	// The slug works wherever the ID does.
+	rr = do(http.MethodGet, "/communities/go-fans", "")
+	if rr.Code != http.StatusOK || decodeResponse[db.CommunityDetail](t, rr.Body.Bytes()).ID != community.ID {
+		t.Fatalf("get by slug: got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = do(http.MethodPost, "/communities/go-fans/posts", `{"title":"Hi","content":"there"}`)
+	if rr.Code != http.StatusCreated || decodeResponse[db.Post](t, rr.Body.Bytes()).CommunityID != community.ID {
+		t.Fatalf("create post by slug: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/communities/no-such-slug/posts", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown slug: expected 404, got %d", rr.Code)
+	}
+
+	// Changing the slug leaves a redirect behind.
+	rr = do(http.MethodPut, "/communities/"+community.ID, `{"name":"Gophers","slug":"gophers"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if updated := decodeResponse[db.Community](t, rr.Body.Bytes()); updated.Slug != "gophers" || updated.Name != "Gophers" {
+		t.Fatalf("unexpected updated community %+v", updated)
+	}
+	rr = do(http.MethodGet, "/communities/go-fans/posts?limit=1", "")
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/communities/gophers/posts?limit=1" {
+		t.Fatalf("GET by former slug: expected 301 to the new slug, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected redirect to be revalidated, got Cache-Control %q", rr.Header().Get("Cache-Control"))
+	}
+	rr = do(http.MethodPost, "/communities/go-fans/posts", `{"title":"Hi","content":"again"}`)
+	if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != "/communities/gophers/posts" {
+		t.Fatalf("POST by former slug: expected 308 to the new slug, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = do(http.MethodPost, "/communities", `{"name":"Rust"}`)
+	other := decodeResponse[db.Community](t, rr.Body.Bytes())
+	if rr := do(http.MethodPut, "/communities/"+other.Slug, `{"name":"Rust","slug":"go-fans"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("former slug of another community: expected 409, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPut, "/communities/no-such-slug", `{"name":"x"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("update unknown slug: expected 404, got %d", rr.Code)
+	}
+
+	if rr := do(http.MethodDelete, "/communities/gophers", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete by slug: expected 204, got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/communities/go-fans", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("former slug after delete: expected 404, got %d", rr.Code)
+	}
+
+	// A slug that also matches the start of the path only replaces {id}.
+	for _, slug := range []string{"communities", "c", "comm"} {
+		rr = do(http.MethodPost, "/communities", `{"name":"Prefix","slug":"`+slug+`"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("create %q: expected 201, got %d: %s", slug, rr.Code, rr.Body.String())
+		}
+		c := decodeResponse[db.Community](t, rr.Body.Bytes())
+		if rr := do(http.MethodPut, "/communities/"+c.ID, `{"name":"Prefix","slug":"renamed-`+slug+`"}`); rr.Code != http.StatusOK {
+			t.Fatalf("rename %q: expected 200, got %d", slug, rr.Code)
+		}
+		rr = do(http.MethodGet, "/communities/"+slug+"/posts", "")
+		if want := "/communities/renamed-" + slug + "/posts"; rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != want {
+			t.Fatalf("former slug %q: expected 301 to %s, got %d %q", slug, want, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+}
@@ -1,14 +1,78 @@
 package apihttp
 
 import (
+	"io/fs"
 	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
 
 	"github.com/hcuri/skool-mvp-app/docs"
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
 )
 
-func swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, _ = w.Write([]byte(swaggerHTML))
+// docsCSP keeps the docs pages on same-origin assets. Both UIs inject inline
+// styles, and ReDoc renders in a blob: worker.
+const docsCSP = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; worker-src 'self' blob:"
+
+const redocScript = "redoc.standalone.js"
+
+// openAPIJSON renders the embedded spec as JSON once, on first request.
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	return openapi.ToJSON(docs.OpenAPISpec)
+})
+
+// WithReDoc also serves the embedded ReDoc view at /swagger/redoc/.
+func WithReDoc() Option {
+	return func(h *Handler) {
+		h.redoc = true
+	}
+}
+
+// mountDocs serves the embedded Swagger UI under /swagger/, the spec as YAML
+// and JSON, and the ReDoc view when enabled.
+func (h *Handler) mountDocs(r chi.Router) {
+	swaggerUI, err := fs.Sub(docs.SwaggerUI, "swagger-ui")
+	if err != nil {
+		panic(err) // the embed pattern guarantees the directory
+	}
+	redoc, err := fs.Sub(docs.ReDoc, "redoc")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := fs.Stat(redoc, redocScript); h.redoc && err != nil {
+		h.logger.Warn("ReDoc view enabled but its bundle is not vendored; run make redoc", zap.Error(err))
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(docsSecurityHeaders)
+		r.Get("/swagger", http.RedirectHandler("/swagger/", http.StatusMovedPermanently).ServeHTTP)
+		r.Get("/swagger/openapi.yaml", openAPISpecHandler)
+		r.Get("/swagger/openapi.json", openAPIJSONHandler)
+		r.Get("/swagger/*", http.StripPrefix("/swagger/", http.FileServerFS(swaggerUI)).ServeHTTP)
+
+		if h.redoc {
+			r.Get("/swagger/redoc", http.RedirectHandler("/swagger/redoc/", http.StatusMovedPermanently).ServeHTTP)
+			r.Get("/swagger/redoc/", serveFile(redoc, "index.html"))
+			r.Get("/swagger/redoc/"+redocScript, serveFile(redoc, redocScript))
+		}
+	})
+}
+
+func docsSecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", docsCSP)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveFile serves a single file from fsys regardless of the request path.
+func serveFile(fsys fs.FS, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, fsys, name)
+	}
 }
 
 func openAPISpecHandler(w http.ResponseWriter, r *http.Request) {
@@ -17,23 +81,13 @@ func openAPISpecHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(docs.OpenAPISpec)
 }
 
-const swaggerHTML = `<!doctype html>
-<html lang="en">
-<head>
-  <meta charset="UTF-8">
-  <title>Skool MVP API</title>
-  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
-</head>
-<body>
-  <div id="swagger-ui"></div>
-  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
-  <script>
-    window.onload = () => {
-      SwaggerUIBundle({
-        url: '/swagger/openapi.yaml',
-        dom_id: '#swagger-ui'
-      });
-    };
-  </script>
-</body>
-</html>`
+func openAPIJSONHandler(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPIJSON()
+	if err != nil {
+		http.Error(w, "failed to render openapi spec", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(spec)
+}
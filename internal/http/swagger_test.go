@@ -0,0 +1,106 @@
+package apihttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/docs"
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func TestSwaggerUIIsSelfHosted(t *testing.T) {
+	ts := newTestServer(t)
+
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger", nil))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/swagger/" {
+		t.Fatalf("expected redirect to /swagger/, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for index, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); strings.Contains(body, "://") || !strings.Contains(body, "swagger-ui-bundle.js") {
+		t.Fatalf("index should only reference local assets:\n%s", body)
+	}
+	if csp := rr.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'self'") {
+		t.Fatalf("expected same-origin CSP, got %q", csp)
+	}
+
+	for _, asset := range []string{"swagger-ui-bundle.js", "swagger-ui.css", "swagger-initializer.js", "favicon-32x32.png"} {
+		rr = httptest.NewRecorder()
+		ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/"+asset, nil))
+		if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+			t.Fatalf("asset %s: expected 200 with body, got %d", asset, rr.Code)
+		}
+	}
+}
+
+func TestReDocIsSelfHosted(t *testing.T) {
+	ts := NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t), WithReDoc())
+
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/redoc", nil))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/swagger/redoc/" {
+		t.Fatalf("expected redirect to /swagger/redoc/, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/redoc/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the ReDoc page, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); strings.Contains(body, "://") || !strings.Contains(body, "redoc.standalone.js") {
+		t.Fatalf("ReDoc page should only reference local assets:\n%s", body)
+	}
+	if csp := rr.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'self'") {
+		t.Fatalf("expected same-origin CSP, got %q", csp)
+	}
+
+	bundle, err := fs.ReadFile(docs.ReDoc, "redoc/redoc.standalone.js")
+	if err != nil {
+		t.Skipf("ReDoc bundle is not vendored (run make redoc): %v", err)
+	}
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/redoc/redoc.standalone.js", nil))
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), bundle) {
+		t.Fatalf("expected the embedded bundle, got %d with %d bytes", rr.Code, rr.Body.Len())
+	}
+}
+
+func TestReDocIsOptIn(t *testing.T) {
+	ts := newTestServer(t)
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/redoc/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected ReDoc to be off by default, got %d", rr.Code)
+	}
+}
+
+func TestOpenAPIJSON(t *testing.T) {
+	ts := newTestServer(t)
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/openapi.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+	doc := decodeResponse[map[string]json.RawMessage](t, rr.Body.Bytes())
+	for _, key := range []string{"openapi", "paths", "components"} {
+		if _, ok := doc[key]; !ok {
+			t.Fatalf("JSON spec is missing %q", key)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package apihttp
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
+)
+
+// maxValidatedBodyBytes caps how much of a request body is buffered for validation.
+const maxValidatedBodyBytes = 1 << 20
+
+// ValidationFailure is the structured body returned when a request does not
+// match the OpenAPI spec.
+type ValidationFailure struct {
+	Error   string                    `json:"error"`
+	Details []openapi.ValidationError `json:"details"`
+}
+
+// ValidationOptions selects what is checked against the OpenAPI spec.
+type ValidationOptions struct {
+	// Requests rejects non-conforming requests with a structured 400 (or 415
+	// for an undocumented content type).
+	Requests bool
+	// Responses logs contract violations in outgoing responses. It buffers
+	// every response and is meant for development.
+	Responses bool
+}
+
+// WithValidation enforces spec on documented operations.
+func WithValidation(spec *openapi.Spec, opts ValidationOptions) Option {
+	return func(h *Handler) {
+		h.spec = spec
+		h.validation = opts
+	}
+}
+
+// validate checks traffic for documented operations against the spec. Paths
+// and methods the spec does not describe pass through untouched so routing can
+// answer 404/405 as usual.
+func (h *Handler) validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tmpl, pathParams, ok := h.spec.Match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op, ok := h.spec.Operation(r.Method, tmpl)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if h.validation.Requests && !h.validRequest(w, r, tmpl, pathParams, op) {
+			return
+		}
+
+		// NDJSON is streamed, so it is never buffered to be checked.
+		streamed := negotiate(r.Header.Get("Accept"), listMedia) == mediaNDJSON
+		if !h.validation.Responses || streamed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &bufferedResponse{header: make(http.Header)}
+		next.ServeHTTP(rec, r)
+		h.checkResponse(r, tmpl, op, rec)
+		rec.flushTo(w)
+	})
+}
+
+// validRequest reports whether r conforms to op, writing the error response
+// when it does not.
+func (h *Handler) validRequest(w http.ResponseWriter, r *http.Request, tmpl string, pathParams map[string]string, op *openapi.Operation) bool {
+	var errs []openapi.ValidationError
+	for _, p := range h.spec.Parameters(r.Method, tmpl) {
+		var (
+			raw     string
+			present bool
+		)
+		switch p.In {
+		case "path":
+			raw, present = pathParams[p.Name]
+		case "query":
+			present = r.URL.Query().Has(p.Name)
+			raw = r.URL.Query().Get(p.Name)
+		case "header":
+			raw = r.Header.Get(p.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				errs = append(errs, openapi.ValidationError{Path: p.In + "." + p.Name, Message: "is required"})
+			}
+			continue
+		}
+		errs = append(errs, h.spec.ValidateParam(p, raw, p.In+"."+p.Name)...)
+	}
+
+	if op.RequestBody != nil {
+		status, bodyErrs := h.validateBody(r, op.RequestBody)
+		if status == http.StatusUnsupportedMediaType {
+			writeJSON(w, status, ValidationFailure{Error: "unsupported media type", Details: bodyErrs})
+			return false
+		}
+		errs = append(errs, bodyErrs...)
+	}
+
+	if len(errs) > 0 {
+		h.logger.Info("request rejected by openapi validation",
+			zap.String("method", r.Method),
+			zap.String("route", tmpl),
+			zap.Any("errors", errs),
+		)
+		writeJSON(w, http.StatusBadRequest, ValidationFailure{Error: "request validation failed", Details: errs})
+		return false
+	}
+	return true
+}
+
+// validateBody reads and validates the request body, then restores it for
+// the handler. It returns 415 when the content type is not documented.
+func (h *Handler) validateBody(r *http.Request, body *openapi.RequestBody) (int, []openapi.ValidationError) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBodyBytes+1))
+	if err != nil {
+		return http.StatusBadRequest, []openapi.ValidationError{{Path: "body", Message: "unreadable body"}}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	if len(raw) > maxValidatedBodyBytes {
+		return http.StatusBadRequest, []openapi.ValidationError{{Path: "body", Message: "body too large"}}
+	}
+	if len(raw) == 0 {
+		if body.Required {
+			return http.StatusBadRequest, []openapi.ValidationError{{Path: "body", Message: "is required"}}
+		}
+		return http.StatusOK, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := body.Content[mediaType]
+	if !ok {
+		return http.StatusUnsupportedMediaType, []openapi.ValidationError{{
+			Path:    "header.Content-Type",
+			Message: "must be application/json",
+		}}
+	}
+	return http.StatusOK, h.spec.ValidateJSON(media.Schema, raw, "body")
+}
+
+// checkResponse logs any way the buffered response deviates from the spec.
+func (h *Handler) checkResponse(r *http.Request, tmpl string, op *openapi.Operation, rec *bufferedResponse) {
+	status := rec.statusCode()
+	fields := []zap.Field{
+		zap.String("method", r.Method),
+		zap.String("route", tmpl),
+		zap.Int("status", status),
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		h.logger.Warn("openapi contract violation: undocumented status", fields...)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		if len(resp.Content) > 0 {
+			h.logger.Warn("openapi contract violation: undocumented content type",
+				append(fields, zap.String("content_type", mediaType))...)
+		}
+		return
+	}
+	// Only JSON bodies are checked against the schema; NDJSON and MessagePack
+	// encode the same models.
+	if mediaType != mediaJSON {
+		return
+	}
+	if errs := h.spec.ValidateJSON(media.Schema, rec.body.Bytes(), "response"); len(errs) > 0 {
+		h.logger.Warn("openapi contract violation: response does not match schema",
+			append(fields, zap.Any("errors", errs))...)
+	}
+}
+
+// bufferedResponse captures a response so it can be inspected before sending.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+func (b *bufferedResponse) flushTo(w http.ResponseWriter) {
+	// Add rather than replace, so values set by outer middleware (such as
+	// Vary) survive.
+	for k, v := range b.header {
+		w.Header()[k] = append(w.Header()[k], v...)
+	}
+	w.WriteHeader(b.statusCode())
+	_, _ = w.Write(b.body.Bytes())
+}
@@ -0,0 +1,130 @@
+package apihttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+func newValidatingServer(t *testing.T) http.Handler {
+	return NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t),
+		WithValidation(loadSpec(t), ValidationOptions{Requests: true}))
+}
+
+func TestRequestValidation(t *testing.T) {
+	ts := newValidatingServer(t)
+
+	post := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rr := httptest.NewRecorder()
+		ts.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Missing required field and wrong type.
+	rr := post("/communities", "application/json", `{"description":5}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	failure := decodeResponse[ValidationFailure](t, rr.Body.Bytes())
+	var paths []string
+	for _, d := range failure.Details {
+		paths = append(paths, d.Path)
+	}
+	if got := strings.Join(paths, ","); got != "body.name,body.description" {
+		t.Fatalf("unexpected details: %+v", failure.Details)
+	}
+
+	// Wrong content type.
+	rr = post("/communities", "text/plain", `{"name":"Go"}`)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rr.Code)
+	}
+
+	// Malformed JSON.
+	rr = post("/communities", "application/json", `{"name":`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	// Valid request reaches the handler with its body intact.
+	rr = post("/communities", "application/json; charset=utf-8", `{"name":"Go"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Undocumented routes pass through to the router.
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from metrics, got %d", rr.Code)
+	}
+}
+
+func TestResponseValidationLogsViolations(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	h := &Handler{logger: zap.New(core), spec: loadSpec(t), validation: ValidationOptions{Responses: true}}
+
+	bad := h.validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]any{{"id": 1}})
+	}))
+	rr := httptest.NewRecorder()
+	bad.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/communities", nil))
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"id":1`) {
+		t.Fatalf("response must be passed through unchanged, got %d %s", rr.Code, rr.Body.String())
+	}
+	entries := logs.FilterMessage("openapi contract violation: response does not match schema").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected one contract violation log, got %d", logs.Len())
+	}
+}
+
+func TestRequestRejectionsAreLogged(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	h := &Handler{logger: zap.New(core), spec: loadSpec(t), validation: ValidationOptions{Requests: true}}
+
+	handler := h.validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("invalid request reached the handler")
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/communities", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if n := logs.FilterMessage("request rejected by openapi validation").Len(); n != 1 {
+		t.Fatalf("expected the rejection to be logged at info, got %d entries", n)
+	}
+}
+
+func TestResponseValidationKeepsNDJSONStreaming(t *testing.T) {
+	h := &Handler{logger: zaptest.NewLogger(t), spec: loadSpec(t), validation: ValidationOptions{Responses: true}}
+
+	var flushable bool
+	handler := h.validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flushable = w.(http.Flusher)
+		w.Header().Set("Content-Type", mediaNDJSON)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/communities", nil)
+	req.Header.Set("Accept", mediaNDJSON)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !flushable {
+		t.Fatalf("NDJSON responses must reach the handler unbuffered")
+	}
+}
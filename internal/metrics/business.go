@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxCommunityLabels bounds the number of distinct community label values a
+// single process will export. Anything beyond it is folded into overflowLabel.
+const (
+	maxCommunityLabels = 100
+	overflowLabel      = "other"
+)
+
+var (
+	communitiesCreatedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "skool_communities_created_total",
+			Help: "Total number of communities created",
+		},
+	)
+
+	communitiesDeletedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "skool_communities_deleted_total",
+			Help: "Total number of communities deleted",
+		},
+	)
+
+	postsCreatedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "skool_posts_created_total",
+			Help: "Total number of posts created by community",
+		},
+		[]string{"community"},
+	)
+
+	communitiesGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "skool_communities",
+			Help: "Current number of communities",
+		},
+	)
+
+	postsGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "skool_posts",
+			Help: "Current number of posts",
+		},
+	)
+
+	activeMembersGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "skool_active_members",
+			Help: "Number of distinct members who posted recently",
+		},
+	)
+
+	communityLabels = newLabelLimiter(maxCommunityLabels)
+)
+
+// CommunityCreated increments the community creation counter.
+func CommunityCreated() {
+	communitiesCreatedTotal.Inc()
+}
+
+// CommunityDeleted increments the community deletion counter and releases the
+// community's label slot so it can be reused.
+func CommunityDeleted(communityID string) {
+	communitiesDeletedTotal.Inc()
+	if communityLabels.release(communityID) {
+		postsCreatedTotal.DeleteLabelValues(communityID)
+	}
+}
+
+// PostCreated increments the per-community post counter.
+func PostCreated(communityID string) {
+	postsCreatedTotal.WithLabelValues(communityLabels.value(communityID)).Inc()
+}
+
+// Totals is a point-in-time snapshot of product-level counts.
+type Totals struct {
+	Communities   int
+	Posts         int
+	ActiveMembers int
+}
+
+// TotalsFunc reads the current totals, typically from the store.
+type TotalsFunc func(ctx context.Context) (Totals, error)
+
+// RunTotalsCollector refreshes the business gauges from fn every interval until
+// ctx is cancelled. Errors are passed to onError and leave the gauges untouched.
+func RunTotalsCollector(ctx context.Context, interval time.Duration, fn TotalsFunc, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		totals, err := fn(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if onError != nil {
+				onError(err)
+			}
+		} else {
+			communitiesGauge.Set(float64(totals.Communities))
+			postsGauge.Set(float64(totals.Posts))
+			activeMembersGauge.Set(float64(totals.ActiveMembers))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// labelLimiter caps label cardinality by handing out at most max distinct values.
+type labelLimiter struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newLabelLimiter(max int) *labelLimiter {
+	return &labelLimiter{max: max, seen: make(map[string]struct{})}
+}
+
+// value returns v if it is already tracked or there is room to track it, and
+// overflowLabel otherwise.
+func (l *labelLimiter) value(v string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[v]; ok {
+		return v
+	}
+	if len(l.seen) >= l.max {
+		return overflowLabel
+	}
+	l.seen[v] = struct{}{}
+	return v
+}
+
+// release stops tracking v and reports whether it was tracked.
+func (l *labelLimiter) release(v string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[v]; !ok {
+		return false
+	}
+	delete(l.seen, v)
+	return true
+}
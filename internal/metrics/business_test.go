@@ -0,0 +1,28 @@
+package metrics
+
+import "testing"
+
+func TestLabelLimiterFoldsOverflow(t *testing.T) {
+	l := newLabelLimiter(2)
+
+	if got := l.value("a"); got != "a" {
+		t.Fatalf("expected a, got %s", got)
+	}
+	if got := l.value("b"); got != "b" {
+		t.Fatalf("expected b, got %s", got)
+	}
+	if got := l.value("c"); got != overflowLabel {
+		t.Fatalf("expected overflow label, got %s", got)
+	}
+	// Already tracked values keep their own label.
+	if got := l.value("a"); got != "a" {
+		t.Fatalf("expected a, got %s", got)
+	}
+
+	if !l.release("a") {
+		t.Fatalf("expected a to be released")
+	}
+	if got := l.value("c"); got != "c" {
+		t.Fatalf("expected c after release, got %s", got)
+	}
+}
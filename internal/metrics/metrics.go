@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"database/sql"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
@@ -27,6 +30,39 @@ var (
 		},
 		[]string{"route", "method", "status"},
 	)
+
+	dbQueryDurationSeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of store operations in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+
+	dbQueryErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Total number of failed store operations",
+		},
+		[]string{"operation"},
+	)
+
+	dbReadsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_reads_total",
+			Help: "Total number of routed reads by target (replica, primary, primary_pinned, primary_fallback)",
+		},
+		[]string{"target"},
+	)
+
+	dbCacheRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_cache_requests_total",
+			Help: "Total number of store cache lookups by cache and result (hit, miss)",
+		},
+		[]string{"cache", "result"},
+	)
 )
 
 // ObserveHTTP records a single HTTP request metric set.
@@ -37,3 +73,38 @@ func ObserveHTTP(route, method, status string, duration time.Duration) {
 	httpRequestsTotal.WithLabelValues(route, method, status).Inc()
 	httpRequestDurationSeconds.WithLabelValues(route, method, status).Observe(duration.Seconds())
 }
+
+// ObserveDBQuery records the latency of a single store operation and counts it as
+// an error when failed is true.
+func ObserveDBQuery(operation string, duration time.Duration, failed bool) {
+	dbQueryDurationSeconds.WithLabelValues(operation).Observe(duration.Seconds())
+	if failed {
+		dbQueryErrorsTotal.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveDBRead counts a read routed to target.
+func ObserveDBRead(target string) {
+	dbReadsTotal.WithLabelValues(target).Inc()
+}
+
+// ObserveCache counts a store cache lookup as a hit or a miss.
+func ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	dbCacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// RegisterDBStats exports connection pool gauges (open, in-use, idle, wait count
+// and wait duration) from db.Stats() under the go_sql_* metric family, labelled
+// with dbName. Registering the same pool name twice is a no-op.
+func RegisterDBStats(db *sql.DB, dbName string) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, dbName))
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPgxPoolCollector(t *testing.T) {
+	// pgxpool connects lazily, so no server is needed to read its stats.
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/test?pool_max_conns=7")
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	defer pool.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(newPgxPoolCollector(pool, "test"))
+
+	want := `
+# HELP pgxpool_max_conns Maximum size of the pool.
+# TYPE pgxpool_max_conns gauge
+pgxpool_max_conns{db_name="test"} 7
+# HELP pgxpool_total_conns Total connections in the pool.
+# TYPE pgxpool_total_conns gauge
+pgxpool_total_conns{db_name="test"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "pgxpool_max_conns", "pgxpool_total_conns"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testutil.GatherAndCount(reg); err != nil || n != 13 {
+		t.Fatalf("expected 13 pool metrics, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,137 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.0.3
+paths:
+  /things/{id}:
+    parameters:
+      - in: path
+        name: id
+        required: true
+        schema:
+          type: string
+    get:
+      responses:
+        '200':
+          description: ok
+  /things/export:
+    get:
+      responses:
+        '200':
+          description: ok
+components:
+  schemas:
+    Thing:
+      type: object
+      properties:
+        id:
+          type: string
+        count:
+          type: integer
+          minimum: 0
+        createdAt:
+          type: string
+          format: date-time
+        tags:
+          type: array
+          items:
+            type: string
+      required: [id]
+      additionalProperties: false
+`
+
+func TestValidateJSON(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	ref := &Schema{Ref: "#/components/schemas/Thing"}
+
+	if errs := spec.ValidateJSON(ref, []byte(`{"id":"t1","count":2,"createdAt":"2025-01-01T00:00:00Z","tags":["a"]}`), "body"); len(errs) != 0 {
+		t.Fatalf("expected valid document, got %v", errs)
+	}
+
+	errs := spec.ValidateJSON(ref, []byte(`{"count":-1.5,"createdAt":"yesterday","tags":[1],"extra":true}`), "body")
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"body.id: is required",
+		"body.count: expected integer, got -1.5",
+		"body.count: must be >= 0",
+		"body.createdAt: must be an RFC 3339 date-time",
+		"body.extra: unexpected value",
+		"body.tags[0]: expected string, got number",
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if g == w {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing error %q in %v", w, got)
+		}
+	}
+}
+
+func TestMatchPrefersLiteralSegments(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tmpl, params, ok := spec.Match("/things/export")
+	if !ok || tmpl != "/things/export" || len(params) != 0 {
+		t.Fatalf("unexpected match: %s %v %v", tmpl, params, ok)
+	}
+	tmpl, params, ok = spec.Match("/things/abc")
+	if !ok || tmpl != "/things/{id}" || params["id"] != "abc" {
+		t.Fatalf("unexpected match: %s %v %v", tmpl, params, ok)
+	}
+	if _, _, ok := spec.Match("/other"); ok {
+		t.Fatalf("expected no match")
+	}
+}
+
+func TestCompareStruct(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	type thing struct {
+		ID    string   `json:"id"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+		Owner string   `json:"owner"`
+	}
+
+	diffs := spec.CompareStruct("Thing", reflect.TypeOf(thing{}))
+	joined := strings.Join(diffs, "\n")
+	if !strings.Contains(joined, "Thing.owner exists") || !strings.Contains(joined, "Thing.createdAt is documented") {
+		t.Fatalf("unexpected diffs: %v", diffs)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	out, err := ToJSON([]byte("paths:\n  /x:\n    get:\n      responses:\n        200:\n          description: ok\n"))
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), `"200"`) {
+		t.Fatalf("expected numeric status key to become a string: %s", out)
+	}
+}
@@ -0,0 +1,312 @@
+// Package openapi parses the subset of OpenAPI 3.0 used by docs/openapi.yaml
+// and validates values and routes against it.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a parsed OpenAPI document.
+type Spec struct {
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components struct {
+		Schemas   map[string]*Schema   `yaml:"schemas"`
+		Responses map[string]*Response `yaml:"responses"`
+	} `yaml:"components"`
+}
+
+// PathItem holds the operations available on a path template.
+type PathItem struct {
+	Parameters []Parameter `yaml:"parameters"`
+	Get        *Operation  `yaml:"get"`
+	Put        *Operation  `yaml:"put"`
+	Post       *Operation  `yaml:"post"`
+	Delete     *Operation  `yaml:"delete"`
+	Patch      *Operation  `yaml:"patch"`
+	Head       *Operation  `yaml:"head"`
+}
+
+// Operation describes a single method on a path.
+type Operation struct {
+	Summary     string                `yaml:"summary"`
+	Parameters  []Parameter           `yaml:"parameters"`
+	RequestBody *RequestBody          `yaml:"requestBody"`
+	Responses   map[string]*Response  `yaml:"responses"`
+	Security    []map[string][]string `yaml:"security"`
+}
+
+// Parameter describes a path, query or header parameter.
+type Parameter struct {
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required"`
+	Schema   *Schema `yaml:"schema"`
+}
+
+// RequestBody describes the accepted request payloads by media type.
+type RequestBody struct {
+	Required bool                  `yaml:"required"`
+	Content  map[string]*MediaType `yaml:"content"`
+}
+
+// Response describes a response payload by media type.
+type Response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+// MediaType pairs a schema with its examples.
+type MediaType struct {
+	Schema   *Schema             `yaml:"schema"`
+	Example  any                 `yaml:"example"`
+	Examples map[string]*Example `yaml:"examples"`
+}
+
+// Example is a named example value.
+type Example struct {
+	Value any `yaml:"value"`
+}
+
+// Schema is the subset of JSON Schema supported by the validator.
+type Schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Enum                 []any              `yaml:"enum"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	Required             []string           `yaml:"required"`
+	Items                *Schema            `yaml:"items"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties"`
+	Minimum              *float64           `yaml:"minimum"`
+	Maximum              *float64           `yaml:"maximum"`
+	MinLength            *int               `yaml:"minLength"`
+	Pattern              string             `yaml:"pattern"`
+	Nullable             bool               `yaml:"nullable"`
+
+	// deny is set for the boolean schema `false`, which matches nothing.
+	deny bool
+}
+
+// UnmarshalYAML accepts boolean schemas (`additionalProperties: false`) as
+// well as schema objects.
+func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var allow bool
+		if err := node.Decode(&allow); err != nil {
+			return err
+		}
+		*s = Schema{deny: !allow}
+		return nil
+	}
+	type plain Schema
+	return node.Decode((*plain)(s))
+}
+
+// Load parses an OpenAPI document.
+func Load(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse openapi spec: %w", err)
+	}
+	for path, item := range spec.Paths {
+		if item == nil {
+			return nil, fmt.Errorf("parse openapi spec: path %s has no operations", path)
+		}
+		// Inline shared responses so callers never see a $ref.
+		for method, op := range item.Methods() {
+			for status, resp := range op.Responses {
+				if resp == nil || resp.Ref == "" {
+					continue
+				}
+				name, _ := strings.CutPrefix(resp.Ref, "#/components/responses/")
+				shared, ok := spec.Components.Responses[name]
+				if !ok {
+					return nil, fmt.Errorf("parse openapi spec: %s %s %s: unknown response %q", method, path, status, resp.Ref)
+				}
+				op.Responses[status] = shared
+			}
+		}
+	}
+	return &spec, nil
+}
+
+// Methods returns the operations on the path item keyed by HTTP method.
+func (p *PathItem) Methods() map[string]*Operation {
+	out := make(map[string]*Operation)
+	for method, op := range map[string]*Operation{
+		http.MethodGet:    p.Get,
+		http.MethodPut:    p.Put,
+		http.MethodPost:   p.Post,
+		http.MethodDelete: p.Delete,
+		http.MethodPatch:  p.Patch,
+		http.MethodHead:   p.Head,
+	} {
+		if op != nil {
+			out[method] = op
+		}
+	}
+	return out
+}
+
+// Route identifies a documented operation.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Routes lists every documented operation in a stable order.
+func (s *Spec) Routes() []Route {
+	var routes []Route
+	for path, item := range s.Paths {
+		for method := range item.Methods() {
+			routes = append(routes, Route{Method: method, Path: path})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Operation returns the operation for method and path template, if documented.
+func (s *Spec) Operation(method, path string) (*Operation, bool) {
+	item, ok := s.Paths[path]
+	if !ok {
+		return nil, false
+	}
+	op, ok := item.Methods()[method]
+	return op, ok
+}
+
+// Parameters returns the path-level and operation-level parameters for the
+// route, with operation parameters taking precedence.
+func (s *Spec) Parameters(method, path string) []Parameter {
+	item, ok := s.Paths[path]
+	if !ok {
+		return nil
+	}
+	byKey := make(map[string]Parameter)
+	var order []string
+	add := func(params []Parameter) {
+		for _, p := range params {
+			key := p.In + ":" + p.Name
+			if _, seen := byKey[key]; !seen {
+				order = append(order, key)
+			}
+			byKey[key] = p
+		}
+	}
+	add(item.Parameters)
+	if op, ok := item.Methods()[method]; ok {
+		add(op.Parameters)
+	}
+	out := make([]Parameter, 0, len(order))
+	for _, key := range order {
+		out = append(out, byKey[key])
+	}
+	return out
+}
+
+// Match finds the documented path template for a concrete request path and
+// returns the extracted path parameters. Literal segments win over templated
+// ones, so /communities/export is preferred to /communities/{id}.
+func (s *Spec) Match(path string) (template string, params map[string]string, ok bool) {
+	segments := splitPath(path)
+	bestScore := -1
+	for tmpl := range s.Paths {
+		tmplSegments := splitPath(tmpl)
+		if len(tmplSegments) != len(segments) {
+			continue
+		}
+		score := 0
+		matched := make(map[string]string)
+		for i, seg := range tmplSegments {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				matched[seg[1:len(seg)-1]] = segments[i]
+				continue
+			}
+			if seg != segments[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore {
+			bestScore, template, params, ok = score, tmpl, matched, true
+		}
+	}
+	return template, params, ok
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// Resolve follows a local $ref to the referenced component schema.
+func (s *Spec) Resolve(schema *Schema) (*Schema, error) {
+	for schema != nil && schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok {
+			return nil, fmt.Errorf("unsupported $ref %q", schema.Ref)
+		}
+		next, ok := s.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %q", name)
+		}
+		schema = next
+	}
+	return schema, nil
+}
+
+// ToJSON renders a YAML OpenAPI document as indented JSON for tools that do
+// not read YAML.
+func ToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi spec: %w", err)
+	}
+	out, err := json.MarshalIndent(jsonCompatible(doc), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode openapi spec as json: %w", err)
+	}
+	return out, nil
+}
+
+// jsonCompatible converts maps with non-string keys, which YAML allows (e.g.
+// an unquoted 200 status), into maps encoding/json can marshal.
+func jsonCompatible(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = jsonCompatible(item)
+		}
+		return v
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return out
+	case []any:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
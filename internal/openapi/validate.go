@@ -0,0 +1,272 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError describes one way a value does not match its schema. Path is
+// a JSON-pointer-like location such as "body.posts[0].title".
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidateJSON decodes data and validates it against schema.
+func (s *Spec) ValidateJSON(schema *Schema, data []byte, path string) []ValidationError {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []ValidationError{{Path: path, Message: "invalid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return []ValidationError{{Path: path, Message: "invalid JSON: trailing data"}}
+	}
+	return s.Validate(schema, value, path)
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json with
+// UseNumber) against schema and returns every mismatch.
+func (s *Spec) Validate(schema *Schema, value any, path string) []ValidationError {
+	var errs []ValidationError
+	s.validate(schema, value, path, &errs)
+	return errs
+}
+
+func (s *Spec) validate(schema *Schema, value any, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	schema, err := s.Resolve(schema)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+	if schema == nil {
+		return
+	}
+	if schema.deny {
+		fail("unexpected value")
+		return
+	}
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("expected %s, got null", schema.Type)
+		}
+		return
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		fail("value %v is not one of %v", value, schema.Enum)
+	}
+
+	switch schema.Type {
+	case "":
+		// Untyped schemas accept anything.
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("expected object, got %s", jsonType(value))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, ValidationError{Path: path + "." + name, Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := schema.Properties[k]; ok {
+				s.validate(prop, obj[k], path+"."+k, errs)
+			} else if schema.AdditionalProperties != nil {
+				s.validate(schema.AdditionalProperties, obj[k], path+"."+k, errs)
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("expected array, got %s", jsonType(value))
+			return
+		}
+		for i, item := range arr {
+			s.validate(schema.Items, item, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected string, got %s", jsonType(value))
+			return
+		}
+		if schema.MinLength != nil && len([]rune(str)) < *schema.MinLength {
+			fail("must be at least %d characters", *schema.MinLength)
+		}
+		if schema.Pattern != "" {
+			if re, err := regexp.Compile(schema.Pattern); err != nil {
+				fail("invalid pattern %q in schema", schema.Pattern)
+			} else if !re.MatchString(str) {
+				fail("does not match pattern %q", schema.Pattern)
+			}
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("must be an RFC 3339 date-time")
+			}
+		}
+	case "integer", "number":
+		f, ok := toFloat(value)
+		if !ok {
+			fail("expected %s, got %s", schema.Type, jsonType(value))
+			return
+		}
+		if schema.Type == "integer" && f != math.Trunc(f) {
+			fail("expected integer, got %v", value)
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			fail("must be >= %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			fail("must be <= %v", *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %s", jsonType(value))
+		}
+	default:
+		fail("unsupported schema type %q", schema.Type)
+	}
+}
+
+// ValidateParam checks a raw string parameter value against its schema.
+func (s *Spec) ValidateParam(p Parameter, raw, path string) []ValidationError {
+	schema, err := s.Resolve(p.Schema)
+	if err != nil || schema == nil {
+		return nil
+	}
+	var value any = raw
+	switch schema.Type {
+	case "integer", "number":
+		value = json.Number(raw)
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []ValidationError{{Path: path, Message: "expected " + schema.Type}}
+		}
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []ValidationError{{Path: path, Message: "expected boolean"}}
+		}
+		value = b
+	}
+	return s.Validate(schema, value, path)
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number, float64, int:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// CompareStruct reports differences between the JSON fields of a Go struct
+// type and the properties of a component schema, in both directions.
+func (s *Spec) CompareStruct(schemaName string, typ reflect.Type) []string {
+	schema, ok := s.Components.Schemas[schemaName]
+	if !ok {
+		return []string{fmt.Sprintf("schema %s is not documented", schemaName)}
+	}
+	fields := jsonFields(typ)
+
+	var diffs []string
+	for name := range fields {
+		if _, ok := schema.Properties[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s.%s exists on %s but is not documented", schemaName, name, typ))
+		}
+	}
+	for name := range schema.Properties {
+		if _, ok := fields[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s.%s is documented but missing from %s", schemaName, name, typ))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// jsonFields returns the JSON names of typ's exported fields, flattening
+// embedded structs the way encoding/json does.
+func jsonFields(typ reflect.Type) map[string]bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	out := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k := range jsonFields(f.Type) {
+				out[k] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out[name] = true
+	}
+	return out
+}
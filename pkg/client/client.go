@@ -0,0 +1,437 @@
+// Package client is a typed Go client for the Skool MVP API.
+//
+// Method names and signatures mirror db.Store, so code can move between
+// talking to the API and talking to a store directly. Idempotent requests
+// (GET, PUT, DELETE) are retried on network errors, 429 and 5xx gateway
+// responses; non-2xx responses are returned as *APIError.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+)
+
+// Model types shared with the server.
+type (
+	Community        = db.Community
+	CommunityDetail  = db.CommunityDetail
+	CommunityInput   = db.CommunityInput
+	Post             = db.Post
+	PostInput        = db.PostInput
+	FeatureFlag      = db.FeatureFlag
+	FeatureFlagInput = db.FeatureFlagInput
+
+	Membership          = db.Membership
+	Invite              = db.Invite
+	InviteInput         = db.InviteInput
+	JoinRequest         = db.JoinRequest
+	JoinRequestStatus   = db.JoinRequestStatus
+	JoinRequestDecision = db.JoinRequestDecision
+
+	Plan               = db.Plan
+	PlanInput          = db.PlanInput
+	Subscription       = db.Subscription
+	SubscriptionStatus = db.SubscriptionStatus
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 100 * time.Millisecond
+	maxBackoff        = 5 * time.Second
+)
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userID     string
+	adminToken string
+	userAgent  string
+	maxRetries int
+	minBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithUserID identifies the caller with the X-User-ID header.
+func WithUserID(id string) Option {
+	return func(c *Client) {
+		c.userID = id
+	}
+}
+
+// WithAdminToken authenticates /admin calls with a bearer token.
+func WithAdminToken(token string) Option {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithRetries sets how many times an idempotent request is retried and the
+// initial backoff, which doubles on every attempt. Zero retries disables them.
+func WithRetries(n int, minBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+		c.minBackoff = minBackoff
+	}
+}
+
+// New returns a Client for the API at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base url %q must be absolute", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		userAgent:  "skool-client-go",
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// ListCommunities returns every community.
+func (c *Client) ListCommunities(ctx context.Context) ([]Community, error) {
+	var out []Community
+	_, err := c.do(ctx, http.MethodGet, "/communities", nil, nil, &out)
+	return out, err
+}
+
+// CreateCommunity creates a community.
+func (c *Client) CreateCommunity(ctx context.Context, input CommunityInput) (Community, error) {
+	var out Community
+	_, err := c.do(ctx, http.MethodPost, "/communities", nil, input, &out)
+	return out, err
+}
+
+// GetCommunity returns a community with its member and post counts. id may
+// also be the community's slug.
+func (c *Client) GetCommunity(ctx context.Context, id string) (CommunityDetail, error) {
+	var out CommunityDetail
+	_, err := c.do(ctx, http.MethodGet, "/communities/"+url.PathEscape(id), nil, nil, &out)
+	return out, err
+}
+
+// UpdateCommunity replaces a community's name and description, and its slug
+// when input.Slug is set.
+func (c *Client) UpdateCommunity(ctx context.Context, id string, input CommunityInput) (Community, error) {
+	var out Community
+	_, err := c.do(ctx, http.MethodPut, "/communities/"+url.PathEscape(id), nil, input, &out)
+	return out, err
+}
+
+// DeleteCommunity deletes a community and its posts.
+func (c *Client) DeleteCommunity(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/communities/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
+
+// ListPostsByCommunity returns every post in a community.
+func (c *Client) ListPostsByCommunity(ctx context.Context, communityID string) ([]Post, error) {
+	var out []Post
+	_, err := c.do(ctx, http.MethodGet, postsPath(communityID), nil, nil, &out)
+	return out, err
+}
+
+// CreatePost creates a post in a community.
+func (c *Client) CreatePost(ctx context.Context, communityID string, input PostInput) (Post, error) {
+	var out Post
+	_, err := c.do(ctx, http.MethodPost, postsPath(communityID), nil, input, &out)
+	return out, err
+}
+
+// GetPost returns a post in a community.
+func (c *Client) GetPost(ctx context.Context, communityID, postID string) (Post, error) {
+	var out Post
+	_, err := c.do(ctx, http.MethodGet, postsPath(communityID)+"/"+url.PathEscape(postID), nil, nil, &out)
+	return out, err
+}
+
+// DeletePost deletes a post from a community.
+func (c *Client) DeletePost(ctx context.Context, communityID, postID string) error {
+	_, err := c.do(ctx, http.MethodDelete, postsPath(communityID)+"/"+url.PathEscape(postID), nil, nil, nil)
+	return err
+}
+
+// JoinCommunity joins a public community as the WithUserID user and returns
+// the membership. For a private community it returns a zero Membership and
+// the pending join request instead.
+func (c *Client) JoinCommunity(ctx context.Context, id string) (Membership, *JoinRequest, error) {
+	var raw json.RawMessage
+	if _, err := c.do(ctx, http.MethodPost, "/communities/"+url.PathEscape(id)+"/join", nil, nil, &raw); err != nil {
+		return Membership{}, nil, err
+	}
+	var probe struct {
+		Status JoinRequestStatus `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return Membership{}, nil, fmt.Errorf("decode join response: %w", err)
+	}
+	if probe.Status != "" {
+		var req JoinRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return Membership{}, nil, fmt.Errorf("decode join response: %w", err)
+		}
+		return Membership{}, &req, nil
+	}
+	var m Membership
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Membership{}, nil, fmt.Errorf("decode join response: %w", err)
+	}
+	return m, nil, nil
+}
+
+// ListJoinRequests returns a community's pending join requests, oldest first.
+func (c *Client) ListJoinRequests(ctx context.Context, communityID string) ([]JoinRequest, error) {
+	var out []JoinRequest
+	_, err := c.do(ctx, http.MethodGet, "/communities/"+url.PathEscape(communityID)+"/join-requests", nil, nil, &out)
+	return out, err
+}
+
+// DecideJoinRequest approves or rejects a pending join request.
+func (c *Client) DecideJoinRequest(ctx context.Context, communityID, requestID string, status JoinRequestStatus) (JoinRequest, error) {
+	var out JoinRequest
+	path := "/communities/" + url.PathEscape(communityID) + "/join-requests/" + url.PathEscape(requestID)
+	_, err := c.do(ctx, http.MethodPut, path, nil, JoinRequestDecision{Status: status}, &out)
+	return out, err
+}
+
+// CreateInvite creates an invite link for a community.
+func (c *Client) CreateInvite(ctx context.Context, communityID string, input InviteInput) (Invite, error) {
+	var out Invite
+	_, err := c.do(ctx, http.MethodPost, "/communities/"+url.PathEscape(communityID)+"/invites", nil, input, &out)
+	return out, err
+}
+
+// RedeemInvite makes the WithUserID user a member of the invite's community.
+func (c *Client) RedeemInvite(ctx context.Context, code string) (Membership, error) {
+	var out Membership
+	_, err := c.do(ctx, http.MethodPost, "/invites/"+url.PathEscape(code), nil, nil, &out)
+	return out, err
+}
+
+// ListPlans returns a community's pricing plans, cheapest first.
+func (c *Client) ListPlans(ctx context.Context, communityID string) ([]Plan, error) {
+	var out []Plan
+	_, err := c.do(ctx, http.MethodGet, "/communities/"+url.PathEscape(communityID)+"/plans", nil, nil, &out)
+	return out, err
+}
+
+// CreatePlan adds a pricing plan, making the community's posts paid.
+func (c *Client) CreatePlan(ctx context.Context, communityID string, input PlanInput) (Plan, error) {
+	var out Plan
+	_, err := c.do(ctx, http.MethodPost, "/communities/"+url.PathEscape(communityID)+"/plans", nil, input, &out)
+	return out, err
+}
+
+// Subscribe subscribes the WithUserID user to a plan and returns their
+// membership with the new subscription.
+func (c *Client) Subscribe(ctx context.Context, communityID, planID string) (Membership, error) {
+	var out Membership
+	path := "/communities/" + url.PathEscape(communityID) + "/plans/" + url.PathEscape(planID) + "/subscribe"
+	_, err := c.do(ctx, http.MethodPost, path, nil, nil, &out)
+	return out, err
+}
+
+// CancelSubscription cancels the WithUserID user's subscription to a
+// community.
+func (c *Client) CancelSubscription(ctx context.Context, communityID string) (Membership, error) {
+	var out Membership
+	_, err := c.do(ctx, http.MethodDelete, "/communities/"+url.PathEscape(communityID)+"/subscription", nil, nil, &out)
+	return out, err
+}
+
+// ListFeatureFlags returns every feature flag. Requires WithAdminToken.
+func (c *Client) ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	var out []FeatureFlag
+	_, err := c.do(ctx, http.MethodGet, "/admin/flags", nil, nil, &out)
+	return out, err
+}
+
+// GetFeatureFlag returns a feature flag. Requires WithAdminToken.
+func (c *Client) GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error) {
+	var out FeatureFlag
+	_, err := c.do(ctx, http.MethodGet, flagPath(name), nil, nil, &out)
+	return out, err
+}
+
+// UpsertFeatureFlag creates or replaces a feature flag. Requires WithAdminToken.
+func (c *Client) UpsertFeatureFlag(ctx context.Context, name string, input FeatureFlagInput) (FeatureFlag, error) {
+	var out FeatureFlag
+	_, err := c.do(ctx, http.MethodPut, flagPath(name), nil, input, &out)
+	return out, err
+}
+
+// DeleteFeatureFlag deletes a feature flag. Requires WithAdminToken.
+func (c *Client) DeleteFeatureFlag(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, flagPath(name), nil, nil, nil)
+	return err
+}
+
+func postsPath(communityID string) string {
+	return "/communities/" + url.PathEscape(communityID) + "/posts"
+}
+
+func flagPath(name string) string {
+	return "/admin/flags/" + url.PathEscape(name)
+}
+
+// do sends a request, retrying idempotent methods, and decodes a JSON
+// response into out when out is non-nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) (http.Header, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	retries := 0
+	if idempotent(method) {
+		retries = c.maxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), body)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out != nil {
+				if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+					return resp.Header, fmt.Errorf("decode %s %s response: %w", method, path, err)
+				}
+			}
+			return resp.Header, nil
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			apiErr := decodeError(resp)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			resp.Body.Close()
+			if !retryableStatus(resp.StatusCode) {
+				return resp.Header, apiErr
+			}
+			err = apiErr
+		} else if ctx.Err() != nil {
+			return nil, err
+		}
+
+		if attempt >= retries {
+			return nil, err
+		}
+		if waitErr := sleep(ctx, max(c.backoff(attempt), retryAfter)); waitErr != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.userID != "" {
+		req.Header.Set("X-User-ID", c.userID)
+	}
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, req.URL.Path, err)
+	}
+	return resp, nil
+}
+
+// backoff returns the delay before retry attempt+1: exponential with jitter,
+// capped at maxBackoff.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.minBackoff << attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return min(time.Duration(secs)*time.Second, maxBackoff)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
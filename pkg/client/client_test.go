@@ -0,0 +1,337 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/hcuri/skool-mvp-app/docs"
+	"github.com/hcuri/skool-mvp-app/internal/billing"
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	apihttp "github.com/hcuri/skool-mvp-app/internal/http"
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
+	"github.com/hcuri/skool-mvp-app/pkg/client"
+)
+
+func newClient(t *testing.T, handler http.Handler, opts ...client.Option) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := client.New(srv.URL, append([]client.Option{client.WithRetries(3, time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c
+}
+
+func TestCommunitiesAndPosts(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, apihttp.NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t)))
+
+	community, err := c.CreateCommunity(ctx, client.CommunityInput{Name: "Go Fans"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	post, err := c.CreatePost(ctx, community.ID, client.PostInput{Title: "Hi", Content: "Hello"})
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+
+	posts, err := c.ListPostsByCommunity(ctx, community.ID)
+	if err != nil || len(posts) != 1 || posts[0].ID != post.ID {
+		t.Fatalf("list posts = %v, %v", posts, err)
+	}
+	detail, err := c.GetCommunity(ctx, community.ID)
+	if err != nil || detail.Name != "Go Fans" || detail.PostCount != 1 {
+		t.Fatalf("get community = %+v, %v", detail, err)
+	}
+	updated, err := c.UpdateCommunity(ctx, community.ID, client.CommunityInput{Name: "Gophers", Slug: "gophers"})
+	if err != nil || updated.Slug != "gophers" {
+		t.Fatalf("update community = %+v, %v", updated, err)
+	}
+	// The client follows the redirect from the former slug.
+	if detail, err := c.GetCommunity(ctx, "go-fans"); err != nil || detail.ID != community.ID || detail.Slug != "gophers" {
+		t.Fatalf("get community by former slug = %+v, %v", detail, err)
+	}
+	if _, err := c.CreateCommunity(ctx, client.CommunityInput{Name: "Copy", Slug: "go-fans"}); !errors.Is(err, db.ErrSlugTaken) {
+		t.Fatalf("expected ErrSlugTaken, got %v", err)
+	}
+	got, err := c.GetPost(ctx, community.ID, post.ID)
+	if err != nil || got.ID != post.ID || got.Content != "Hello" {
+		t.Fatalf("get post = %+v, %v", got, err)
+	}
+	if err := c.DeletePost(ctx, community.ID, post.ID); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	if err := c.DeletePost(ctx, community.ID, post.ID); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound, got %v", err)
+	}
+	if _, err := c.GetPost(ctx, community.ID, post.ID); !errors.Is(err, db.ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound, got %v", err)
+	}
+	if err := c.DeleteCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("delete community: %v", err)
+	}
+	if _, err := c.ListPostsByCommunity(ctx, community.ID); !errors.Is(err, db.ErrCommunityNotFound) || !client.IsNotFound(err) {
+		t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+	}
+}
+
+func TestPlansAndSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	srv := httptest.NewServer(apihttp.NewRouter(store, zaptest.NewLogger(t), apihttp.WithBilling(billing.NewFake("whsec"))))
+	t.Cleanup(srv.Close)
+	as := func(name string) *client.Client {
+		user, err := store.CreateUser(ctx, db.UserInput{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		c, err := client.New(srv.URL, client.WithUserID(user.ID))
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		return c
+	}
+	admin, bob := as("Ada"), as("Bob")
+
+	community, err := admin.CreateCommunity(ctx, client.CommunityInput{Name: "Club"})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	plan, err := admin.CreatePlan(ctx, community.ID, client.PlanInput{Name: "Monthly", PriceCents: 900, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	if plans, err := bob.ListPlans(ctx, community.Slug); err != nil || len(plans) != 1 || plans[0] != plan {
+		t.Fatalf("list plans = %+v, %v", plans, err)
+	}
+	if _, err := bob.Subscribe(ctx, community.ID, "missing"); !errors.Is(err, db.ErrPlanNotFound) {
+		t.Fatalf("expected ErrPlanNotFound, got %v", err)
+	}
+	if _, err := bob.CancelSubscription(ctx, community.ID); !errors.Is(err, db.ErrSubscriptionNotFound) {
+		t.Fatalf("expected ErrSubscriptionNotFound, got %v", err)
+	}
+	m, err := bob.Subscribe(ctx, community.ID, plan.ID)
+	if err != nil || !m.Subscription.Paid() || m.Subscription.PlanID != plan.ID {
+		t.Fatalf("subscribe = %+v, %v", m, err)
+	}
+	if _, err := bob.ListPostsByCommunity(ctx, community.ID); err != nil {
+		t.Fatalf("list posts as subscriber: %v", err)
+	}
+	if m, err := bob.CancelSubscription(ctx, community.ID); err != nil || m.Subscription.Status != db.SubscriptionCanceled {
+		t.Fatalf("cancel = %+v, %v", m, err)
+	}
+	var apiErr *client.APIError
+	if _, err := bob.ListPostsByCommunity(ctx, community.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("expected 402 after canceling, got %v", err)
+	}
+}
+
+func TestJoinRequestsAndInvites(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	srv := httptest.NewServer(apihttp.NewRouter(store, zaptest.NewLogger(t)))
+	t.Cleanup(srv.Close)
+	as := func(name string) (*client.Client, string) {
+		user, err := store.CreateUser(ctx, db.UserInput{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		c, err := client.New(srv.URL, client.WithUserID(user.ID))
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		return c, user.ID
+	}
+	admin, _ := as("Ada")
+	bob, bobID := as("Bob")
+	cy, _ := as("Cy")
+
+	community, err := admin.CreateCommunity(ctx, client.CommunityInput{Name: "Club", Visibility: db.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("create community: %v", err)
+	}
+	m, req, err := bob.JoinCommunity(ctx, community.Slug)
+	if err != nil || req == nil || req.UserID != bobID || m.Role != "" {
+		t.Fatalf("join private community = %+v, %+v, %v", m, req, err)
+	}
+	pending, err := admin.ListJoinRequests(ctx, community.ID)
+	if err != nil || len(pending) != 1 || pending[0].ID != req.ID {
+		t.Fatalf("list join requests = %+v, %v", pending, err)
+	}
+	if _, err := admin.DecideJoinRequest(ctx, community.ID, req.ID, db.JoinRequestApproved); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if _, err := admin.DecideJoinRequest(ctx, community.ID, req.ID, db.JoinRequestRejected); !errors.Is(err, db.ErrJoinRequestDecided) {
+		t.Fatalf("expected ErrJoinRequestDecided, got %v", err)
+	}
+	if m, req, err := bob.JoinCommunity(ctx, community.ID); err != nil || req != nil || m.Role != db.RoleMember {
+		t.Fatalf("join as member = %+v, %+v, %v", m, req, err)
+	}
+
+	invite, err := admin.CreateInvite(ctx, community.ID, client.InviteInput{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	if m, err := cy.RedeemInvite(ctx, invite.Code); err != nil || m.CommunityID != community.ID {
+		t.Fatalf("redeem invite = %+v, %v", m, err)
+	}
+	if _, err := cy.RedeemInvite(ctx, "no-such-code"); !errors.Is(err, db.ErrInviteNotFound) {
+		t.Fatalf("expected ErrInviteNotFound, got %v", err)
+	}
+	late, _ := as("Dee")
+	if _, err := late.RedeemInvite(ctx, invite.Code); !errors.Is(err, db.ErrInviteExpired) {
+		t.Fatalf("expected ErrInviteExpired, got %v", err)
+	}
+}
+
+func TestFeatureFlags(t *testing.T) {
+	ctx := context.Background()
+	router := apihttp.NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t), apihttp.WithAdmin("secret", zap.NewAtomicLevel()))
+
+	anonymous := newClient(t, router)
+	var apiErr *client.APIError
+	if _, err := anonymous.ListFeatureFlags(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %v", err)
+	}
+
+	admin := newClient(t, router, client.WithAdminToken("secret"))
+	if _, err := admin.UpsertFeatureFlag(ctx, "beta", client.FeatureFlagInput{Enabled: true, Percentage: 10}); err != nil {
+		t.Fatalf("upsert flag: %v", err)
+	}
+	flag, err := admin.GetFeatureFlag(ctx, "beta")
+	if err != nil || flag.Percentage != 10 {
+		t.Fatalf("get flag = %+v, %v", flag, err)
+	}
+	if err := admin.DeleteFeatureFlag(ctx, "beta"); err != nil {
+		t.Fatalf("delete flag: %v", err)
+	}
+	if _, err := admin.GetFeatureFlag(ctx, "beta"); !errors.Is(err, db.ErrFeatureFlagNotFound) {
+		t.Fatalf("expected ErrFeatureFlagNotFound, got %v", err)
+	}
+}
+
+func TestValidationErrorDetails(t *testing.T) {
+	spec, err := openapi.Load(docs.OpenAPISpec)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	router := apihttp.NewRouter(db.NewInMemoryStore(), zaptest.NewLogger(t),
+		apihttp.WithValidation(spec, apihttp.ValidationOptions{Requests: true}))
+	c := newClient(t, router)
+
+	var apiErr *client.APIError
+	for _, err = range c.Communities(context.Background(), 500) {
+	}
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 APIError, got %v", err)
+	}
+	if apiErr.Message != "request validation failed" || len(apiErr.Details) == 0 {
+		t.Fatalf("expected structured details, got %+v", apiErr)
+	}
+}
+
+func TestRetriesIdempotentRequestsOnly(t *testing.T) {
+	var calls atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"id":"c1","name":"Go Fans","description":""}]`)
+	})
+	c := newClient(t, flaky)
+
+	communities, err := c.ListCommunities(context.Background())
+	if err != nil || len(communities) != 1 {
+		t.Fatalf("list communities = %v, %v", communities, err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+
+	calls.Store(0)
+	_, err = c.CreateCommunity(context.Background(), client.CommunityInput{Name: "x"})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "try later" {
+		t.Fatalf("expected 503 APIError, got %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("POST must not be retried, got %d attempts", got)
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	var calls atomic.Int32
+	down := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	c := newClient(t, down)
+
+	err := c.DeleteCommunity(context.Background(), "c1")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 APIError, got %v", err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Fatalf("expected 1 attempt plus 3 retries, got %d", got)
+	}
+}
+
+func TestPaginationIterators(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewInMemoryStore()
+	var calls atomic.Int32
+	router := apihttp.NewRouter(store, zaptest.NewLogger(t))
+	counting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		router.ServeHTTP(w, r)
+	})
+	c := newClient(t, counting)
+
+	for i := range 5 {
+		if _, err := store.CreateCommunity(ctx, db.CommunityInput{Name: fmt.Sprintf("c%d", i)}); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	var names []string
+	for community, err := range c.Communities(ctx, 2) {
+		if err != nil {
+			t.Fatalf("iterate: %v", err)
+		}
+		names = append(names, community.Name)
+	}
+	if len(names) != 5 {
+		t.Fatalf("expected 5 communities, got %v", names)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 page requests, got %d", got)
+	}
+
+	calls.Store(0)
+	for range c.Communities(ctx, 2) {
+		break
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("breaking early should stop fetching, got %d requests", got)
+	}
+
+	for _, err := range c.Posts(ctx, "missing", 2) {
+		if !errors.Is(err, db.ErrCommunityNotFound) {
+			t.Fatalf("expected ErrCommunityNotFound, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/hcuri/skool-mvp-app/internal/db"
+	"github.com/hcuri/skool-mvp-app/internal/openapi"
+)
+
+// ValidationError is one field-level problem reported by the server when
+// OpenAPI request validation is enabled.
+type ValidationError = openapi.ValidationError
+
+// maxErrorBodyBytes caps how much of an error response is read.
+const maxErrorBodyBytes = 64 << 10
+
+// APIError is returned for any non-2xx response. It matches the db sentinel
+// errors with errors.Is, so callers can handle a 404 from the API the same way
+// they would a db.Store error.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Details    []ValidationError
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("skool api: %d %s", e.StatusCode, e.Message)
+	for _, d := range e.Details {
+		msg += "; " + d.Error()
+	}
+	return msg
+}
+
+// Is maps 404, 409 and 410 responses onto the matching db sentinel error.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case db.ErrSlugTaken:
+		return e.StatusCode == http.StatusConflict && e.Message == "slug already taken"
+	case db.ErrJoinRequestDecided:
+		return e.StatusCode == http.StatusConflict && e.Message == "join request already decided"
+	case db.ErrInviteExpired:
+		return e.StatusCode == http.StatusGone
+	}
+	if e.StatusCode != http.StatusNotFound {
+		return false
+	}
+	switch target {
+	case db.ErrCommunityNotFound:
+		return e.Message == "community not found"
+	case db.ErrPostNotFound:
+		return e.Message == "post not found"
+	case db.ErrFeatureFlagNotFound:
+		return e.Message == "feature flag not found"
+	case db.ErrInviteNotFound:
+		return e.Message == "invite not found"
+	case db.ErrJoinRequestNotFound:
+		return e.Message == "join request not found"
+	case db.ErrPlanNotFound:
+		return e.Message == "plan not found"
+	case db.ErrSubscriptionNotFound:
+		return e.Message == "subscription not found"
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// decodeError builds an APIError from resp. The server answers with plain
+// text, or with a JSON body of the form {"error": ..., "details": [...]} when a
+// request fails OpenAPI validation.
+func decodeError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var failure struct {
+			Error   string            `json:"error"`
+			Details []ValidationError `json:"details"`
+		}
+		if err := json.Unmarshal(body, &failure); err == nil && failure.Error != "" {
+			apiErr.Message = failure.Error
+			apiErr.Details = failure.Details
+			return apiErr
+		}
+	}
+
+	apiErr.Message = strings.TrimSpace(string(body))
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultPageSize is used by the iterators when pageSize is not positive.
+const DefaultPageSize = 50
+
+// Communities iterates over all communities, fetching pageSize at a time. The
+// iteration stops after the first error, which is yielded with a zero value.
+func (c *Client) Communities(ctx context.Context, pageSize int) iter.Seq2[Community, error] {
+	return paginate[Community](ctx, c, "/communities", pageSize)
+}
+
+// Posts iterates over the posts in a community, fetching pageSize at a time.
+func (c *Client) Posts(ctx context.Context, communityID string, pageSize int) iter.Seq2[Post, error] {
+	return paginate[Post](ctx, c, postsPath(communityID), pageSize)
+}
+
+// paginate requests path page by page, following the server's Link rel="next"
+// header until it is absent.
+func paginate[T any](ctx context.Context, c *Client, path string, pageSize int) iter.Seq2[T, error] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return func(yield func(T, error) bool) {
+		query := url.Values{
+			"limit":  {strconv.Itoa(pageSize)},
+			"offset": {"0"},
+		}
+		for query != nil {
+			var page []T
+			header, err := c.do(ctx, http.MethodGet, path, query, nil, &page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			query = nextPageQuery(header)
+		}
+	}
+}
+
+// nextPageQuery returns the query string of the rel="next" link, or nil. Only
+// the query is reused so a base URL with a path prefix keeps working behind
+// proxies that rewrite paths.
+func nextPageQuery(header http.Header) url.Values {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.Contains(params, `rel="next"`) {
+				continue
+			}
+			target = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(target), "<"), ">")
+			u, err := url.Parse(target)
+			if err != nil {
+				return nil
+			}
+			return u.Query()
+		}
+	}
+	return nil
+}